@@ -19,6 +19,7 @@ var Users []types.User
 var Balances []types.Balance
 var Transections []types.Transection
 var Markets []types.Market
+var Observations []types.Observation
 
 var databaseFromEngineQueueClient *redis.Client
 
@@ -69,6 +70,13 @@ func handleIncomingMessages(message []byte) error {
 			return err
 		}
 		return createOrUpdateOrder(order)
+	case types.UPDATE_ORDER:
+		var update types.OrderUpdate
+		err = json.Unmarshal(msg.Data, &update)
+		if err != nil {
+			return err
+		}
+		return updateOrder(update)
 	case types.MARKET:
 		var market types.Market
 		err = json.Unmarshal(msg.Data, &market)
@@ -76,6 +84,13 @@ func handleIncomingMessages(message []byte) error {
 			return err
 		}
 		return createOrUpdateMarket(market)
+	case types.MARKET_RESOLUTION:
+		var update types.MarketResolutionUpdate
+		err = json.Unmarshal(msg.Data, &update)
+		if err != nil {
+			return err
+		}
+		return updateMarketResolution(update)
 	case types.USER:
 		var user types.User
 		err = json.Unmarshal(msg.Data, &user)
@@ -104,16 +119,58 @@ func handleIncomingMessages(message []byte) error {
 			return err
 		}
 		return createTransection(transection)
+	case types.OBSERVATION:
+		// Every value an oracle reported, kept for auditing markets
+		var observation types.Observation
+		err = json.Unmarshal(msg.Data, &observation)
+		if err != nil {
+			return err
+		}
+		Observations = append(Observations, observation)
+		return nil
+	case types.USER_USD:
+		// Every user's USD balance, sent by the engine after each order
+		var usd struct {
+			Data types.USDBalances `json:"data"`
+		}
+		err = json.Unmarshal(msg.Data, &usd)
+		if err != nil {
+			return err
+		}
+		for userId, balance := range usd.Data {
+			createOrUpdateBalance(types.Balance{UserId: userId, Balance: balance.Balance, Locked: balance.Locked})
+		}
+		return nil
+	case types.USER_STOCKS:
+		// Every user's stocks, sent by the engine after each order
+		var stocks struct {
+			Data map[string]struct {
+				Data json.RawMessage `json:"data"`
+			} `json:"data"`
+		}
+		err = json.Unmarshal(msg.Data, &stocks)
+		if err != nil {
+			return err
+		}
+		for userId, userStocks := range stocks.Data {
+			createOrUpdateUserStock(types.User{Id: userId, Stock: userStocks.Data})
+		}
+		return nil
 	default:
 		return fmt.Errorf("unknown type: %s", msg.Type)
 	}
 }
 
 func createTransection(data types.Transection) error {
-	data.Id = fmt.Sprintf("transection%d", transectionCounter)
-	transectionCounter++
-	data.CreatedAt = time.Now().Format(time.RFC3339)
-	data.UpdatedAt = data.CreatedAt
+	// Trades come with the id the engine gave them
+	if data.Id == "" {
+		data.Id = fmt.Sprintf("transection%d", transectionCounter)
+		transectionCounter++
+	}
+	if data.CreatedAt == "" {
+		data.CreatedAt = time.Now().Format(time.RFC3339)
+	}
+	data.UpdatedAt = time.Now().Format(time.RFC3339)
 	Transections = append(Transections, data)
 	return nil
 }
@@ -135,6 +192,21 @@ func createOrUpdateOrder(data types.Order) error {
 	return nil
 }
 
+// updateOrder applies a fill or cancellation to an existing order
+func updateOrder(update types.OrderUpdate) error {
+	for i := range Orders {
+		if Orders[i].Id == update.OrderId {
+			Orders[i].FilledQty += update.FilledQty
+			if update.Status != "" {
+				Orders[i].Status = update.Status
+			}
+			Orders[i].UpdatedAt = time.Now().Format(time.RFC3339)
+			return nil
+		}
+	}
+	return fmt.Errorf("order %s not found", update.OrderId)
+}
+
 func createOrUpdateBalance(data types.Balance) error {
 	for i := range Balances {
 		if Balances[i].UserId == data.UserId {
@@ -183,3 +255,16 @@ func createOrUpdateMarket(data types.Market) error {
 	Markets = append(Markets, data)
 	return nil
 }
+
+// updateMarketResolution stores the proposal, disputes and decision of a manual market
+func updateMarketResolution(update types.MarketResolutionUpdate) error {
+	for i := range Markets {
+		if Markets[i].Symbol == update.Symbol {
+			resolution := update.Resolution
+			Markets[i].Resolution = &resolution
+			Markets[i].UpdatedAt = time.Now().Format(time.RFC3339)
+			return nil
+		}
+	}
+	return fmt.Errorf("market %s not found", update.Symbol)
+}
@@ -2,13 +2,15 @@ module github.com/adityadeshlahre/probo-v1/database
 
 go 1.25.1
 
-require github.com/adityadeshlahre/probo-v1/shared v0.0.0
+require (
+	github.com/adityadeshlahre/probo-v1/shared v0.0.0
+	github.com/redis/go-redis/v9 v9.14.0
+)
 
 require (
 	github.com/cespare/xxhash/v2 v2.3.0 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
 	github.com/joho/godotenv v1.5.1 // indirect
-	github.com/redis/go-redis/v9 v9.14.0 // indirect
 )
 
 replace github.com/adityadeshlahre/probo-v1/shared => ../shared
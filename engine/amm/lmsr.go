@@ -0,0 +1,112 @@
+package amm
+
+import (
+	"math"
+	"sort"
+
+	types "github.com/adityadeshlahre/probo-v1/shared/types"
+)
+
+// An LMSR (logarithmic market scoring rule) market maker prices a YES/NO
+// market from the shares it has sold, qYes and qNo, with the cost function
+//
+//	C(q) = b × ln(e^(qYes/b) + e^(qNo/b))
+//
+// A trade that takes q to q' costs C(q') - C(q) shares' worth of payout. The
+// price of YES is e^(qYes/b) / (e^(qYes/b) + e^(qNo/b)), so YES and NO always
+// add up to the payout, and the market maker loses at most b × ln 2 × payout.
+// Costs are rounded up and proceeds down, to the cent, so rounding never
+// adds to that loss.
+
+// Liquidity works out the b and subsidy of a market maker from a config
+func Liquidity(config types.AMMConfig, payout types.Amount) (float64, types.Amount, bool) {
+	b, subsidy := config.Liquidity, config.Subsidy
+	if b == 0 {
+		b = float64(subsidy) / (math.Ln2 * float64(payout))
+	}
+	if subsidy == 0 {
+		subsidy = MaxLoss(b, payout)
+	}
+	return b, subsidy, subsidy >= MaxLoss(b, payout)
+}
+
+// MaxLoss is the most a market maker with liquidity b can lose
+func MaxLoss(b float64, payout types.Amount) types.Amount {
+	return types.Amount(math.Ceil(b * math.Ln2 * float64(payout)))
+}
+
+// cost is C(q), in shares
+func cost(b float64, yes, no types.Shares) float64 {
+	y, n := float64(yes)/b, float64(no)/b
+	m := math.Max(y, n)
+	return b * (m + math.Log(math.Exp(y-m)+math.Exp(n-m)))
+}
+
+// Price is the instantaneous price of stockType, the chance the market maker
+// gives it times the payout
+func Price(a types.AMM, stockType string, payout types.Amount) float64 {
+	y, n := float64(a.Yes)/a.B, float64(a.No)/a.B
+	if stockType == "no" {
+		y, n = n, y
+	}
+	return float64(payout) / (1 + math.Exp(n-y))
+}
+
+// moved returns the shares sold of each type after the market maker sells
+// quantity stockType shares, or buys them back for a negative quantity
+func moved(a types.AMM, stockType string, quantity types.Shares) (types.Shares, types.Shares) {
+	if stockType == "yes" {
+		return a.Yes + quantity, a.No
+	}
+	return a.Yes, a.No + quantity
+}
+
+// BuyCost is what buying quantity stockType shares from the market maker costs
+func BuyCost(a types.AMM, stockType string, quantity types.Shares, payout types.Amount) types.Amount {
+	yes, no := moved(a, stockType, quantity)
+	return types.Amount(math.Ceil((cost(a.B, yes, no) - cost(a.B, a.Yes, a.No)) * float64(payout)))
+}
+
+// SellProceeds is what selling quantity stockType shares to the market maker pays
+func SellProceeds(a types.AMM, stockType string, quantity types.Shares, payout types.Amount) types.Amount {
+	yes, no := moved(a, stockType, -quantity)
+	return types.Amount(math.Floor((cost(a.B, a.Yes, a.No) - cost(a.B, yes, no)) * float64(payout)))
+}
+
+// Buyable returns how many of up to max stockType shares can be bought from
+// the market maker for at most maxPrice each: the price of the last share,
+// and so the average price, stays at or below it
+func Buyable(a types.AMM, stockType string, maxPrice types.Amount, max types.Shares, payout types.Amount) types.Shares {
+	if maxPrice <= 0 || max <= 0 {
+		return 0
+	}
+	return types.Shares(sort.Search(int(max), func(i int) bool {
+		last := types.Shares(i + 1)
+		yes, no := moved(a, stockType, last-1)
+		yesAfter, noAfter := moved(a, stockType, last)
+		return (cost(a.B, yesAfter, noAfter)-cost(a.B, yes, no))*float64(payout) > float64(maxPrice)
+	}))
+}
+
+// Sellable returns how many of up to max stockType shares the market maker
+// buys for at least minPrice each
+func Sellable(a types.AMM, stockType string, minPrice types.Amount, max types.Shares, payout types.Amount) types.Shares {
+	if max <= 0 {
+		return 0
+	}
+	return types.Shares(sort.Search(int(max), func(i int) bool {
+		last := types.Shares(i + 1)
+		yes, no := moved(a, stockType, -(last - 1))
+		yesAfter, noAfter := moved(a, stockType, -last)
+		return (cost(a.B, yes, no)-cost(a.B, yesAfter, noAfter))*float64(payout) < float64(minPrice)
+	}))
+}
+
+// WorstCaseLoss is what the market maker loses if the outcome worse for it
+// wins with the shares it has sold so far: it pays out the shares and keeps
+// what it was paid for them. It's never more than MaxLoss.
+func WorstCaseLoss(a types.AMM, payout types.Amount) types.Amount {
+	received := (cost(a.B, a.Yes, a.No) - cost(a.B, 0, 0)) * float64(payout)
+	owed := float64(max(a.Yes, a.No)) * float64(payout)
+	return types.Amount(math.Max(0, math.Ceil(owed-received)))
+}
@@ -0,0 +1,117 @@
+package amm
+
+import (
+	"math"
+	"testing"
+
+	types "github.com/adityadeshlahre/probo-v1/shared/types"
+)
+
+const payout = types.MaxPrice
+
+func TestPrice(t *testing.T) {
+	fresh := types.AMM{B: 100}
+	if yes, no := Price(fresh, "yes", payout), Price(fresh, "no", payout); yes != float64(payout)/2 || no != yes {
+		t.Errorf("fresh market maker prices YES at %v and NO at %v, want half the payout each", yes, no)
+	}
+
+	for _, a := range []types.AMM{
+		{B: 100, Yes: 50},
+		{B: 100, No: 50},
+		{B: 100, Yes: 30, No: 80},
+		{B: 10, Yes: -20, No: 400},
+	} {
+		yes, no := Price(a, "yes", payout), Price(a, "no", payout)
+		if math.Abs(yes+no-float64(payout)) > 1e-6 {
+			t.Errorf("%+v prices YES at %v and NO at %v, want them to add up to %v", a, yes, no, payout)
+		}
+		// The side it sold more of is the dearer one, by the same amount the
+		// other way round
+		mirrored := types.AMM{B: a.B, Yes: a.No, No: a.Yes}
+		if math.Abs(Price(mirrored, "no", payout)-yes) > 1e-6 {
+			t.Errorf("%+v prices YES at %v but mirrored prices NO at %v", a, yes, Price(mirrored, "no", payout))
+		}
+		if (a.Yes > a.No) != (yes > no) {
+			t.Errorf("%+v prices YES at %v and NO at %v", a, yes, no)
+		}
+	}
+}
+
+// Buying YES until it's certain costs a trader b × ln 2 × payout less than
+// it pays out, and no more
+func TestLossBound(t *testing.T) {
+	for _, b := range []float64{10, 100, 289.5} {
+		bound := b * math.Ln2 * float64(payout)
+		maxLoss := MaxLoss(b, payout)
+		if float64(maxLoss) < bound || float64(maxLoss) >= bound+1 {
+			t.Errorf("b %v: MaxLoss is %s, want %v rounded up", b, maxLoss, bound)
+		}
+
+		a := types.AMM{B: b}
+		var received types.Amount
+		for range 200 {
+			quantity := types.Shares(b/10) + 1
+			received += BuyCost(a, "yes", quantity, payout)
+			a.Yes += quantity
+
+			loss := payout.Times(a.Yes) - received
+			if loss > maxLoss {
+				t.Fatalf("b %v: loses %s selling %d YES, more than MaxLoss %s", b, loss, a.Yes, maxLoss)
+			}
+			if worst := WorstCaseLoss(a, payout); worst > maxLoss {
+				t.Fatalf("b %v: WorstCaseLoss %s selling %d YES, more than MaxLoss %s", b, worst, a.Yes, maxLoss)
+			}
+		}
+		// Far enough out YES is all but certain and the loss nears the bound
+		if loss := payout.Times(a.Yes) - received; float64(loss) < 0.99*bound {
+			t.Errorf("b %v: loses %s selling %d YES, want close to %v", b, loss, a.Yes, bound)
+		}
+	}
+
+	// The loss from both sides is what the worse side pays out, less what
+	// the market maker was paid for both, each payment up to a cent over
+	a := types.AMM{B: 100}
+	received := BuyCost(a, "yes", 150, payout)
+	a.Yes = 150
+	received += BuyCost(a, "no", 60, payout)
+	a.No = 60
+	if worst, want := WorstCaseLoss(a, payout), payout.Times(150)-received; worst < want || worst > want+2 {
+		t.Errorf("WorstCaseLoss is %s, want %s", worst, want)
+	}
+}
+
+func TestRounding(t *testing.T) {
+	a := types.AMM{B: 100, Yes: 17, No: 3}
+	for _, stockType := range []string{"yes", "no"} {
+		for _, quantity := range []types.Shares{1, 7, 33} {
+			yes, no := moved(a, stockType, quantity)
+			exact := (cost(a.B, yes, no) - cost(a.B, a.Yes, a.No)) * float64(payout)
+			if got := BuyCost(a, stockType, quantity, payout); got != types.Amount(math.Ceil(exact)) {
+				t.Errorf("buying %d %s costs %s, want %v rounded up", quantity, stockType, got, exact)
+			}
+
+			yes, no = moved(a, stockType, -quantity)
+			exact = (cost(a.B, a.Yes, a.No) - cost(a.B, yes, no)) * float64(payout)
+			if got := SellProceeds(a, stockType, quantity, payout); got != types.Amount(math.Floor(exact)) {
+				t.Errorf("selling %d %s pays %s, want %v rounded down", quantity, stockType, got, exact)
+			}
+
+			// Buying and selling straight back never makes money
+			after := a
+			after.Yes, after.No = moved(a, stockType, quantity)
+			if paid, back := BuyCost(a, stockType, quantity, payout), SellProceeds(after, stockType, quantity, payout); back > paid {
+				t.Errorf("buying %d %s for %s and selling them back pays %s", quantity, stockType, paid, back)
+			}
+		}
+	}
+
+	// Nor does splitting a buy into single shares make it cheaper
+	whole := BuyCost(a, "yes", 10, payout)
+	var split types.Amount
+	for i := range types.Shares(10) {
+		split += BuyCost(types.AMM{B: a.B, Yes: a.Yes + i, No: a.No}, "yes", 1, payout)
+	}
+	if split < whole {
+		t.Errorf("10 YES one at a time cost %s, less than the %s all at once", split, whole)
+	}
+}
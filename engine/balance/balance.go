@@ -30,7 +30,7 @@ func SetDataStructures(usdBalances types.USDBalances, stockBalances types.StockB
 }
 
 // OnRampUSD adds USD to user balance
-func OnRampUSD(userId string, amount float64) error {
+func OnRampUSD(userId string, amount types.Amount) error {
 	// Find or create balance for user
 	found := false
 	for i := range Balances {
@@ -66,8 +66,7 @@ func OnRampUSD(userId string, amount float64) error {
 		MakerId:         userId,
 		TakerId:         userId,
 		TransectionType: types.DEPOSIT,
-		Quantity:        amount,
-		Price:           100, // USD deposit
+		Amount:          amount, // USD deposit
 		Symbol:          "USD",
 		SymbolStockType: "USD",
 		CreatedAt:       time.Now().Format(time.RFC3339),
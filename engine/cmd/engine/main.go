@@ -9,7 +9,9 @@ import (
 	"time"
 
 	"github.com/adityadeshlahre/probo-v1/engine/balance"
+	"github.com/adityadeshlahre/probo-v1/engine/core"
 	"github.com/adityadeshlahre/probo-v1/engine/database"
+	"github.com/adityadeshlahre/probo-v1/engine/fees"
 	server "github.com/adityadeshlahre/probo-v1/engine/handler"
 	"github.com/adityadeshlahre/probo-v1/engine/market"
 	"github.com/adityadeshlahre/probo-v1/engine/orderbook"
@@ -29,7 +31,8 @@ var Markets []types.Market
 
 var USDBalances types.USDBalances = make(types.USDBalances)
 var StockBalances types.StockBalances = make(types.StockBalances)
-var OrderBook types.YesNoOrderBook = make(types.YesNoOrderBook)
+var OrderBook orderbook.Books = make(orderbook.Books)
+var OrderRegistry orderbook.Registry = make(orderbook.Registry)
 var MarketsMap types.Markets = make(types.Markets)
 
 var engineToDatabaseQueueClient *redis.Client
@@ -40,89 +43,83 @@ var engineResponseSubscriber *redis.Client
 var transectionCounter int = 0
 var EngineAwaitsForResponseMap = make(map[string]chan string)
 
+// addMarketMaker posts sell ladders on both sides of a symbol from its
+// market maker's account, which market.CreateMarket funded with YES/NO pairs
 func addMarketMaker(symbol string) {
 	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	// Add market maker
-	USDBalances["marketmaker"] = types.USDBalance{Balance: 10000, Locked: 0}
-	if _, exists := StockBalances["marketmaker"]; !exists {
-		StockBalances["marketmaker"] = make(types.UserStockBalance)
+	account := types.LPAccount(symbol)
+
+	// Add market maker orders with spread
+	yesPrices := []types.Amount{
+		types.Amount(50+r.Intn(10)) * types.USD,
+		types.Amount(53+r.Intn(10)) * types.USD,
+		types.Amount(55+r.Intn(10)) * types.USD,
+		types.Amount(57+r.Intn(10)) * types.USD,
+		types.Amount(60+r.Intn(10)) * types.USD,
+		types.Amount(62+r.Intn(10)) * types.USD,
+		types.Amount(65+r.Intn(10)) * types.USD,
+		types.Amount(67+r.Intn(10)) * types.USD,
+		types.Amount(70+r.Intn(10)) * types.USD,
+		types.Amount(72+r.Intn(10)) * types.USD,
+		types.Amount(75+r.Intn(10)) * types.USD,
+		types.Amount(77+r.Intn(10)) * types.USD,
+		types.Amount(80+r.Intn(10)) * types.USD,
+		types.Amount(82+r.Intn(10)) * types.USD,
+		types.Amount(85+r.Intn(10)) * types.USD,
+		types.Amount(87+r.Intn(10)) * types.USD,
+		types.Amount(90+r.Intn(10)) * types.USD,
+		types.Amount(92+r.Intn(10)) * types.USD,
+		types.Amount(95+r.Intn(10)) * types.USD,
 	}
-	StockBalances["marketmaker"][symbol] = types.SymbolStockBalance{
-		Yes: types.StockPosition{Quantity: 1000, Locked: 0},
-		No:  types.StockPosition{Quantity: 1000, Locked: 0},
+	noPrices := []types.Amount{
+		types.Amount(5+r.Intn(10)) * types.USD,
+		types.Amount(7+r.Intn(10)) * types.USD,
+		types.Amount(10+r.Intn(10)) * types.USD,
+		types.Amount(12+r.Intn(10)) * types.USD,
+		types.Amount(15+r.Intn(10)) * types.USD,
+		types.Amount(17+r.Intn(10)) * types.USD,
+		types.Amount(20+r.Intn(10)) * types.USD,
+		types.Amount(22+r.Intn(10)) * types.USD,
+		types.Amount(25+r.Intn(10)) * types.USD,
+		types.Amount(27+r.Intn(10)) * types.USD,
+		types.Amount(30+r.Intn(10)) * types.USD,
+		types.Amount(32+r.Intn(10)) * types.USD,
+		types.Amount(35+r.Intn(10)) * types.USD,
+		types.Amount(37+r.Intn(10)) * types.USD,
+		types.Amount(40+r.Intn(10)) * types.USD,
+		types.Amount(42+r.Intn(10)) * types.USD,
+		types.Amount(45+r.Intn(10)) * types.USD,
+		types.Amount(47+r.Intn(10)) * types.USD,
+		types.Amount(50+r.Intn(10)) * types.USD,
 	}
-	// Send marketmaker to database
-	marketmakerUser := types.User{Id: "marketmaker"}
-	userData, _ := json.Marshal(marketmakerUser)
-	userMsg := types.IncomingMessage{Type: types.USER, Data: userData}
-	userBytes, _ := json.Marshal(userMsg)
-	engineToDatabaseQueueClient.Publish(context.Background(), types.DB_ACTIONS, userBytes)
-
-	balanceData := types.Balance{Id: "marketmaker", UserId: "marketmaker", Balance: 10000, Locked: 0}
-	balanceBytes, _ := json.Marshal(balanceData)
-	balanceMsg := types.IncomingMessage{Type: types.BALANCE, Data: balanceBytes}
-	balanceMsgBytes, _ := json.Marshal(balanceMsg)
-	engineToDatabaseQueueClient.Publish(context.Background(), types.DB_ACTIONS, balanceMsgBytes)
-
-	stockData := types.User{Id: "marketmaker", Stock: json.RawMessage(fmt.Sprintf(`{"%s":{"yes":{"quantity":1000,"locked":0},"no":{"quantity":1000,"locked":0}}}`, symbol))}
-	stockBytes, _ := json.Marshal(stockData)
-	stockMsg := types.IncomingMessage{Type: types.STOCK, Data: stockBytes}
-	stockMsgBytes, _ := json.Marshal(stockMsg)
-	engineToDatabaseQueueClient.Publish(context.Background(), types.DB_ACTIONS, stockMsgBytes)
 
-	// Add market maker orders with spread
-	yesPrices := []float64{
-		float64(50 + r.Intn(10)),
-		float64(53 + r.Intn(10)),
-		float64(55 + r.Intn(10)),
-		float64(57 + r.Intn(10)),
-		float64(60 + r.Intn(10)),
-		float64(62 + r.Intn(10)),
-		float64(65 + r.Intn(10)),
-		float64(67 + r.Intn(10)),
-		float64(70 + r.Intn(10)),
-		float64(72 + r.Intn(10)),
-		float64(75 + r.Intn(10)),
-		float64(77 + r.Intn(10)),
-		float64(80 + r.Intn(10)),
-		float64(82 + r.Intn(10)),
-		float64(85 + r.Intn(10)),
-		float64(87 + r.Intn(10)),
-		float64(90 + r.Intn(10)),
-		float64(92 + r.Intn(10)),
-		float64(95 + r.Intn(10)),
+	// The prices above are for the default payout, scale them to the market's contract
+	contract := MarketsMap.Contract(symbol)
+	for i := range yesPrices {
+		yesPrices[i] = scaleToContract(yesPrices[i], contract)
 	}
-	noPrices := []float64{
-		float64(5 + r.Intn(10)),
-		float64(7 + r.Intn(10)),
-		float64(10 + r.Intn(10)),
-		float64(12 + r.Intn(10)),
-		float64(15 + r.Intn(10)),
-		float64(17 + r.Intn(10)),
-		float64(20 + r.Intn(10)),
-		float64(22 + r.Intn(10)),
-		float64(25 + r.Intn(10)),
-		float64(27 + r.Intn(10)),
-		float64(30 + r.Intn(10)),
-		float64(32 + r.Intn(10)),
-		float64(35 + r.Intn(10)),
-		float64(37 + r.Intn(10)),
-		float64(40 + r.Intn(10)),
-		float64(42 + r.Intn(10)),
-		float64(45 + r.Intn(10)),
-		float64(47 + r.Intn(10)),
-		float64(50 + r.Intn(10)),
+	for i := range noPrices {
+		noPrices[i] = scaleToContract(noPrices[i], contract)
 	}
 
-	priceMap := OrderBook[symbol].Yes
-	priceMapNo := OrderBook[symbol].No
+	symbolBook := OrderBook.Symbol(symbol)
 
 	for _, price := range yesPrices {
 		orderId, _ := gonanoid.Generate("0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ", 21)
-		quantity := float64(r.Intn(10) + 1) // 1-10 random
+		quantity := types.Shares(r.Intn(10) + 1) // 1-10 random
+		// Only what the market maker holds can be sold, locked until it fills
+		stocks := StockBalances[account][symbol]
+		quantity = min(quantity, stocks.Yes.Quantity)
+		if quantity == 0 {
+			break
+		}
+		stocks.Yes.Quantity -= quantity
+		stocks.Yes.Locked += quantity
+		StockBalances[account][symbol] = stocks
+
 		order := types.Order{
 			Id:              orderId,
-			UserId:          "marketmaker",
+			UserId:          account,
 			OrderType:       types.SELL,
 			Symbol:          symbol,
 			SymbolStockType: "yes",
@@ -136,23 +133,28 @@ func addMarketMaker(symbol string) {
 		orderData, _ := json.Marshal(order)
 		orderMsg := types.IncomingMessage{Type: "ORDER", Data: orderData}
 		orderMsgBytes, _ := json.Marshal(orderMsg)
-		engineToDatabaseQueueClient.Publish(context.Background(), types.DB_ACTIONS, orderMsgBytes)
+		engineToDatabaseQueueClient.LPush(context.Background(), types.DB_ACTIONS, orderMsgBytes)
 
-		if _, exists := priceMap[price]; !exists {
-			priceMap[price] = types.PriceLevel{Total: 0, Orders: make(map[string]types.OrderBookEntry)}
-		}
-		priceLevel := priceMap[price]
-		priceLevel.Total += quantity
-		priceLevel.Orders[orderId] = types.OrderBookEntry{UserId: "marketmaker", Quantity: quantity, Price: price, Type: "regular"}
-		priceMap[price] = priceLevel
+		symbolBook.Yes.Add(orderId, types.OrderBookEntry{UserId: account, Quantity: quantity, Price: price, Type: "regular"})
+		OrderRegistry.Register(orderbook.OrderRecord{Id: orderId, UserId: account, Symbol: symbol, StockType: "yes", Quantity: quantity, Status: types.PENDING})
 	}
 
 	for _, price := range noPrices {
 		orderId, _ := gonanoid.Generate("0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ", 21)
-		quantity := float64(r.Intn(10) + 1) // 1-10 random
+		quantity := types.Shares(r.Intn(10) + 1) // 1-10 random
+		// Only what the market maker holds can be sold, locked until it fills
+		stocks := StockBalances[account][symbol]
+		quantity = min(quantity, stocks.No.Quantity)
+		if quantity == 0 {
+			break
+		}
+		stocks.No.Quantity -= quantity
+		stocks.No.Locked += quantity
+		StockBalances[account][symbol] = stocks
+
 		order := types.Order{
 			Id:              orderId,
-			UserId:          "marketmaker",
+			UserId:          account,
 			OrderType:       types.SELL,
 			Symbol:          symbol,
 			SymbolStockType: "no",
@@ -166,29 +168,32 @@ func addMarketMaker(symbol string) {
 		orderData, _ := json.Marshal(order)
 		orderMsg := types.IncomingMessage{Type: "ORDER", Data: orderData}
 		orderMsgBytes, _ := json.Marshal(orderMsg)
-		engineToDatabaseQueueClient.Publish(context.Background(), types.DB_ACTIONS, orderMsgBytes)
+		engineToDatabaseQueueClient.LPush(context.Background(), types.DB_ACTIONS, orderMsgBytes)
 
-		if _, exists := priceMapNo[price]; !exists {
-			priceMapNo[price] = types.PriceLevel{Total: 0, Orders: make(map[string]types.OrderBookEntry)}
-		}
-		priceLevel := priceMapNo[price]
-		priceLevel.Total += quantity
-		priceLevel.Orders[orderId] = types.OrderBookEntry{UserId: "marketmaker", Quantity: quantity, Price: price, Type: "regular"}
-		priceMapNo[price] = priceLevel
+		symbolBook.No.Add(orderId, types.OrderBookEntry{UserId: account, Quantity: quantity, Price: price, Type: "regular"})
+		OrderRegistry.Register(orderbook.OrderRecord{Id: orderId, UserId: account, Symbol: symbol, StockType: "no", Quantity: quantity, Status: types.PENDING})
 	}
+}
 
-	OrderBook[symbol] = types.SymbolOrderBook{Yes: priceMap, No: priceMapNo}
+// scaleToContract turns a price for the default payout into the same share of
+// the contract's payout, on its tick
+func scaleToContract(price types.Amount, contract types.ContractSpec) types.Amount {
+	price = price * contract.Payout / types.MaxPrice
+	return price - price%contract.TickSize
 }
 
 func main() {
 	sharedRedis.InitRedis()
+	if err := market.LoadDisputeConfig(); err != nil {
+		log.Fatal(err)
+	}
 
 	// Initialize S3 logger for order book backups
 	if err := s3.InitS3Logger(); err != nil {
 		log.Printf("Failed to initialize S3 logger: %v", err)
 	} else {
 		// Start periodic upload of order book logs to S3
-		s3.StartPeriodicUpload(OrderBook, USDBalances, StockBalances)
+		s3.StartPeriodicUpload(core.TakeSnapshot)
 	}
 
 	e := server.NewServer()
@@ -204,95 +209,125 @@ func main() {
 
 	// Initialize packages with data structures and clients
 	trading.SetClients(engineToDatabaseQueueClient, engineToServerPubSubClient)
-	trading.SetDataStructures(USDBalances, StockBalances, OrderBook)
+	trading.SetDataStructures(USDBalances, StockBalances, OrderBook, OrderRegistry, MarketsMap)
 
 	market.SetClients(engineToDatabaseQueueClient, engineToServerPubSubClient)
-	market.SetDataStructures(USDBalances, StockBalances, OrderBook, MarketsMap, &Orders, &Transections)
+	market.SetDataStructures(USDBalances, StockBalances, OrderBook, OrderRegistry, MarketsMap, &Orders, &Transections)
 
 	balance.SetClients(engineToDatabaseQueueClient)
 	balance.SetDataStructures(USDBalances, StockBalances, &Balances, &Transections)
 
-	orderbook.SetDataStructures(OrderBook)
+	fees.SetClients(engineToDatabaseQueueClient)
+	fees.SetDataStructures(USDBalances, MarketsMap)
+
+	orderbook.SetDataStructures(OrderBook, OrderRegistry)
+	core.SetDataStructures(USDBalances, StockBalances, OrderBook, MarketsMap)
 	database.SetDataStructures(&Orders, &Users, &Balances, &Transections, &Markets, &transectionCounter)
 
-	databaseActionsClient := sharedRedis.GetRedisClient()
-	databasePubsub := databaseActionsClient.Subscribe(context.Background(), types.DB_ACTIONS)
+	// Market makers are funded from the treasury, fees paid into the fee account
+	if err := market.InitTreasury(); err != nil {
+		log.Fatal(err)
+	}
+	if err := fees.LoadConfig(); err != nil {
+		log.Fatal(err)
+	}
+
+	engineResponseSubscriber = sharedRedis.GetRedisClient()
+	engineResponsePubsub := engineResponseSubscriber.Subscribe(context.Background(), types.ENGINE_RESPONSES)
 
 	go func() {
-		for msg := range databasePubsub.Channel() {
-			println("Received message in engine:", msg.Payload)
+		for msg := range engineResponsePubsub.Channel() {
+			// Echoes update balances, so they are applied on the engine goroutine
+			core.Submit(func() { handleEngineResponse(msg.Payload) })
 		}
 	}()
 
-	engineResponseSubscriber = sharedRedis.GetRedisClient()
-	engineResponsePubsub := engineResponseSubscriber.Subscribe(context.Background(), types.ENGINE_RESPONSES)
+	ctx := context.Background()
 
+	// Wake the engine up once a second so GTD orders expire on time even
+	// when no commands arrive
 	go func() {
-		for msg := range engineResponsePubsub.Channel() {
-			var resp types.IncomingMessage
-			if err := json.Unmarshal([]byte(msg.Payload), &resp); err == nil {
-				var key string
-				switch resp.Type {
-				case types.USER:
-					var user types.User
-					if err := json.Unmarshal(resp.Data, &user); err == nil {
-						key = user.Id
-					}
-				case types.BALANCE:
-					var balance types.Balance
-					if err := json.Unmarshal(resp.Data, &balance); err == nil {
-						key = balance.Id
-						// Update USDBalances
-						USDBalances[balance.UserId] = types.USDBalance{
-							Balance: balance.Balance,
-							Locked:  balance.Locked,
-						}
-					}
-				case types.ORDER:
-					var order types.Order
-					if err := json.Unmarshal(resp.Data, &order); err == nil {
-						key = order.Id
-					}
-				case types.MARKET:
-					var market types.Market
-					if err := json.Unmarshal(resp.Data, &market); err == nil {
-						key = market.Id
-					}
-				case types.TRANSECTION:
-					var transection types.Transection
-					if err := json.Unmarshal(resp.Data, &transection); err == nil {
-						key = transection.Id
-					}
-				case "STOCK":
-					var user types.User
-					if err := json.Unmarshal(resp.Data, &user); err == nil {
-						key = user.Id
-					}
-				}
-				if key != "" {
-					if ch, ok := EngineAwaitsForResponseMap[key]; ok {
-						ch <- msg.Payload
-						delete(EngineAwaitsForResponseMap, key)
-					}
-				}
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			core.Submit(func() {
+				now := time.Now()
+				trading.ExpireOrders(now)
+				market.CloseDueMarkets(now)
+				market.FinalizeResolutions(now)
+			})
+		}
+	}()
+
+	// Commands from the server are queued for the engine goroutine in the
+	// order they were popped
+	go func() {
+		for {
+			res, err := engineFromServerQueueClient.BRPop(ctx, 0, types.HTTP_TO_ENGINE).Result()
+			if err != nil {
+				log.Println("Error popping from queue:", err)
+				continue
 			}
-			println("Received response in engine:", msg.Payload)
+			message := []byte(res[1])
+			core.Submit(func() {
+				if err := handleIncomingMessages(message); err != nil {
+					log.Println("Error handling message:", err)
+				}
+			})
 		}
 	}()
 
-	ctx := context.Background()
-	for {
-		res, err := engineFromServerQueueClient.BRPop(ctx, 0, types.HTTP_TO_ENGINE).Result()
-		if err != nil {
-			log.Println("Error popping from queue:", err)
-			continue
+	// This goroutine owns all engine state from here on
+	core.Run(ctx)
+}
+
+// handleEngineResponse handles a message echoed back on ENGINE_RESPONSES
+func handleEngineResponse(payload string) {
+	var resp types.IncomingMessage
+	if err := json.Unmarshal([]byte(payload), &resp); err == nil {
+		var key string
+		switch resp.Type {
+		case types.USER:
+			var user types.User
+			if err := json.Unmarshal(resp.Data, &user); err == nil {
+				key = user.Id
+			}
+		case types.BALANCE:
+			var balance types.Balance
+			if err := json.Unmarshal(resp.Data, &balance); err == nil {
+				// The engine owns balances, the echo only acknowledges the write.
+				// Applying it would undo whatever traded since it was sent.
+				key = balance.Id
+			}
+		case types.ORDER:
+			var order types.Order
+			if err := json.Unmarshal(resp.Data, &order); err == nil {
+				key = order.Id
+			}
+		case types.MARKET:
+			var market types.Market
+			if err := json.Unmarshal(resp.Data, &market); err == nil {
+				key = market.Id
+			}
+		case types.TRANSECTION:
+			var transection types.Transection
+			if err := json.Unmarshal(resp.Data, &transection); err == nil {
+				key = transection.Id
+			}
+		case "STOCK":
+			var user types.User
+			if err := json.Unmarshal(resp.Data, &user); err == nil {
+				key = user.Id
+			}
 		}
-		message := []byte(res[1])
-		err = handleIncomingMessages(message)
-		if err != nil {
-			log.Println("Error handling message:", err)
+		if key != "" {
+			if ch, ok := EngineAwaitsForResponseMap[key]; ok {
+				ch <- payload
+				delete(EngineAwaitsForResponseMap, key)
+			}
 		}
 	}
+	println("Received response in engine:", payload)
 }
 
 func handleIncomingMessages(message []byte) error {
@@ -313,7 +348,7 @@ func handleIncomingMessages(message []byte) error {
 		if err != nil {
 			return err
 		}
-		engineToDatabaseQueueClient.Publish(context.Background(), types.DB_ACTIONS, message).Err()
+		engineToDatabaseQueueClient.LPush(context.Background(), types.DB_ACTIONS, message).Err()
 		return nil
 
 	case string(types.ONRAMP_USD):
@@ -329,7 +364,7 @@ func handleIncomingMessages(message []byte) error {
 		// Send success response
 		responseMsg := types.IncomingMessage{
 			Type: types.ONRAMP_USD,
-			Data: json.RawMessage(fmt.Sprintf(`{"userId":"%s","amount":%f,"status":"success"}`, onRampReq.UserId, onRampReq.Amount)),
+			Data: json.RawMessage(fmt.Sprintf(`{"userId":"%s","amount":"%s","status":"success"}`, onRampReq.UserId, onRampReq.Amount)),
 		}
 		responseBytes, _ := json.Marshal(responseMsg)
 		engineToServerPubSubClient.LPush(context.Background(), "SERVER_RESPONSES_QUEUE", responseBytes).Err()
@@ -345,7 +380,7 @@ func handleIncomingMessages(message []byte) error {
 		if err != nil {
 			return err
 		}
-		engineToDatabaseQueueClient.Publish(context.Background(), types.DB_ACTIONS, message).Err()
+		engineToDatabaseQueueClient.LPush(context.Background(), types.DB_ACTIONS, message).Err()
 		return nil
 
 	case types.USER:
@@ -354,20 +389,35 @@ func handleIncomingMessages(message []byte) error {
 		if err != nil {
 			return err
 		}
+		if err = market.CheckNewUser(user.Id); err != nil {
+			// Send error response
+			errorData, _ := json.Marshal(map[string]interface{}{
+				"status": false,
+				"error":  err.Error(),
+				"id":     user.Id,
+			})
+			responseMsg := types.IncomingMessage{
+				Type: types.USER,
+				Data: errorData,
+			}
+			responseBytes, _ := json.Marshal(responseMsg)
+			engineToServerPubSubClient.LPush(context.Background(), "SERVER_RESPONSES_QUEUE", responseBytes).Err()
+			return err
+		}
 		err = database.CreateOrUpdateUser(user)
 		if err != nil {
 			return err
 		}
 		// Create balance for new user
 		balanceId := user.Id // Use user ID as balance ID for simplicity
-		newBalance := types.Balance{Id: balanceId, UserId: user.Id, Balance: 100, Locked: 0}
+		newBalance := types.Balance{Id: balanceId, UserId: user.Id, Balance: 100 * types.USD, Locked: 0}
 		err = database.CreateOrUpdateBalance(newBalance)
 		if err != nil {
 			return err
 		}
 		// Update in-memory USDBalances immediately
-		USDBalances[user.Id] = types.USDBalance{Balance: 100, Locked: 0}
-		engineToDatabaseQueueClient.Publish(context.Background(), types.DB_ACTIONS, message).Err()
+		USDBalances[user.Id] = types.USDBalance{Balance: 100 * types.USD, Locked: 0}
+		engineToDatabaseQueueClient.LPush(context.Background(), types.DB_ACTIONS, message).Err()
 		engineToServerPubSubClient.LPush(context.Background(), "SERVER_RESPONSES_QUEUE", message).Err()
 		return nil
 
@@ -381,7 +431,7 @@ func handleIncomingMessages(message []byte) error {
 		if err != nil {
 			return err
 		}
-		engineToDatabaseQueueClient.Publish(context.Background(), types.DB_ACTIONS, message).Err()
+		engineToDatabaseQueueClient.LPush(context.Background(), types.DB_ACTIONS, message).Err()
 		return nil
 
 	case types.STOCK:
@@ -394,7 +444,7 @@ func handleIncomingMessages(message []byte) error {
 		if err != nil {
 			return err
 		}
-		engineToDatabaseQueueClient.Publish(context.Background(), types.DB_ACTIONS, message).Err()
+		engineToDatabaseQueueClient.LPush(context.Background(), types.DB_ACTIONS, message).Err()
 		return nil
 
 	case types.TRANSECTION:
@@ -407,7 +457,7 @@ func handleIncomingMessages(message []byte) error {
 		if err != nil {
 			return err
 		}
-		engineToDatabaseQueueClient.Publish(context.Background(), types.DB_ACTIONS, message).Err()
+		engineToDatabaseQueueClient.LPush(context.Background(), types.DB_ACTIONS, message).Err()
 		return nil
 
 	case types.GET_ORDER_BOOK:
@@ -464,20 +514,35 @@ func handleIncomingMessages(message []byte) error {
 		}
 		return nil
 
-	case types.CANCLE_ORDER:
+	case types.CANCEL_ORDER, types.CANCLE_ORDER:
 		var cancelReq types.CancelOrderProps
 		err = json.Unmarshal(msg.Data, &cancelReq)
 		if err != nil {
 			return err
 		}
-		err = trading.CancelOrder(cancelReq)
+		result, err := trading.CancelOrder(cancelReq)
 		if err != nil {
+			// Send error response
+			errorData, _ := json.Marshal(map[string]interface{}{
+				"status":  false,
+				"error":   err.Error(),
+				"orderId": cancelReq.OrderId,
+				"userId":  cancelReq.UserId,
+			})
+			responseMsg := types.IncomingMessage{
+				Type: types.CANCEL_ORDER,
+				Data: errorData,
+			}
+			responseBytes, _ := json.Marshal(responseMsg)
+			engineToServerPubSubClient.LPush(context.Background(), "SERVER_RESPONSES_QUEUE", responseBytes).Err()
 			return err
 		}
 		// Send success response
+		result["userId"] = cancelReq.UserId
+		resultData, _ := json.Marshal(result)
 		responseMsg := types.IncomingMessage{
-			Type: types.CANCLE_ORDER,
-			Data: json.RawMessage(fmt.Sprintf(`{"orderId":"%s","status":"cancelled"}`, cancelReq.OrderId)),
+			Type: types.CANCEL_ORDER,
+			Data: resultData,
 		}
 		responseBytes, _ := json.Marshal(responseMsg)
 		engineToServerPubSubClient.LPush(context.Background(), "SERVER_RESPONSES_QUEUE", responseBytes).Err()
@@ -485,26 +550,52 @@ func handleIncomingMessages(message []byte) error {
 
 	case types.END_MARKET:
 		var endReq struct {
-			StockSymbol  string `json:"stockSymbol"`
-			MarketId     string `json:"marketId"`
-			WinningStock string `json:"winningStock"`
+			StockSymbol  string   `json:"stockSymbol"`
+			MarketId     string   `json:"marketId"`
+			WinningStock string   `json:"winningStock"`
+			Value        *float64 `json:"value"` // scalar markets settle on a value instead
 		}
 		err = json.Unmarshal(msg.Data, &endReq)
 		if err != nil {
 			return err
 		}
-		err = market.EndMarket(endReq.StockSymbol, endReq.WinningStock)
+		var response map[string]interface{}
+		switch {
+		case MarketsMap[endReq.StockSymbol].Type == types.MarketManual:
+			// Manual markets settle through the dispute workflow instead
+			err = fmt.Errorf("market %s is manual, propose its outcome instead", endReq.StockSymbol)
+		case endReq.Value != nil:
+			var settlement types.Settlement
+			settlement, err = market.EndScalarMarket(endReq.StockSymbol, *endReq.Value)
+			response = map[string]interface{}{
+				"status":  "ended",
+				"value":   settlement.Value,
+				"payouts": settlement.Payouts,
+			}
+		default:
+			err = market.EndMarket(endReq.StockSymbol, endReq.WinningStock)
+			response = map[string]interface{}{
+				"status": "ended",
+				"winner": endReq.WinningStock,
+			}
+		}
 		if err != nil {
-			return err
+			// Send error response, so whoever ended the market isn't left waiting
+			response = map[string]interface{}{
+				"status": false,
+				"error":  err.Error(),
+			}
 		}
-		// Send success response
+		response["marketId"] = endReq.MarketId
+		response["stockSymbol"] = endReq.StockSymbol
+		responseData, _ := json.Marshal(response)
 		responseMsg := types.IncomingMessage{
 			Type: types.END_MARKET,
-			Data: json.RawMessage(fmt.Sprintf(`{"marketId":"%s","status":"ended","winner":"%s"}`, endReq.MarketId, endReq.WinningStock)),
+			Data: responseData,
 		}
 		responseBytes, _ := json.Marshal(responseMsg)
 		engineToServerPubSubClient.LPush(context.Background(), "SERVER_RESPONSES_QUEUE", responseBytes).Err()
-		return nil
+		return err
 
 	case types.BUY_ORDER:
 		var orderProps types.OrderProps
@@ -562,6 +653,183 @@ func handleIncomingMessages(message []byte) error {
 		engineToServerPubSubClient.LPush(context.Background(), "SERVER_RESPONSES_QUEUE", responseBytes).Err()
 		return nil
 
+	case types.MARKET_STATUS:
+		var statusReq types.MarketStatusProps
+		err = json.Unmarshal(msg.Data, &statusReq)
+		if err != nil {
+			return err
+		}
+		result, err := market.SetMarketStatus(statusReq)
+		if err != nil {
+			// Send error response
+			result = map[string]interface{}{
+				"status": false,
+				"error":  err.Error(),
+			}
+		}
+		result["stockSymbol"] = statusReq.StockSymbol
+		resultData, _ := json.Marshal(result)
+		responseMsg := types.IncomingMessage{
+			Type: types.MARKET_STATUS,
+			Data: resultData,
+		}
+		responseBytes, _ := json.Marshal(responseMsg)
+		engineToServerPubSubClient.LPush(context.Background(), "SERVER_RESPONSES_QUEUE", responseBytes).Err()
+		return err
+
+	case types.SPLIT_SET, types.MERGE_SET:
+		var setReq types.CompleteSetProps
+		err = json.Unmarshal(msg.Data, &setReq)
+		if err != nil {
+			return err
+		}
+		var result map[string]interface{}
+		if msg.Type == types.SPLIT_SET {
+			result, err = market.SplitSet(setReq)
+		} else {
+			result, err = market.MergeSet(setReq)
+		}
+		if err != nil {
+			// Send error response
+			result = map[string]interface{}{
+				"status": false,
+				"error":  err.Error(),
+				"userId": setReq.UserId,
+			}
+		}
+		resultData, _ := json.Marshal(result)
+		responseMsg := types.IncomingMessage{
+			Type: msg.Type,
+			Data: resultData,
+		}
+		responseBytes, _ := json.Marshal(responseMsg)
+		engineToServerPubSubClient.LPush(context.Background(), "SERVER_RESPONSES_QUEUE", responseBytes).Err()
+		return err
+
+	case types.PROPOSE_RESOLUTION, types.DISPUTE_RESOLUTION, types.VOTE_RESOLUTION:
+		var stockSymbol, userId string
+		var resolution *types.Resolution
+		switch msg.Type {
+		case types.PROPOSE_RESOLUTION:
+			var proposeReq types.ProposeResolutionProps
+			if err = json.Unmarshal(msg.Data, &proposeReq); err != nil {
+				return err
+			}
+			stockSymbol, userId = proposeReq.StockSymbol, proposeReq.UserId
+			resolution, err = market.ProposeResolution(proposeReq)
+		case types.DISPUTE_RESOLUTION:
+			var disputeReq types.DisputeResolutionProps
+			if err = json.Unmarshal(msg.Data, &disputeReq); err != nil {
+				return err
+			}
+			stockSymbol, userId = disputeReq.StockSymbol, disputeReq.UserId
+			resolution, err = market.DisputeResolution(disputeReq)
+		case types.VOTE_RESOLUTION:
+			var voteReq types.VoteResolutionProps
+			if err = json.Unmarshal(msg.Data, &voteReq); err != nil {
+				return err
+			}
+			stockSymbol, userId = voteReq.StockSymbol, voteReq.AdminId
+			resolution, err = market.VoteResolution(voteReq)
+		}
+		result := map[string]interface{}{
+			"stockSymbol": stockSymbol,
+			"userId":      userId,
+		}
+		if err != nil {
+			// Send error response
+			result["status"] = false
+			result["error"] = err.Error()
+		} else {
+			result["status"] = true
+			result["resolution"] = resolution
+		}
+		resultData, _ := json.Marshal(result)
+		responseMsg := types.IncomingMessage{
+			Type: msg.Type,
+			Data: resultData,
+		}
+		responseBytes, _ := json.Marshal(responseMsg)
+		engineToServerPubSubClient.LPush(context.Background(), "SERVER_RESPONSES_QUEUE", responseBytes).Err()
+		return err
+
+	case types.AMM_QUOTE:
+		var quoteReq types.AMMQuoteProps
+		err = json.Unmarshal(msg.Data, &quoteReq)
+		if err != nil {
+			return err
+		}
+		result, err := trading.QuoteAMM(quoteReq)
+		if err != nil {
+			// Send error response
+			result = map[string]interface{}{
+				"status": false,
+				"error":  err.Error(),
+			}
+		}
+		result["stockSymbol"] = quoteReq.StockSymbol
+		resultData, _ := json.Marshal(result)
+		responseMsg := types.IncomingMessage{
+			Type: types.AMM_QUOTE,
+			Data: resultData,
+		}
+		responseBytes, _ := json.Marshal(responseMsg)
+		engineToServerPubSubClient.LPush(context.Background(), "SERVER_RESPONSES_QUEUE", responseBytes).Err()
+		return err
+
+	case types.LP_REPORT:
+		var reportReq struct {
+			StockSymbol string `json:"stockSymbol"`
+		}
+		err = json.Unmarshal(msg.Data, &reportReq)
+		if err != nil {
+			return err
+		}
+		result, err := market.LPReport(reportReq.StockSymbol)
+		if err != nil {
+			// Send error response
+			result = map[string]interface{}{
+				"status":      false,
+				"stockSymbol": reportReq.StockSymbol,
+				"error":       err.Error(),
+			}
+		}
+		resultData, _ := json.Marshal(result)
+		responseMsg := types.IncomingMessage{
+			Type: types.LP_REPORT,
+			Data: resultData,
+		}
+		responseBytes, _ := json.Marshal(responseMsg)
+		engineToServerPubSubClient.LPush(context.Background(), "SERVER_RESPONSES_QUEUE", responseBytes).Err()
+		return err
+
+	case types.FEE_REPORT:
+		var reportReq struct {
+			Date string `json:"date"`
+		}
+		err = json.Unmarshal(msg.Data, &reportReq)
+		if err != nil {
+			return err
+		}
+		result, err := fees.Report(reportReq.Date)
+		if err != nil {
+			// Send error response
+			result = map[string]interface{}{
+				"status": false,
+				"error":  err.Error(),
+			}
+		}
+		// The server waits on the date it asked for
+		result["requestedDate"] = reportReq.Date
+		resultData, _ := json.Marshal(result)
+		responseMsg := types.IncomingMessage{
+			Type: types.FEE_REPORT,
+			Data: resultData,
+		}
+		responseBytes, _ := json.Marshal(responseMsg)
+		engineToServerPubSubClient.LPush(context.Background(), "SERVER_RESPONSES_QUEUE", responseBytes).Err()
+		return err
+
 	case types.CREATE_MARKET:
 		var createReq types.CreateMarket
 		err = json.Unmarshal(msg.Data, &createReq)
@@ -570,10 +838,25 @@ func handleIncomingMessages(message []byte) error {
 		}
 		err = market.CreateMarket(createReq)
 		if err != nil {
+			// Keyed by symbol like the success response, so the server stops waiting
+			errData, _ := json.Marshal(map[string]interface{}{
+				createReq.Symbol: map[string]string{"status": "failed", "error": err.Error()},
+			})
+			responseMsg := types.IncomingMessage{
+				Type: types.CREATE_MARKET,
+				Data: errData,
+			}
+			responseBytes, _ := json.Marshal(responseMsg)
+			engineToServerPubSubClient.LPush(context.Background(), "SERVER_RESPONSES_QUEUE", responseBytes).Err()
 			return err
 		}
-		// Add market maker
-		addMarketMaker(createReq.Symbol)
+		// Add market maker, to every outcome of a categorical market, unless
+		// the market has an LMSR market maker instead
+		if createReq.AMM == nil {
+			for _, symbol := range market.TradedSymbols(createReq.Symbol) {
+				addMarketMaker(symbol)
+			}
+		}
 
 		// Send success response
 		responseMsg := types.IncomingMessage{
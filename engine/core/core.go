@@ -0,0 +1,107 @@
+package core
+
+import (
+	"context"
+
+	"github.com/adityadeshlahre/probo-v1/engine/orderbook"
+	types "github.com/adityadeshlahre/probo-v1/shared/types"
+)
+
+// The engine's state (balances, order books, markets) is owned by a single
+// goroutine, the one running Run. Everything else hands it work through
+// Submit, Do or Query instead of touching the maps itself, so commands are
+// applied one at a time in arrival order and nothing needs a lock.
+
+// Command is a unit of work run on the engine goroutine
+type Command func()
+
+var commands = make(chan Command, 1024)
+
+// Import these from main (will need to be passed or made accessible)
+var USDBalances types.USDBalances
+var StockBalances types.StockBalances
+var OrderBook orderbook.Books
+var MarketsMap types.Markets
+
+// SetDataStructures sets references to shared data structures
+func SetDataStructures(usdBalances types.USDBalances, stockBalances types.StockBalances, orderBook orderbook.Books, marketsMap types.Markets) {
+	USDBalances = usdBalances
+	StockBalances = stockBalances
+	OrderBook = orderBook
+	MarketsMap = marketsMap
+}
+
+// Run applies commands one at a time until ctx is done. Only one goroutine
+// may run it.
+func Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case command := <-commands:
+			command()
+		}
+	}
+}
+
+// Submit queues a command for the engine goroutine without waiting for it.
+// It blocks while the queue is full.
+func Submit(command Command) {
+	commands <- command
+}
+
+// Do runs a command on the engine goroutine and waits for it to finish. It
+// must not be called from a command, which would wait on itself.
+func Do(command Command) {
+	done := make(chan struct{})
+	commands <- func() {
+		defer close(done)
+		command()
+	}
+	<-done
+}
+
+// Query runs fn on the engine goroutine and returns its result. The result
+// must not share maps or pointers with engine state.
+func Query[T any](fn func() T) T {
+	var result T
+	Do(func() {
+		result = fn()
+	})
+	return result
+}
+
+// Snapshot is a consistent copy of the engine's state at one point in time
+type Snapshot struct {
+	OrderBook     types.YesNoOrderBook `json:"orderBook"`
+	USDBalances   types.USDBalances    `json:"usdBalances"`
+	StockBalances types.StockBalances  `json:"stockBalances"`
+	Markets       types.Markets        `json:"markets"`
+}
+
+// TakeSnapshot copies the engine's state on the engine goroutine, so the copy
+// is safe to read, marshal or upload from anywhere
+func TakeSnapshot() Snapshot {
+	return Query(func() Snapshot {
+		snapshot := Snapshot{
+			OrderBook:     OrderBook.Snapshot(),
+			USDBalances:   make(types.USDBalances, len(USDBalances)),
+			StockBalances: make(types.StockBalances, len(StockBalances)),
+			Markets:       make(types.Markets, len(MarketsMap)),
+		}
+		for userId, balance := range USDBalances {
+			snapshot.USDBalances[userId] = balance
+		}
+		for userId, userStocks := range StockBalances {
+			stocks := make(types.UserStockBalance, len(userStocks))
+			for symbol, symbolStocks := range userStocks {
+				stocks[symbol] = symbolStocks
+			}
+			snapshot.StockBalances[userId] = stocks
+		}
+		for symbol, market := range MarketsMap {
+			snapshot.Markets[symbol] = market.Clone()
+		}
+		return snapshot
+	})
+}
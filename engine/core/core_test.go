@@ -0,0 +1,258 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/adityadeshlahre/probo-v1/engine/orderbook"
+	"github.com/adityadeshlahre/probo-v1/engine/trading"
+	types "github.com/adityadeshlahre/probo-v1/shared/types"
+	"github.com/redis/go-redis/v9"
+)
+
+// TestConcurrentLoad drives the engine from many goroutines at once, the way
+// the queue reader, the expiry ticker, the S3 uploader and the debug
+// endpoints do, and checks nothing was lost. Run it with -race:
+//
+//	go test -race ./core
+func TestConcurrentLoad(t *testing.T) {
+	const (
+		traders         = 8
+		ordersPerTrader = 250
+		readers         = 4
+		startingBalance = 1000 * types.USD
+		symbol          = "RACE"
+	)
+
+	// Redis is never reachable, publishing fails straight away
+	offline := redis.NewClient(&redis.Options{
+		Dialer: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return nil, errors.New("offline")
+		},
+		MaxRetries: -1,
+	})
+	usdBalances := make(types.USDBalances)
+	stockBalances := make(types.StockBalances)
+	orderBook := make(orderbook.Books)
+	orderRegistry := make(orderbook.Registry)
+	markets := types.Markets{symbol: {StockSymbol: symbol, Status: types.MarketOpen}}
+	trading.SetClients(offline, offline)
+	trading.SetDataStructures(usdBalances, stockBalances, orderBook, orderRegistry, markets)
+	orderbook.SetDataStructures(orderBook, orderRegistry)
+	SetDataStructures(usdBalances, stockBalances, orderBook, markets)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Run(ctx)
+
+	userIds := make([]string, traders)
+	Do(func() {
+		for i := range userIds {
+			userIds[i] = fmt.Sprintf("trader-%d", i)
+			usdBalances[userIds[i]] = types.USDBalance{Balance: startingBalance}
+		}
+	})
+
+	var tradersDone sync.WaitGroup
+	for i, userId := range userIds {
+		tradersDone.Add(1)
+		go func() {
+			defer tradersDone.Done()
+			r := rand.New(rand.NewSource(int64(i)))
+			var placed []string
+
+			for range ordersPerTrader {
+				props := types.OrderProps{
+					UserId:      userId,
+					StockSymbol: symbol,
+					Quantity:    types.Shares(1 + r.Intn(3)),
+					Price:       types.Amount(30+r.Intn(41)) * types.USD,
+					StockType:   []string{"yes", "no"}[r.Intn(2)],
+				}
+				if r.Intn(5) == 0 {
+					props.TimeInForce = types.GoodTillDate
+					props.ExpiresAt = time.Now().Add(time.Duration(1+r.Intn(20)) * time.Millisecond).UnixMilli()
+				}
+
+				var result map[string]interface{}
+				switch r.Intn(4) {
+				case 0, 1:
+					Do(func() { result, _ = trading.PlaceBuyOrder(props) })
+				case 2:
+					Do(func() { result, _ = trading.PlaceSellOrder(props) })
+				case 3:
+					if len(placed) > 0 {
+						orderId := placed[r.Intn(len(placed))]
+						Do(func() {
+							trading.CancelOrder(types.CancelOrderProps{UserId: userId, OrderId: orderId})
+						})
+					}
+				}
+				if orderId, ok := result["orderId"].(string); ok {
+					placed = append(placed, orderId)
+				}
+			}
+		}()
+	}
+
+	stop := make(chan struct{})
+	var readersDone sync.WaitGroup
+	for range readers {
+		readersDone.Add(1)
+		go func() {
+			defer readersDone.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if _, err := json.Marshal(TakeSnapshot()); err != nil {
+					t.Errorf("marshal snapshot: %v", err)
+					return
+				}
+			}
+		}()
+	}
+
+	readersDone.Add(1)
+	go func() {
+		defer readersDone.Done()
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				Submit(func() { trading.ExpireOrders(time.Now()) })
+			}
+		}
+	}()
+
+	tradersDone.Wait()
+	close(stop)
+	readersDone.Wait()
+
+	snapshot := TakeSnapshot()
+
+	// Money only turns into shares when a YES/NO pair is minted for MaxPrice,
+	// so cash plus the pairs held is what the traders started with
+	var cash, locked, cost types.Amount
+	var yes, no, lockedYes, lockedNo types.Shares
+	for _, userId := range userIds {
+		balance := snapshot.USDBalances[userId]
+		if balance.Balance < 0 || balance.Locked < 0 {
+			t.Errorf("%s has a negative balance %+v", userId, balance)
+		}
+		cash += balance.Balance + balance.Locked
+		locked += balance.Locked
+
+		stocks := snapshot.StockBalances[userId][symbol]
+		if stocks.Yes.Quantity < 0 || stocks.Yes.Locked < 0 || stocks.No.Quantity < 0 || stocks.No.Locked < 0 {
+			t.Errorf("%s has negative stocks %+v", userId, stocks)
+		}
+		yes += stocks.Yes.Quantity + stocks.Yes.Locked
+		no += stocks.No.Quantity + stocks.No.Locked
+		lockedYes += stocks.Yes.Locked
+		lockedNo += stocks.No.Locked
+		cost += stocks.Cost()
+	}
+
+	t.Logf("%d pairs held, %s locked in resting buys", yes, locked)
+	if yes != no {
+		t.Errorf("%d YES shares but %d NO shares", yes, no)
+	}
+	if want := startingBalance.Times(traders); cash+types.MaxPrice.Times(yes) != want {
+		t.Errorf("cash %s plus %d pairs doesn't add up to %s", cash, yes, want)
+	}
+	// and what the traders paid for their stocks is what the pairs are worth,
+	// so voiding the market could refund everyone
+	if cost != types.MaxPrice.Times(yes) {
+		t.Errorf("stocks cost %s but %d pairs are worth %s", cost, yes, types.MaxPrice.Times(yes))
+	}
+
+	// Whatever is locked is exactly what resting orders hold
+	var restingCash types.Amount
+	var restingYes, restingNo types.Shares
+	for stockType, priceMap := range map[string]types.PriceOrderBook{"yes": snapshot.OrderBook[symbol].Yes, "no": snapshot.OrderBook[symbol].No} {
+		for _, level := range priceMap {
+			for _, entry := range level.Orders {
+				switch {
+				case entry.Type == "reverted":
+					restingCash += (types.MaxPrice - entry.Price).Times(entry.Quantity)
+				case stockType == "yes":
+					restingYes += entry.Quantity
+				default:
+					restingNo += entry.Quantity
+				}
+			}
+		}
+	}
+	if locked != restingCash {
+		t.Errorf("%s locked but resting buy orders hold %s", locked, restingCash)
+	}
+	if lockedYes != restingYes || lockedNo != restingNo {
+		t.Errorf("%d/%d YES/NO shares locked but resting sell orders hold %d/%d", lockedYes, lockedNo, restingYes, restingNo)
+	}
+}
+
+// TestSnapshotIsACopy changes everything a market points to in place after
+// taking a snapshot, the way transitions, disputes and trades do, and checks
+// the snapshot kept what it copied
+func TestSnapshotIsACopy(t *testing.T) {
+	const symbol = "COPY"
+	proposed, voted, decided := 1.5, 2.5, 3.5
+	markets := types.Markets{symbol: {
+		StockSymbol: symbol,
+		Status:      types.MarketClosed,
+		Transitions: []types.MarketTransition{{From: types.MarketOpen, To: types.MarketClosed}},
+		Outcomes:    []string{"red", "blue"},
+		Scalar:      &types.ScalarRange{Lower: 0, Upper: 10},
+		Resolution: &types.Resolution{
+			Status:   types.ResolutionDisputed,
+			Proposal: types.Proposal{UserId: "resolver", Value: &proposed},
+			Disputes: []types.Dispute{{UserId: "disputer", Reason: "wrong"}},
+			Votes:    []types.Vote{{AdminId: "admin", Outcome: "yes", Value: &voted}},
+			Decision: &types.Decision{By: "admins", Value: &decided, Admins: []string{"admin"}},
+		},
+		AMM: &types.AMM{Account: types.AMMAccount(symbol), B: 100, Yes: 5},
+		LP:  &types.LiquidityProvider{Account: types.LPAccount(symbol), Subsidy: 100 * types.USD},
+	}}
+	SetDataStructures(make(types.USDBalances), make(types.StockBalances), make(orderbook.Books), markets)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Run(ctx)
+
+	snapshot := TakeSnapshot()
+	taken, _ := json.Marshal(snapshot.Markets[symbol])
+	Do(func() {
+		market := markets[symbol]
+		market.Transitions[0].To = types.MarketVoided
+		market.Outcomes[0] = "green"
+		market.Scalar.Upper = 20
+		resolution := market.Resolution
+		resolution.Status = types.ResolutionFinal
+		*resolution.Proposal.Value = 9
+		resolution.Disputes[0].Reason = "changed"
+		resolution.Votes[0].Outcome = "no"
+		*resolution.Votes[0].Value = 9
+		resolution.Decision.By = "undisputed"
+		*resolution.Decision.Value = 9
+		resolution.Decision.Admins[0] = "someone else"
+		market.AMM.Yes += 10
+		market.LP.DefundedAt = "now"
+	})
+
+	if after, _ := json.Marshal(snapshot.Markets[symbol]); string(after) != string(taken) {
+		t.Errorf("snapshot's market changed with the engine's:\n%s\nwas\n%s", after, taken)
+	}
+}
@@ -109,10 +109,15 @@ func CreateOrUpdateMarket(data types.Market) error {
 
 // CreateTransection creates a new transaction
 func CreateTransection(data types.Transection) error {
-	data.Id = fmt.Sprintf("transection%d", TransectionCounter)
-	TransectionCounter++
-	data.CreatedAt = time.Now().Format(time.RFC3339)
-	data.UpdatedAt = data.CreatedAt
+	// Trades come with the id the engine gave them
+	if data.Id == "" {
+		data.Id = fmt.Sprintf("transection%d", TransectionCounter)
+		TransectionCounter++
+	}
+	if data.CreatedAt == "" {
+		data.CreatedAt = time.Now().Format(time.RFC3339)
+	}
+	data.UpdatedAt = time.Now().Format(time.RFC3339)
 	Transections = append(Transections, data)
 	return nil
 }
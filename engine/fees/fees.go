@@ -0,0 +1,303 @@
+package fees
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	types "github.com/adityadeshlahre/probo-v1/shared/types"
+	"github.com/redis/go-redis/v9"
+)
+
+// Every fill charges its maker and taker the trading fees of their schedule,
+// and every settlement charges winnings the settlement fee. Fees are paid into
+// the fee account and added to the day's revenue, by market. The platform's
+// own accounts pay no fees.
+
+var engineToDatabaseQueueClient *redis.Client
+
+// SetClients sets the Redis clients for fee operations
+func SetClients(dbClient *redis.Client) {
+	engineToDatabaseQueueClient = dbClient
+}
+
+var USDBalances types.USDBalances
+var MarketsMap types.Markets
+
+// SetDataStructures sets references to shared data structures
+func SetDataStructures(usdBalances types.USDBalances, marketsMap types.Markets) {
+	USDBalances = usdBalances
+	MarketsMap = marketsMap
+}
+
+// config is the fee schedules in force, no fees at all until LoadConfig
+var config types.FeeConfig
+
+// Revenue is the fees collected in a day, or in a market in a day
+type Revenue struct {
+	Maker      types.Amount `json:"maker"`
+	Taker      types.Amount `json:"taker"`
+	Settlement types.Amount `json:"settlement"`
+	Total      types.Amount `json:"total"`
+	Trades     int          `json:"trades"` // fills that paid a fee
+}
+
+func (r *Revenue) add(maker, taker, settlement types.Amount) {
+	r.Maker += maker
+	r.Taker += taker
+	r.Settlement += settlement
+	r.Total += maker + taker + settlement
+	if maker+taker > 0 {
+		r.Trades++
+	}
+}
+
+// dayRevenue is a day's revenue with its split by market
+type dayRevenue struct {
+	Revenue
+	markets map[string]*Revenue
+}
+
+// revenue by UTC date, YYYY-MM-DD
+var revenue = map[string]*dayRevenue{}
+
+// redis hash revenue is saved in, each market's revenue of a day under
+// "<date> <symbol>", so the report survives restarts
+const FEE_REVENUE = "FEE_REVENUE"
+
+// LoadConfig reads the fee schedules from the JSON file FEE_CONFIG names,
+// see fees.example.json, creates the fee account and loads the revenue saved
+// so far. Without a config nothing is charged.
+func LoadConfig() error {
+	if path := os.Getenv("FEE_CONFIG"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("invalid FEE_CONFIG: %v", err)
+		}
+		var loaded types.FeeConfig
+		if err := json.Unmarshal(data, &loaded); err != nil {
+			return fmt.Errorf("invalid FEE_CONFIG %s: %v", path, err)
+		}
+		if err := loaded.Validate(); err != nil {
+			return fmt.Errorf("invalid FEE_CONFIG %s: %v", path, err)
+		}
+		SetConfig(loaded)
+	}
+
+	if _, exists := USDBalances[types.FeeAccount]; !exists {
+		USDBalances[types.FeeAccount] = types.USDBalance{}
+		userData, _ := json.Marshal(types.User{Id: types.FeeAccount})
+		userMsg := types.IncomingMessage{Type: types.USER, Data: userData}
+		userBytes, _ := json.Marshal(userMsg)
+		engineToDatabaseQueueClient.LPush(context.Background(), types.DB_ACTIONS, userBytes)
+	}
+	if err := loadRevenue(); err != nil {
+		return err
+	}
+	fmt.Printf("Fees: default %+v, %d schedules, %d tiers\n", config.Default, len(config.Schedules), len(config.Tiers))
+	return nil
+}
+
+// SetConfig sets the fee schedules in force
+func SetConfig(feeConfig types.FeeConfig) {
+	config = feeConfig
+}
+
+// CheckSchedule returns an error unless a market can use the schedule id,
+// empty for the default
+func CheckSchedule(id string) error {
+	if _, exists := config.Schedules[id]; id != "" && !exists {
+		return fmt.Errorf("fee schedule %s doesn't exist", id)
+	}
+	return nil
+}
+
+// Schedule returns the fee schedule a user trades a symbol under: their
+// tier's, or else the one the market's contract names, or else the default
+func Schedule(userId, stockSymbol string) types.FeeSchedule {
+	if tier, exists := config.Users[userId]; exists {
+		return config.Tiers[tier]
+	}
+	if schedule, exists := config.Schedules[MarketsMap.Contract(stockSymbol).FeeSchedule]; exists {
+		return schedule
+	}
+	return config.Default
+}
+
+// TakerFee is the most a taker can pay for quantity shares worth value
+func TakerFee(userId, stockSymbol string, value types.Amount, quantity types.Shares) types.Amount {
+	if types.PlatformAccount(userId) {
+		return 0
+	}
+	return Schedule(userId, stockSymbol).TradeFee(false, value, quantity)
+}
+
+// MakerFee is the most a maker can pay for quantity shares worth value,
+// reserved with the order when it rests
+func MakerFee(userId, stockSymbol string, value types.Amount, quantity types.Shares) types.Amount {
+	if types.PlatformAccount(userId) {
+		return 0
+	}
+	return Schedule(userId, stockSymbol).TradeFee(true, value, quantity)
+}
+
+// ChargeTrade charges the maker and taker of a fill their fees and sets
+// them on the fill. The maker of a mint or burn traded the other side, at
+// payout - price. The maker's fee comes out of what its order reserved, and
+// what the fill doesn't need of that goes back to its balance.
+func ChargeTrade(takerId, stockSymbol string, fill *types.Fill) {
+	takerValue := fill.Price.Times(fill.Quantity)
+	makerValue := takerValue
+	if fill.Type == "mint" || fill.Type == "burn" {
+		makerValue = (MarketsMap.Contract(stockSymbol).Payout - fill.Price).Times(fill.Quantity)
+	}
+
+	if !types.PlatformAccount(takerId) {
+		fill.TakerFee = charge(takerId, Schedule(takerId, stockSymbol).TradeFee(false, takerValue, fill.Quantity))
+	}
+	if !types.PlatformAccount(fill.UserId) {
+		fill.MakerFee = chargeReserved(fill.UserId, Schedule(fill.UserId, stockSymbol).TradeFee(true, makerValue, fill.Quantity), fill.Reserved)
+	}
+	record(stockSymbol, fill.MakerFee, fill.TakerFee, 0)
+}
+
+// ChargeSettlement charges a user's winnings in a symbol the settlement fee
+// and returns what was charged
+func ChargeSettlement(userId, stockSymbol string, winnings types.Amount) types.Amount {
+	if types.PlatformAccount(userId) {
+		return 0
+	}
+	fee := charge(userId, Schedule(userId, stockSymbol).SettlementFee(winnings))
+	record(stockSymbol, 0, 0, fee)
+	return fee
+}
+
+// charge moves a fee from a user's free balance to the fee account and
+// returns what was moved, all of their balance if that's less
+func charge(userId string, fee types.Amount) types.Amount {
+	if fee <= 0 {
+		return 0
+	}
+	balance := USDBalances[userId]
+	if balance.Balance < fee {
+		fmt.Printf("fees: %s owes %s but only has %s\n", userId, fee, balance.Balance)
+		fee = max(balance.Balance, 0)
+	}
+	balance.Balance -= fee
+	USDBalances[userId] = balance
+	pay(fee)
+	return fee
+}
+
+// chargeReserved charges a maker's fee out of the reserved part of their
+// locked balance, unlocking what the fee doesn't take. Should the fee come to
+// more, rounding up a fill at a time, the rest comes out of the free balance.
+func chargeReserved(userId string, fee, reserved types.Amount) types.Amount {
+	if reserved == 0 {
+		return charge(userId, fee)
+	}
+	fromReserve := max(min(fee, reserved), 0)
+	balance := USDBalances[userId]
+	balance.Locked -= reserved
+	balance.Balance += reserved - fromReserve
+	USDBalances[userId] = balance
+	pay(fromReserve)
+	return fromReserve + charge(userId, fee-fromReserve)
+}
+
+// pay adds a fee to the fee account
+func pay(fee types.Amount) {
+	feeBalance := USDBalances[types.FeeAccount]
+	feeBalance.Balance += fee
+	USDBalances[types.FeeAccount] = feeBalance
+}
+
+// record adds fees to today's revenue
+func record(stockSymbol string, maker, taker, settlement types.Amount) {
+	if maker+taker+settlement == 0 {
+		return
+	}
+	date := time.Now().UTC().Format(time.DateOnly)
+	day, exists := revenue[date]
+	if !exists {
+		day = &dayRevenue{markets: map[string]*Revenue{}}
+		revenue[date] = day
+	}
+	market, exists := day.markets[stockSymbol]
+	if !exists {
+		market = &Revenue{}
+		day.markets[stockSymbol] = market
+	}
+	day.add(maker, taker, settlement)
+	market.add(maker, taker, settlement)
+
+	marketData, _ := json.Marshal(market)
+	engineToDatabaseQueueClient.HSet(context.Background(), FEE_REVENUE, date+" "+stockSymbol, marketData)
+}
+
+// loadRevenue rebuilds the revenue of every day from what record saved
+func loadRevenue() error {
+	saved, err := engineToDatabaseQueueClient.HGetAll(context.Background(), FEE_REVENUE).Result()
+	if err != nil {
+		return fmt.Errorf("loading fee revenue: %v", err)
+	}
+	revenue = map[string]*dayRevenue{}
+	for field, marketData := range saved {
+		date, stockSymbol, _ := strings.Cut(field, " ")
+		var market Revenue
+		if err := json.Unmarshal([]byte(marketData), &market); err != nil {
+			fmt.Printf("fees: skipping revenue of %s: %v\n", field, err)
+			continue
+		}
+		day, exists := revenue[date]
+		if !exists {
+			day = &dayRevenue{markets: map[string]*Revenue{}}
+			revenue[date] = day
+		}
+		day.markets[stockSymbol] = &market
+		day.Maker += market.Maker
+		day.Taker += market.Taker
+		day.Settlement += market.Settlement
+		day.Total += market.Total
+		day.Trades += market.Trades
+	}
+	return nil
+}
+
+// Report returns the fee revenue of a UTC date, YYYY-MM-DD, today's if empty
+func Report(date string) (map[string]interface{}, error) {
+	if date == "" {
+		date = time.Now().UTC().Format(time.DateOnly)
+	}
+	if _, err := time.Parse(time.DateOnly, date); err != nil {
+		return nil, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", date)
+	}
+
+	day, exists := revenue[date]
+	if !exists {
+		day = &dayRevenue{markets: map[string]*Revenue{}}
+	}
+	symbols := make([]string, 0, len(day.markets))
+	for symbol := range day.markets {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	markets := make([]map[string]interface{}, 0, len(symbols))
+	for _, symbol := range symbols {
+		markets = append(markets, map[string]interface{}{
+			"stockSymbol": symbol,
+			"revenue":     day.markets[symbol],
+		})
+	}
+
+	return map[string]interface{}{
+		"date":       date,
+		"revenue":    day.Revenue,
+		"markets":    markets,
+		"feeAccount": USDBalances[types.FeeAccount].Balance,
+	}, nil
+}
@@ -0,0 +1,211 @@
+package fees
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	types "github.com/adityadeshlahre/probo-v1/shared/types"
+	"github.com/redis/go-redis/v9"
+)
+
+const testSymbol = "TEST"
+
+// setupFees charges the default schedule to fresh balances, with Redis never
+// reachable so saving revenue fails straight away
+func setupFees(t *testing.T, schedule types.FeeSchedule) {
+	t.Helper()
+	offline := redis.NewClient(&redis.Options{
+		Dialer: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return nil, errors.New("offline")
+		},
+		MaxRetries: -1,
+	})
+	SetClients(offline)
+	SetDataStructures(make(types.USDBalances), types.Markets{testSymbol: {StockSymbol: testSymbol, Status: types.MarketOpen}})
+	saved := config
+	config = types.FeeConfig{Default: schedule}
+	revenue = map[string]*dayRevenue{}
+	t.Cleanup(func() { config = saved })
+}
+
+func TestCharge(t *testing.T) {
+	setupFees(t, types.FeeSchedule{})
+	USDBalances["user"] = types.USDBalance{Balance: 5 * types.USD, Locked: 10 * types.USD}
+	USDBalances["debtor"] = types.USDBalance{Balance: -2 * types.USD}
+
+	for _, test := range []struct {
+		userId string
+		fee    types.Amount
+		want   types.Amount
+	}{
+		{"user", 3 * types.USD, 3 * types.USD},
+		{"user", 0, 0},
+		{"user", 5 * types.USD, 2 * types.USD}, // all that's free, none of what's locked
+		{"user", 1 * types.USD, 0},
+		{"debtor", 1 * types.USD, 0},
+	} {
+		if got := charge(test.userId, test.fee); got != test.want {
+			t.Errorf("charging %s %s took %s, want %s", test.userId, test.fee, got, test.want)
+		}
+	}
+	if balance := USDBalances["user"]; balance != (types.USDBalance{Balance: 0, Locked: 10 * types.USD}) {
+		t.Errorf("user is left with %+v, want only its locked 10.00", balance)
+	}
+	if balance := USDBalances["debtor"]; balance.Balance != -2*types.USD {
+		t.Errorf("debtor is left with %s, want its debt of 2.00 unchanged", balance.Balance)
+	}
+	if got := USDBalances[types.FeeAccount].Balance; got != 5*types.USD {
+		t.Errorf("fee account has %s, want 5.00", got)
+	}
+}
+
+func TestChargeTrade(t *testing.T) {
+	schedule := types.FeeSchedule{MakerBps: 10, TakerBps: 50, TakerPerContract: 1 * types.Cent}
+	for _, test := range []struct {
+		name      string
+		takerId   string
+		fill      types.Fill
+		makerHas  types.Amount
+		reserved  types.Amount // locked with the maker's order
+		wantMaker types.Amount
+		wantTaker types.Amount
+	}{
+		{
+			name:      "swap",
+			takerId:   "taker",
+			fill:      types.Fill{UserId: "maker", Price: 60 * types.USD, Quantity: 10, Type: "swap"},
+			makerHas:  100 * types.USD,
+			wantMaker: 60 * types.Cent,
+			wantTaker: 3*types.USD + 10*types.Cent,
+		},
+		{
+			name:      "the maker of a mint traded the other side",
+			takerId:   "taker",
+			fill:      types.Fill{UserId: "maker", Price: 60 * types.USD, Quantity: 10, Type: "mint"},
+			makerHas:  100 * types.USD,
+			wantMaker: 40 * types.Cent,
+			wantTaker: 3*types.USD + 10*types.Cent,
+		},
+		{
+			name:      "the maker pays what's left of its free balance",
+			takerId:   "taker",
+			fill:      types.Fill{UserId: "maker", Price: 60 * types.USD, Quantity: 10, Type: "swap"},
+			makerHas:  25 * types.Cent,
+			wantMaker: 25 * types.Cent,
+			wantTaker: 3*types.USD + 10*types.Cent,
+		},
+		{
+			name:      "the maker pays out of what its order reserved",
+			takerId:   "taker",
+			fill:      types.Fill{UserId: "maker", Price: 60 * types.USD, Quantity: 10, Type: "swap", Reserved: 60 * types.Cent},
+			reserved:  60 * types.Cent,
+			wantMaker: 60 * types.Cent,
+			wantTaker: 3*types.USD + 10*types.Cent,
+		},
+		{
+			name:      "and gets back what the fee doesn't take",
+			takerId:   "taker",
+			fill:      types.Fill{UserId: "maker", Price: 60 * types.USD, Quantity: 10, Type: "swap", Reserved: 1 * types.USD},
+			reserved:  1 * types.USD,
+			wantMaker: 60 * types.Cent,
+			wantTaker: 3*types.USD + 10*types.Cent,
+		},
+		{
+			name:      "or pays the rest out of its free balance",
+			takerId:   "taker",
+			fill:      types.Fill{UserId: "maker", Price: 60 * types.USD, Quantity: 10, Type: "swap", Reserved: 59 * types.Cent},
+			makerHas:  100 * types.USD,
+			reserved:  59 * types.Cent,
+			wantMaker: 60 * types.Cent,
+			wantTaker: 3*types.USD + 10*types.Cent,
+		},
+		{
+			name:      "the market maker pays nothing",
+			takerId:   "taker",
+			fill:      types.Fill{OrderId: "amm", UserId: types.AMMAccount(testSymbol), Price: 60 * types.USD, Quantity: 10, Type: "amm"},
+			makerHas:  100 * types.USD,
+			wantTaker: 3*types.USD + 10*types.Cent,
+		},
+		{
+			name:      "nor does a liquidity provider taking",
+			takerId:   types.LPAccount(testSymbol),
+			fill:      types.Fill{UserId: "maker", Price: 60 * types.USD, Quantity: 10, Type: "swap"},
+			makerHas:  100 * types.USD,
+			wantMaker: 60 * types.Cent,
+		},
+		{
+			name:     "nor the treasury and the fee account",
+			takerId:  types.TreasuryAccount,
+			fill:     types.Fill{UserId: types.FeeAccount, Price: 60 * types.USD, Quantity: 10, Type: "swap"},
+			makerHas: 100 * types.USD,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			setupFees(t, schedule)
+			USDBalances[test.takerId] = types.USDBalance{Balance: 100 * types.USD}
+			USDBalances[test.fill.UserId] = types.USDBalance{Balance: test.makerHas, Locked: test.reserved}
+			before := USDBalances[test.takerId].Balance + USDBalances[test.fill.UserId].Balance + USDBalances[test.fill.UserId].Locked + USDBalances[types.FeeAccount].Balance
+
+			fill := test.fill
+			ChargeTrade(test.takerId, testSymbol, &fill)
+			if fill.MakerFee != test.wantMaker || fill.TakerFee != test.wantTaker {
+				t.Errorf("maker paid %s and taker %s, want %s and %s", fill.MakerFee, fill.TakerFee, test.wantMaker, test.wantTaker)
+			}
+			after := USDBalances[test.takerId].Balance + USDBalances[test.fill.UserId].Balance + USDBalances[test.fill.UserId].Locked + USDBalances[types.FeeAccount].Balance
+			if after != before {
+				t.Errorf("%s USD after the fees, want the %s there was before", after, before)
+			}
+			if locked := USDBalances[test.fill.UserId].Locked; locked != 0 {
+				t.Errorf("maker has %s locked, want all of the reservation spent or given back", locked)
+			}
+			if test.takerId != types.TreasuryAccount {
+				if got := USDBalances[types.FeeAccount].Balance; got != fill.MakerFee+fill.TakerFee {
+					t.Errorf("fee account has %s, want the %s charged", got, fill.MakerFee+fill.TakerFee)
+				}
+			}
+		})
+	}
+}
+
+func TestReport(t *testing.T) {
+	setupFees(t, types.FeeSchedule{MakerBps: 10, TakerBps: 50, SettlementBps: 100})
+	USDBalances["maker"] = types.USDBalance{Balance: 1000 * types.USD}
+	USDBalances["taker"] = types.USDBalance{Balance: 1000 * types.USD}
+
+	for range 2 {
+		fill := types.Fill{UserId: "maker", Price: 60 * types.USD, Quantity: 10, Type: "swap"}
+		ChargeTrade("taker", testSymbol, &fill)
+	}
+	// A fill between platform accounts pays no fee and isn't a trade that did
+	fill := types.Fill{UserId: types.AMMAccount(testSymbol), Price: 60 * types.USD, Quantity: 10, Type: "amm"}
+	ChargeTrade(types.LPAccount(testSymbol), testSymbol, &fill)
+	ChargeSettlement("taker", testSymbol, 1000*types.USD)
+
+	report, err := Report("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Revenue{
+		Maker:      2 * 60 * types.Cent,
+		Taker:      2 * 3 * types.USD,
+		Settlement: 10 * types.USD,
+		Total:      17*types.USD + 20*types.Cent,
+		Trades:     2,
+	}
+	if got := report["revenue"].(Revenue); got != want {
+		t.Errorf("revenue is %+v, want %+v", got, want)
+	}
+	markets := report["markets"].([]map[string]interface{})
+	if len(markets) != 1 || markets[0]["stockSymbol"] != testSymbol || *markets[0]["revenue"].(*Revenue) != want {
+		t.Errorf("markets are %v, want all of it in %s", markets, testSymbol)
+	}
+	if got := report["feeAccount"]; got != want.Total {
+		t.Errorf("fee account has %v, want %s", got, want.Total)
+	}
+
+	if _, err := Report("yesterday"); err == nil {
+		t.Error("report of an invalid date didn't fail")
+	}
+}
@@ -3,6 +3,7 @@ package server
 import (
 	"net/http"
 
+	"github.com/adityadeshlahre/probo-v1/engine/core"
 	"github.com/labstack/echo/v4"
 )
 
@@ -11,5 +12,41 @@ func NewServer() *echo.Echo {
 	e.GET("/", func(c echo.Context) error {
 		return c.String(http.StatusOK, "Engine is running")
 	})
+
+	// Debug endpoints, served from a snapshot taken on the engine goroutine
+	debugGroup := e.Group("/debug")
+	{
+		debugGroup.GET("/snapshot", getSnapshot)
+		debugGroup.GET("/orderbook/:symbol", getOrderBook)
+		debugGroup.GET("/balances/:userId", getBalances)
+	}
 	return e
 }
+
+func getSnapshot(c echo.Context) error {
+	return c.JSON(http.StatusOK, core.TakeSnapshot())
+}
+
+func getOrderBook(c echo.Context) error {
+	symbol := c.Param("symbol")
+	snapshot := core.TakeSnapshot()
+	orderBook, exists := snapshot.OrderBook[symbol]
+	if !exists {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "order book not found"})
+	}
+	return c.JSON(http.StatusOK, orderBook)
+}
+
+func getBalances(c echo.Context) error {
+	userId := c.Param("userId")
+	snapshot := core.TakeSnapshot()
+	balance, exists := snapshot.USDBalances[userId]
+	if !exists {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "user not found"})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"userId":  userId,
+		"balance": balance,
+		"stocks":  snapshot.StockBalances[userId],
+	})
+}
@@ -0,0 +1,38 @@
+package market
+
+import (
+	"fmt"
+
+	"github.com/adityadeshlahre/probo-v1/engine/amm"
+	types "github.com/adityadeshlahre/probo-v1/shared/types"
+)
+
+// validateAMM checks the market maker asked for with a market and works out
+// its b and subsidy
+func validateAMM(config types.AMMConfig, payout types.Amount) (float64, types.Amount, error) {
+	if err := config.Validate(); err != nil {
+		return 0, 0, err
+	}
+	b, subsidy, funded := amm.Liquidity(config, payout)
+	if !funded {
+		return 0, 0, fmt.Errorf("a subsidy of %s can't cover the %s a market maker with b = %v can lose", subsidy, amm.MaxLoss(b, payout), b)
+	}
+	return b, subsidy, nil
+}
+
+// addAMM gives a traded symbol an LMSR market maker, trading from an account
+// of its own funded with the subsidy from the treasury
+func addAMM(stockSymbol string, b float64, subsidy types.Amount) {
+	account := types.AMMAccount(stockSymbol)
+	market := MarketsMap[stockSymbol]
+	market.AMM = &types.AMM{
+		Account: account,
+		B:       b,
+		Subsidy: subsidy,
+	}
+	MarketsMap[stockSymbol] = market
+
+	fundLP(stockSymbol, account, "amm", subsidy)
+	fmt.Printf("Market %s: LMSR market maker with b = %v and %s subsidy\n", stockSymbol, b, subsidy)
+	sendUSDBalancesToDB()
+}
@@ -0,0 +1,421 @@
+package market
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	types "github.com/adityadeshlahre/probo-v1/shared/types"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+// A manual market isn't settled by whoever ends it. Once it's closed a
+// resolver proposes its outcome with evidence (an admin can propose while it's
+// still trading, which closes it), and the proposal stands once its dispute
+// window passes unchallenged. Any other user can dispute it in the
+// window by posting a bond, and a disputed market waits for a quorum of
+// admins to agree on its outcome. The disputers get their bonds back if the
+// admins overturn the proposal and lose them to the resolver if they don't.
+// A market the admins don't agree on by its deadline is voided.
+
+// Dispute settings, read from the environment by LoadDisputeConfig
+var (
+	disputeWindow      = time.Hour
+	disputeBond        = 10 * types.USD
+	resolutionAdmins   []string
+	resolutionQuorum   = 1
+	resolutionDeadline = 72 * time.Hour
+)
+
+// LoadDisputeConfig reads the dispute settings: DISPUTE_WINDOW_MS, DISPUTE_BOND,
+// RESOLUTION_ADMINS (comma separated user ids, required), RESOLUTION_QUORUM,
+// the number of admins that decide a disputed market (a majority by default),
+// and RESOLUTION_DEADLINE_MS, how long after its dispute window they have.
+// The admins' ids are reserved, so no user can sign up as one.
+func LoadDisputeConfig() error {
+	if window := os.Getenv("DISPUTE_WINDOW_MS"); window != "" {
+		millis, err := strconv.ParseInt(window, 10, 64)
+		if err != nil || millis <= 0 {
+			return fmt.Errorf("invalid DISPUTE_WINDOW_MS %q", window)
+		}
+		disputeWindow = time.Duration(millis) * time.Millisecond
+	}
+	if bond := os.Getenv("DISPUTE_BOND"); bond != "" {
+		amount, err := types.ParseAmount(bond)
+		if err != nil || amount <= 0 {
+			return fmt.Errorf("invalid DISPUTE_BOND %q", bond)
+		}
+		disputeBond = amount
+	}
+	admins := os.Getenv("RESOLUTION_ADMINS")
+	resolutionAdmins = nil
+	for _, admin := range strings.Split(admins, ",") {
+		if admin = strings.TrimSpace(admin); admin != "" && !slices.Contains(resolutionAdmins, admin) {
+			resolutionAdmins = append(resolutionAdmins, admin)
+		}
+	}
+	if len(resolutionAdmins) == 0 {
+		return fmt.Errorf("invalid RESOLUTION_ADMINS %q, set it to the user ids that decide disputes", admins)
+	}
+	resolutionQuorum = len(resolutionAdmins)/2 + 1
+	if quorum := os.Getenv("RESOLUTION_QUORUM"); quorum != "" {
+		n, err := strconv.Atoi(quorum)
+		if err != nil || n < 1 || n > len(resolutionAdmins) {
+			return fmt.Errorf("invalid RESOLUTION_QUORUM %q for %d admins", quorum, len(resolutionAdmins))
+		}
+		resolutionQuorum = n
+	}
+	if deadline := os.Getenv("RESOLUTION_DEADLINE_MS"); deadline != "" {
+		millis, err := strconv.ParseInt(deadline, 10, 64)
+		if err != nil || millis <= 0 {
+			return fmt.Errorf("invalid RESOLUTION_DEADLINE_MS %q", deadline)
+		}
+		resolutionDeadline = time.Duration(millis) * time.Millisecond
+	}
+	fmt.Printf("Disputes: %s window, %s bond, %d of %v admins decide within %s\n", disputeWindow, disputeBond, resolutionQuorum, resolutionAdmins, resolutionDeadline)
+	return nil
+}
+
+// ResolutionAdmin reports whether a user id is one of the resolution admins
+func ResolutionAdmin(userId string) bool {
+	return slices.Contains(resolutionAdmins, userId)
+}
+
+// ProposeResolution proposes the outcome of a closed manual market, or of a
+// trading one if an admin proposes it, which closes it. The outcome is final
+// once the dispute window passes without a dispute.
+func ProposeResolution(req types.ProposeResolutionProps) (*types.Resolution, error) {
+	market, exists := MarketsMap[req.StockSymbol]
+	if !exists {
+		return nil, fmt.Errorf("market %s doesn't exist", req.StockSymbol)
+	}
+	if err := checkNotOutcome(req.StockSymbol); err != nil {
+		return nil, err
+	}
+	if market.Type != types.MarketManual {
+		return nil, fmt.Errorf("market %s resolves on its oracle, not on proposals", req.StockSymbol)
+	}
+	if market.Resolution != nil {
+		return nil, fmt.Errorf("market %s already has a proposed outcome", req.StockSymbol)
+	}
+	if market.Status != types.MarketClosed && !(req.ByAdmin && ResolutionAdmin(req.UserId)) {
+		return nil, fmt.Errorf("market %s is %s, its outcome can only be proposed once it's closed", req.StockSymbol, market.Status)
+	}
+	if _, exists := USDBalances[req.UserId]; !exists {
+		return nil, fmt.Errorf("user %s doesn't exist", req.UserId)
+	}
+	outcome, err := checkClaim(market, req.WinningStock, req.Value)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(req.Evidence) == "" {
+		return nil, fmt.Errorf("a proposal needs evidence of the outcome")
+	}
+	if err := startSettlement(req.StockSymbol); err != nil {
+		return nil, err
+	}
+
+	window := disputeWindow
+	if market.DisputeWindow > 0 {
+		window = time.Duration(market.DisputeWindow) * time.Millisecond
+	}
+	now := time.Now()
+	resolution := &types.Resolution{
+		Status: types.ResolutionProposed,
+		Proposal: types.Proposal{
+			UserId:   req.UserId,
+			Outcome:  outcome,
+			Value:    req.Value,
+			Evidence: req.Evidence,
+			At:       now.Format(time.RFC3339),
+		},
+		DisputeEndsAt: now.Add(window).UnixMilli(),
+	}
+	setResolution(req.StockSymbol, resolution)
+	fmt.Printf("Market %s: %s proposed %s, disputable until %s\n", req.StockSymbol, req.UserId, claimText(outcome, req.Value), time.UnixMilli(resolution.DisputeEndsAt).Format(time.RFC3339))
+	return resolution, nil
+}
+
+// DisputeResolution challenges a market's proposed outcome within its dispute
+// window. The disputer's bond is locked until the market is decided.
+func DisputeResolution(req types.DisputeResolutionProps) (*types.Resolution, error) {
+	resolution, err := openResolution(req.StockSymbol)
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().UnixMilli() >= resolution.DisputeEndsAt {
+		return nil, fmt.Errorf("the dispute window of market %s has passed", req.StockSymbol)
+	}
+	if req.UserId == resolution.Proposal.UserId {
+		return nil, fmt.Errorf("a resolver can't dispute their own proposal")
+	}
+	for _, dispute := range resolution.Disputes {
+		if dispute.UserId == req.UserId {
+			return nil, fmt.Errorf("user %s already disputed market %s", req.UserId, req.StockSymbol)
+		}
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		return nil, fmt.Errorf("a dispute needs a reason")
+	}
+	balance, exists := USDBalances[req.UserId]
+	if !exists {
+		return nil, fmt.Errorf("user %s doesn't exist", req.UserId)
+	}
+	if balance.Balance < disputeBond {
+		return nil, fmt.Errorf("insufficient balance for the %s dispute bond", disputeBond)
+	}
+	balance.Balance -= disputeBond
+	balance.Locked += disputeBond
+	USDBalances[req.UserId] = balance
+	sendUSDBalancesToDB()
+
+	resolution.Status = types.ResolutionDisputed
+	resolution.DecideBy = time.UnixMilli(resolution.DisputeEndsAt).Add(resolutionDeadline).UnixMilli()
+	resolution.Disputes = append(resolution.Disputes, types.Dispute{
+		UserId: req.UserId,
+		Reason: req.Reason,
+		Bond:   disputeBond,
+		At:     time.Now().Format(time.RFC3339),
+	})
+	setResolution(req.StockSymbol, resolution)
+	fmt.Printf("Market %s: %s disputed the proposal\n", req.StockSymbol, req.UserId)
+	return resolution, nil
+}
+
+// VoteResolution records an admin's ruling on a disputed market, replacing
+// their earlier vote. The market is settled once a quorum agrees.
+func VoteResolution(req types.VoteResolutionProps) (*types.Resolution, error) {
+	if !ResolutionAdmin(req.AdminId) {
+		return nil, fmt.Errorf("%s isn't a resolution admin", req.AdminId)
+	}
+	resolution, err := openResolution(req.StockSymbol)
+	if err != nil {
+		return nil, err
+	}
+	if resolution.Status != types.ResolutionDisputed {
+		return nil, fmt.Errorf("market %s isn't disputed, its proposal stands once the dispute window passes", req.StockSymbol)
+	}
+	outcome, err := checkClaim(MarketsMap[req.StockSymbol], req.WinningStock, req.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	vote := types.Vote{
+		AdminId: req.AdminId,
+		Outcome: outcome,
+		Value:   req.Value,
+		At:      time.Now().Format(time.RFC3339),
+	}
+	resolution.Votes = slices.DeleteFunc(resolution.Votes, func(v types.Vote) bool { return v.AdminId == req.AdminId })
+	resolution.Votes = append(resolution.Votes, vote)
+	fmt.Printf("Market %s: %s voted %s\n", req.StockSymbol, req.AdminId, claimText(outcome, req.Value))
+
+	var agreed []string
+	for _, v := range resolution.Votes {
+		if sameClaim(v.Outcome, v.Value, outcome, req.Value) {
+			agreed = append(agreed, v.AdminId)
+		}
+	}
+	if len(agreed) < resolutionQuorum {
+		setResolution(req.StockSymbol, resolution)
+		return resolution, nil
+	}
+	if err := finalize(req.StockSymbol, resolution, types.Decision{
+		Outcome: outcome,
+		Value:   req.Value,
+		By:      "admins",
+		Admins:  agreed,
+	}); err != nil {
+		return nil, err
+	}
+	return resolution, nil
+}
+
+// FinalizeResolutions settles every market whose proposal went undisputed
+// through its dispute window, and voids every disputed one the admins didn't
+// decide by its deadline. The engine calls it on a timer.
+func FinalizeResolutions(now time.Time) {
+	for stockSymbol, market := range MarketsMap {
+		resolution := market.Resolution
+		if resolution == nil || market.Status != types.MarketResolving {
+			continue
+		}
+		if resolution.Status == types.ResolutionDisputed && resolution.DecideBy != 0 && now.UnixMilli() >= resolution.DecideBy {
+			fmt.Printf("Market %s: the admins didn't reach a quorum by the deadline, voiding it\n", stockSymbol)
+			if _, err := VoidMarket(stockSymbol); err != nil {
+				fmt.Printf("Error voiding market %s: %v\n", stockSymbol, err)
+				continue
+			}
+			resolution.Status = types.ResolutionExpired
+			setResolution(stockSymbol, resolution)
+			continue
+		}
+		if resolution.Status != types.ResolutionProposed || now.UnixMilli() < resolution.DisputeEndsAt {
+			continue
+		}
+		fmt.Printf("Market %s: dispute window passed, settling on the proposal\n", stockSymbol)
+		if err := finalize(stockSymbol, resolution, types.Decision{
+			Outcome: resolution.Proposal.Outcome,
+			Value:   resolution.Proposal.Value,
+			By:      "undisputed",
+		}); err != nil {
+			fmt.Printf("Error settling market %s: %v\n", stockSymbol, err)
+		}
+	}
+}
+
+// finalize settles a market on its decided outcome, then settles the bonds
+// of its disputers
+func finalize(stockSymbol string, resolution *types.Resolution, decision types.Decision) error {
+	if decision.Value != nil {
+		if _, err := EndScalarMarket(stockSymbol, *decision.Value); err != nil {
+			return err
+		}
+	} else if err := EndMarket(stockSymbol, decision.Outcome); err != nil {
+		return err
+	}
+
+	proposal := resolution.Proposal
+	decision.Upheld = sameClaim(decision.Outcome, decision.Value, proposal.Outcome, proposal.Value)
+	for _, dispute := range resolution.Disputes {
+		balance := USDBalances[dispute.UserId]
+		balance.Locked -= dispute.Bond
+		if decision.Upheld {
+			// The dispute failed, its bond pays the resolver for their trouble
+			proposer := USDBalances[proposal.UserId]
+			proposer.Balance += dispute.Bond
+			USDBalances[proposal.UserId] = proposer
+			recordBond(dispute.UserId, proposal.UserId, stockSymbol, dispute.Bond)
+		} else {
+			balance.Balance += dispute.Bond
+		}
+		USDBalances[dispute.UserId] = balance
+	}
+	if len(resolution.Disputes) > 0 {
+		sendUSDBalancesToDB()
+	}
+
+	decision.At = time.Now().Format(time.RFC3339)
+	resolution.Decision = &decision
+	resolution.Status = types.ResolutionFinal
+	setResolution(stockSymbol, resolution)
+	return nil
+}
+
+// releaseBonds gives the disputers of a voided market their bonds back
+func releaseBonds(stockSymbol string) {
+	resolution := MarketsMap[stockSymbol].Resolution
+	if resolution == nil || resolution.Status == types.ResolutionFinal {
+		return
+	}
+	for _, dispute := range resolution.Disputes {
+		balance := USDBalances[dispute.UserId]
+		balance.Locked -= dispute.Bond
+		balance.Balance += dispute.Bond
+		USDBalances[dispute.UserId] = balance
+	}
+}
+
+// openResolution returns the resolution of a market that's proposed or disputed
+func openResolution(stockSymbol string) (*types.Resolution, error) {
+	market, exists := MarketsMap[stockSymbol]
+	if !exists {
+		return nil, fmt.Errorf("market %s doesn't exist", stockSymbol)
+	}
+	if market.Resolution == nil {
+		return nil, fmt.Errorf("market %s has no proposed outcome", stockSymbol)
+	}
+	if market.Resolution.Status == types.ResolutionFinal || market.Status != types.MarketResolving {
+		return nil, fmt.Errorf("market %s is already %s", stockSymbol, market.Status)
+	}
+	return market.Resolution, nil
+}
+
+// checkClaim checks an outcome claimed for a market: a value for a scalar
+// market, one of its outcomes otherwise. It returns the outcome lowercased.
+func checkClaim(market types.EnhancedMarket, winningStock string, value *float64) (string, error) {
+	if market.Scalar != nil {
+		if value == nil {
+			return "", fmt.Errorf("market %s is scalar, claim a value", market.StockSymbol)
+		}
+		return "", nil
+	}
+	if value != nil {
+		return "", fmt.Errorf("market %s isn't scalar, claim a winning stock", market.StockSymbol)
+	}
+	winningStock = strings.ToLower(winningStock)
+	if !slices.Contains(market.OutcomeNames(), winningStock) {
+		return "", fmt.Errorf("invalid winning outcome %q, expected one of %s", winningStock, strings.Join(market.OutcomeNames(), ", "))
+	}
+	return winningStock, nil
+}
+
+func sameClaim(outcome string, value *float64, otherOutcome string, otherValue *float64) bool {
+	if value != nil || otherValue != nil {
+		return value != nil && otherValue != nil && *value == *otherValue
+	}
+	return outcome == otherOutcome
+}
+
+func claimText(outcome string, value *float64) string {
+	if value != nil {
+		return strconv.FormatFloat(*value, 'f', -1, 64)
+	}
+	return outcome
+}
+
+// setResolution stores a market's resolution, sends it to the database and
+// tells the market's subscribers
+func setResolution(stockSymbol string, resolution *types.Resolution) {
+	market := MarketsMap[stockSymbol]
+	market.Resolution = resolution
+	MarketsMap[stockSymbol] = market
+
+	updateData, _ := json.Marshal(types.MarketResolutionUpdate{
+		Symbol:     stockSymbol,
+		Resolution: *resolution,
+	})
+	updateMsg := types.IncomingMessage{
+		Type: types.MARKET_RESOLUTION,
+		Data: updateData,
+	}
+	updateBytes, _ := json.Marshal(updateMsg)
+	engineToDatabaseQueueClient.LPush(context.Background(), types.DB_ACTIONS, updateBytes)
+
+	resolutionData, _ := json.Marshal(resolution)
+	resolutionMsg := types.IncomingMessage{
+		Type: "RESOLUTION",
+		Data: resolutionData,
+	}
+	resolutionBytes, _ := json.Marshal(resolutionMsg)
+	engineToServerPubSubClient.Publish(context.Background(), types.SettlementsChannel(stockSymbol), resolutionBytes)
+}
+
+// recordBond adds a forfeited bond to the ledger and sends it to the database
+func recordBond(disputerId, proposerId, stockSymbol string, bond types.Amount) {
+	transectionId, _ := gonanoid.New()
+	transection := types.Transection{
+		Id:              transectionId,
+		MakerId:         disputerId,
+		TakerId:         proposerId,
+		TransectionType: types.BOND,
+		Amount:          bond,
+		Symbol:          stockSymbol,
+		CreatedAt:       time.Now().Format(time.RFC3339),
+		UpdatedAt:       time.Now().Format(time.RFC3339),
+	}
+	Transections = append(Transections, transection)
+
+	transectionData, _ := json.Marshal(transection)
+	transectionMsg := types.IncomingMessage{
+		Type: types.TRANSECTION,
+		Data: transectionData,
+	}
+	transectionMsgBytes, _ := json.Marshal(transectionMsg)
+	engineToDatabaseQueueClient.LPush(context.Background(), types.DB_ACTIONS, transectionMsgBytes)
+}
@@ -0,0 +1,108 @@
+package market
+
+import (
+	"testing"
+	"time"
+
+	types "github.com/adityadeshlahre/probo-v1/shared/types"
+)
+
+// setupDisputes makes the markets manual ones with "admin" as the only
+// resolution admin
+func setupDisputes(t *testing.T, symbols ...string) {
+	t.Helper()
+	setupMarkets(t, symbols...)
+	for _, symbol := range symbols {
+		market := MarketsMap[symbol]
+		market.Type = types.MarketManual
+		MarketsMap[symbol] = market
+	}
+	savedAdmins, savedQuorum := resolutionAdmins, resolutionQuorum
+	resolutionAdmins, resolutionQuorum = []string{"admin"}, 1
+	t.Cleanup(func() { resolutionAdmins, resolutionQuorum = savedAdmins, savedQuorum })
+	for _, userId := range []string{"admin", "user"} {
+		USDBalances[userId] = types.USDBalance{Balance: 100 * types.USD}
+	}
+}
+
+func propose(userId, stockSymbol string, byAdmin bool) error {
+	_, err := ProposeResolution(types.ProposeResolutionProps{
+		StockSymbol:  stockSymbol,
+		UserId:       userId,
+		WinningStock: "yes",
+		Evidence:     "it happened",
+		ByAdmin:      byAdmin,
+	})
+	return err
+}
+
+func TestProposeWhileTrading(t *testing.T) {
+	setupDisputes(t, "OPEN", "CLOSED")
+
+	for _, test := range []struct {
+		userId  string
+		byAdmin bool
+	}{
+		{"user", false},
+		{"user", true}, // the server vouched for an admin's key, but not this user's
+		{"admin", false},
+	} {
+		if err := propose(test.userId, "OPEN", test.byAdmin); err == nil {
+			t.Errorf("%s proposed the outcome of a trading market (byAdmin %v)", test.userId, test.byAdmin)
+		}
+	}
+	if status := MarketsMap["OPEN"].Status; status != types.MarketOpen || MarketsMap["OPEN"].Resolution != nil {
+		t.Fatalf("refused proposals left the market %s with %+v", status, MarketsMap["OPEN"].Resolution)
+	}
+
+	if err := propose("admin", "OPEN", true); err != nil {
+		t.Fatal(err)
+	}
+	if status := MarketsMap["OPEN"].Status; status != types.MarketResolving {
+		t.Errorf("admin's proposal left the market %s, want it closed and RESOLVING", status)
+	}
+
+	// Once the market is closed anyone can propose
+	if _, err := SetMarketStatus(types.MarketStatusProps{StockSymbol: "CLOSED", Status: types.MarketClosed}); err != nil {
+		t.Fatal(err)
+	}
+	if err := propose("user", "CLOSED", false); err != nil {
+		t.Fatal(err)
+	}
+	if resolution := MarketsMap["CLOSED"].Resolution; resolution == nil || resolution.Proposal.UserId != "user" {
+		t.Errorf("user's proposal of the closed market is %+v", resolution)
+	}
+}
+
+// A disputed market the admins don't decide by its deadline, here one vote
+// short of a quorum, is voided and its disputers get their bonds back
+func TestDisputeDeadline(t *testing.T) {
+	setupDisputes(t, "M")
+	resolutionAdmins, resolutionQuorum = []string{"admin", "other"}, 2
+	if err := propose("admin", "M", true); err != nil {
+		t.Fatal(err)
+	}
+	resolution, err := DisputeResolution(types.DisputeResolutionProps{StockSymbol: "M", UserId: "user", Reason: "it didn't"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.UnixMilli(resolution.DisputeEndsAt).Add(resolutionDeadline).UnixMilli(); resolution.DecideBy != want {
+		t.Errorf("disputed market must be decided by %d, want %d", resolution.DecideBy, want)
+	}
+	if _, err := VoteResolution(types.VoteResolutionProps{StockSymbol: "M", AdminId: "admin", WinningStock: "no"}); err != nil {
+		t.Fatal(err)
+	}
+
+	FinalizeResolutions(time.UnixMilli(resolution.DecideBy - 1))
+	if status := MarketsMap["M"].Resolution.Status; status != types.ResolutionDisputed {
+		t.Fatalf("resolution is %s before the deadline, want DISPUTED", status)
+	}
+
+	FinalizeResolutions(time.UnixMilli(resolution.DecideBy))
+	if market := MarketsMap["M"]; market.Status != types.MarketVoided || market.Resolution.Status != types.ResolutionExpired {
+		t.Errorf("market is %s with its resolution %s after the deadline, want VOIDED and EXPIRED", market.Status, market.Resolution.Status)
+	}
+	if balance := USDBalances["user"]; balance != (types.USDBalance{Balance: 100 * types.USD}) {
+		t.Errorf("disputer has %+v, want their bond back", balance)
+	}
+}
@@ -0,0 +1,136 @@
+package market
+
+import (
+	"fmt"
+	"time"
+
+	types "github.com/adityadeshlahre/probo-v1/shared/types"
+)
+
+// transition moves a market to status if its lifecycle allows it, and records
+// when it did. The outcomes of a categorical market move with it.
+func transition(stockSymbol string, status types.MarketStatus) error {
+	market, exists := MarketsMap[stockSymbol]
+	if !exists {
+		return fmt.Errorf("market %s doesn't exist", stockSymbol)
+	}
+	if !market.Status.CanBecome(status) {
+		return fmt.Errorf("market %s can't go from %s to %s", stockSymbol, market.Status, status)
+	}
+
+	setStatus(stockSymbol, status)
+	for _, outcome := range market.Outcomes {
+		setStatus(types.OutcomeSymbol(stockSymbol, outcome), status)
+	}
+	return nil
+}
+
+// setStatus records a market's move to status
+func setStatus(stockSymbol string, status types.MarketStatus) {
+	market := MarketsMap[stockSymbol]
+	fmt.Printf("Market %s: %s -> %s\n", stockSymbol, market.Status, status)
+	market.Transitions = append(market.Transitions, types.MarketTransition{
+		From: market.Status,
+		To:   status,
+		At:   time.Now().Format(time.RFC3339),
+	})
+	market.Status = status
+	MarketsMap[stockSymbol] = market
+}
+
+// TradedSymbols returns the symbols a market trades under: one per outcome
+// for a categorical market, its own for a binary one
+func TradedSymbols(stockSymbol string) []string {
+	market := MarketsMap[stockSymbol]
+	if len(market.Outcomes) == 0 {
+		return []string{stockSymbol}
+	}
+	symbols := make([]string, len(market.Outcomes))
+	for i, outcome := range market.Outcomes {
+		symbols[i] = types.OutcomeSymbol(stockSymbol, outcome)
+	}
+	return symbols
+}
+
+// checkNotOutcome returns an error for the outcome of a categorical market,
+// whose lifecycle is its parent's
+func checkNotOutcome(stockSymbol string) error {
+	if parent := MarketsMap[stockSymbol].Parent; parent != "" {
+		return fmt.Errorf("%s is an outcome of %s, change %s instead", stockSymbol, parent, parent)
+	}
+	return nil
+}
+
+// SetMarketStatus opens, halts, closes or voids a market. Resolving and
+// settling happen through EndMarket.
+func SetMarketStatus(req types.MarketStatusProps) (map[string]interface{}, error) {
+	switch req.Status {
+	case types.MarketOpen, types.MarketHalted, types.MarketClosed, types.MarketVoided:
+	case types.MarketResolving, types.MarketSettled:
+		return nil, fmt.Errorf("markets are resolved and settled by ending them")
+	default:
+		return nil, fmt.Errorf("invalid status %q, expected OPEN, HALTED, CLOSED or VOIDED", req.Status)
+	}
+	if err := checkNotOutcome(req.StockSymbol); err != nil {
+		return nil, err
+	}
+
+	var err error
+	var refunds map[string]types.Amount
+	switch req.Status {
+	case types.MarketClosed:
+		err = closeMarket(req.StockSymbol)
+	case types.MarketVoided:
+		refunds, err = VoidMarket(req.StockSymbol)
+	default:
+		err = transition(req.StockSymbol, req.Status)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	market := MarketsMap[req.StockSymbol]
+	result := map[string]interface{}{
+		"status":       true,
+		"stockSymbol":  req.StockSymbol,
+		"marketStatus": market.Status,
+		"transitions":  market.Transitions,
+	}
+	if refunds != nil {
+		result["refunds"] = refunds
+	}
+	return result, nil
+}
+
+// closeMarket stops trading in a market. Every resting order is cancelled and
+// its locked USD or stocks released, so nothing trades until it's resolved.
+func closeMarket(stockSymbol string) error {
+	if err := transition(stockSymbol, types.MarketClosed); err != nil {
+		return err
+	}
+	for _, symbol := range TradedSymbols(stockSymbol) {
+		if err := clearOrderBook(symbol); err != nil {
+			return err
+		}
+	}
+	sendUSDBalancesToDB()
+	return nil
+}
+
+// CloseDueMarkets closes every open or halted market whose close time has
+// passed. The engine calls it on a timer, so markets close on time whether or
+// not the server is up.
+func CloseDueMarkets(now time.Time) {
+	for stockSymbol, market := range MarketsMap {
+		if market.ClosesAt == 0 || now.UnixMilli() < market.ClosesAt {
+			continue
+		}
+		if market.Status != types.MarketOpen && market.Status != types.MarketHalted {
+			continue
+		}
+		fmt.Printf("Market %s reached its close time\n", stockSymbol)
+		if err := closeMarket(stockSymbol); err != nil {
+			fmt.Printf("Error closing market %s: %v\n", stockSymbol, err)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package market
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	types "github.com/adityadeshlahre/probo-v1/shared/types"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+// Market makers are funded from the treasury, never out of thin air. Each
+// traded symbol's market maker gets an account of its own and its subsidy
+// when the market is created. The sell ladders' market maker mints its
+// subsidy into YES/NO pairs to sell, an LMSR market maker keeps it as USD.
+// Once the market settles or is voided whatever is in the account goes back
+// to the treasury, and the difference is the market maker's P&L.
+
+// Funding settings, read from the environment by InitTreasury
+var (
+	treasuryBalance = 1000000 * types.USD
+	lpSubsidy       = 20000 * types.USD
+)
+
+// InitTreasury reads TREASURY_BALANCE, what the treasury starts with, and
+// LP_SUBSIDY, what the sell ladders of a symbol are funded with unless its
+// market asks for more or less, and creates the treasury account
+func InitTreasury() error {
+	if balance := os.Getenv("TREASURY_BALANCE"); balance != "" {
+		amount, err := types.ParseAmount(balance)
+		if err != nil || amount < 0 {
+			return fmt.Errorf("invalid TREASURY_BALANCE %q", balance)
+		}
+		treasuryBalance = amount
+	}
+	if subsidy := os.Getenv("LP_SUBSIDY"); subsidy != "" {
+		amount, err := types.ParseAmount(subsidy)
+		if err != nil || amount <= 0 {
+			return fmt.Errorf("invalid LP_SUBSIDY %q", subsidy)
+		}
+		lpSubsidy = amount
+	}
+
+	if _, exists := USDBalances[types.TreasuryAccount]; !exists {
+		USDBalances[types.TreasuryAccount] = types.USDBalance{Balance: treasuryBalance}
+		sendUserToDB(types.TreasuryAccount)
+		sendUSDBalancesToDB()
+	}
+	fmt.Printf("Treasury: %s, %s per market maker\n", USDBalances[types.TreasuryAccount].Balance, lpSubsidy)
+	return nil
+}
+
+// checkFunding works out what the sell ladders of each traded symbol of a
+// new market are funded with, 0 for a market with an LMSR market maker, and
+// checks the treasury can fund every market maker of the market
+func checkFunding(createReq types.CreateMarket, payout types.Amount, ammSubsidy types.Amount, symbols int) (types.Amount, error) {
+	subsidy := ammSubsidy
+	if createReq.AMM == nil {
+		if createReq.LPSubsidy < 0 {
+			return 0, fmt.Errorf("invalid lp subsidy %s", createReq.LPSubsidy)
+		}
+		subsidy = createReq.LPSubsidy
+		if subsidy == 0 {
+			subsidy = lpSubsidy
+		}
+		if subsidy < payout {
+			return 0, fmt.Errorf("an lp subsidy of %s can't mint a single pair worth %s", subsidy, payout)
+		}
+	}
+	needed := subsidy * types.Amount(symbols)
+	if treasury := USDBalances[types.TreasuryAccount].Balance; treasury < needed {
+		return 0, fmt.Errorf("the treasury has %s, funding the market makers of this market takes %s", treasury, needed)
+	}
+	if createReq.AMM != nil {
+		return 0, nil
+	}
+	return subsidy, nil
+}
+
+// fundLP moves a symbol's market maker's subsidy from the treasury to its account
+func fundLP(stockSymbol, account, kind string, subsidy types.Amount) {
+	treasury := USDBalances[types.TreasuryAccount]
+	treasury.Balance -= subsidy
+	USDBalances[types.TreasuryAccount] = treasury
+
+	balance := USDBalances[account]
+	balance.Balance += subsidy
+	USDBalances[account] = balance
+	if _, exists := StockBalances[account]; !exists {
+		StockBalances[account] = make(types.UserStockBalance)
+	}
+	StockBalances[account][stockSymbol] = types.SymbolStockBalance{}
+
+	market := MarketsMap[stockSymbol]
+	market.LP = &types.LiquidityProvider{
+		Account:  account,
+		Kind:     kind,
+		Subsidy:  subsidy,
+		FundedAt: time.Now().Format(time.RFC3339),
+	}
+	MarketsMap[stockSymbol] = market
+
+	sendUserToDB(account)
+	recordFunding(types.FUND, types.TreasuryAccount, account, stockSymbol, subsidy)
+}
+
+// addBookLP funds the market maker that posts sell ladders in a symbol and
+// mints as many YES/NO pairs as its subsidy buys. The ladders are posted by
+// the engine from LPAccount(stockSymbol).
+func addBookLP(stockSymbol string, subsidy types.Amount) {
+	account := types.LPAccount(stockSymbol)
+	fundLP(stockSymbol, account, "book", subsidy)
+
+	payout := MarketsMap.Contract(stockSymbol).Payout
+	pairs := types.Shares(subsidy / payout)
+	cost := payout.Times(pairs)
+	balance := USDBalances[account]
+	balance.Balance -= cost
+	USDBalances[account] = balance
+
+	legCosts := splitAmount(cost, 2)
+	StockBalances[account][stockSymbol] = types.SymbolStockBalance{
+		Yes: types.StockPosition{Quantity: pairs, Cost: legCosts[0]},
+		No:  types.StockPosition{Quantity: pairs, Cost: legCosts[1]},
+	}
+	recordSet(types.SPLIT, types.CompleteSetProps{UserId: account, StockSymbol: stockSymbol, Quantity: pairs}, cost)
+	fmt.Printf("Market %s: market maker %s funded with %s, %d pairs minted\n", stockSymbol, account, subsidy, pairs)
+	sendUSDBalancesToDB()
+}
+
+// defundLPs pays what's left in the accounts of a market's market makers back
+// into the treasury, once its payouts or refunds are made
+func defundLPs(stockSymbol string) {
+	for _, symbol := range TradedSymbols(stockSymbol) {
+		market := MarketsMap[symbol]
+		if market.LP == nil || market.LP.DefundedAt != "" {
+			continue
+		}
+		lp := *market.LP
+		balance := USDBalances[lp.Account]
+		returned := balance.Balance + balance.Locked
+		USDBalances[lp.Account] = types.USDBalance{}
+
+		treasury := USDBalances[types.TreasuryAccount]
+		treasury.Balance += returned
+		USDBalances[types.TreasuryAccount] = treasury
+
+		lp.Returned = returned
+		lp.PnL = returned - lp.Subsidy
+		lp.DefundedAt = time.Now().Format(time.RFC3339)
+		market.LP = &lp
+		MarketsMap[symbol] = market
+
+		recordFunding(types.DEFUND, lp.Account, types.TreasuryAccount, symbol, returned)
+		fmt.Printf("Market %s: market maker %s returned %s of %s, P&L %s\n", symbol, lp.Account, returned, lp.Subsidy, lp.PnL)
+	}
+}
+
+// recordFunding adds a transfer between the treasury and a market maker to
+// the ledger and sends it to the database
+func recordFunding(transectionType types.TransectionType, from, to, stockSymbol string, amount types.Amount) {
+	transectionId, _ := gonanoid.New()
+	transection := types.Transection{
+		Id:              transectionId,
+		MakerId:         from,
+		TakerId:         to,
+		TransectionType: transectionType,
+		Amount:          amount,
+		Symbol:          stockSymbol,
+		SymbolStockType: symbolStockType(stockSymbol),
+		CreatedAt:       time.Now().Format(time.RFC3339),
+		UpdatedAt:       time.Now().Format(time.RFC3339),
+	}
+	Transections = append(Transections, transection)
+
+	transectionData, _ := json.Marshal(transection)
+	transectionMsg := types.IncomingMessage{
+		Type: types.TRANSECTION,
+		Data: transectionData,
+	}
+	transectionMsgBytes, _ := json.Marshal(transectionMsg)
+	engineToDatabaseQueueClient.LPush(context.Background(), types.DB_ACTIONS, transectionMsgBytes)
+}
+
+// sendUserToDB creates an account in the database
+func sendUserToDB(userId string) {
+	userData, _ := json.Marshal(types.User{Id: userId})
+	userMsg := types.IncomingMessage{Type: types.USER, Data: userData}
+	userBytes, _ := json.Marshal(userMsg)
+	engineToDatabaseQueueClient.LPush(context.Background(), types.DB_ACTIONS, userBytes)
+}
+
+// LPReport lists the funding of every market maker, or of those of one
+// market, with their P&L once their market is over
+func LPReport(stockSymbol string) (map[string]interface{}, error) {
+	if _, exists := MarketsMap[stockSymbol]; stockSymbol != "" && !exists {
+		return nil, fmt.Errorf("market %s doesn't exist", stockSymbol)
+	}
+
+	symbols := make([]string, 0, len(MarketsMap))
+	for symbol, market := range MarketsMap {
+		if market.LP == nil {
+			continue
+		}
+		if stockSymbol != "" && symbol != stockSymbol && market.Parent != stockSymbol {
+			continue
+		}
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	var subsidy, returned, pnl types.Amount
+	makers := make([]map[string]interface{}, 0, len(symbols))
+	for _, symbol := range symbols {
+		market := MarketsMap[symbol]
+		lp := *market.LP
+		status := market.Status
+		if market.Parent != "" {
+			status = MarketsMap[market.Parent].Status
+		}
+		row := map[string]interface{}{
+			"stockSymbol": symbol,
+			"status":      status,
+			"lp":          lp,
+		}
+		subsidy += lp.Subsidy
+		if lp.DefundedAt != "" {
+			returned += lp.Returned
+			pnl += lp.PnL
+		} else {
+			// Still trading, so the P&L isn't known yet
+			row["balance"] = USDBalances[lp.Account]
+			row["stocks"] = StockBalances[lp.Account][symbol]
+		}
+		makers = append(makers, row)
+	}
+
+	return map[string]interface{}{
+		"stockSymbol":  stockSymbol,
+		"treasury":     USDBalances[types.TreasuryAccount].Balance,
+		"marketMakers": makers,
+		"subsidy":      subsidy,
+		"returned":     returned,
+		"pnl":          pnl,
+	}, nil
+}
@@ -4,9 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/adityadeshlahre/probo-v1/engine/fees"
+	"github.com/adityadeshlahre/probo-v1/engine/orderbook"
+	"github.com/adityadeshlahre/probo-v1/shared/rule"
 	types "github.com/adityadeshlahre/probo-v1/shared/types"
 	gonanoid "github.com/matoous/go-nanoid/v2"
 	"github.com/redis/go-redis/v9"
@@ -24,40 +28,50 @@ func SetClients(dbClient, pubSubClient *redis.Client) {
 // Import these from main (will need to be passed or made accessible)
 var USDBalances types.USDBalances
 var StockBalances types.StockBalances
-var OrderBook types.YesNoOrderBook
+var OrderBook orderbook.Books
+var OrderRegistry orderbook.Registry
 var MarketsMap types.Markets
 var Orders []types.Order
 var Transections []types.Transection
 
 // SetDataStructures sets references to shared data structures
-func SetDataStructures(usdBalances types.USDBalances, stockBalances types.StockBalances, orderBook types.YesNoOrderBook, marketsMap types.Markets, orders *[]types.Order, transections *[]types.Transection) {
+func SetDataStructures(usdBalances types.USDBalances, stockBalances types.StockBalances, orderBook orderbook.Books, orderRegistry orderbook.Registry, marketsMap types.Markets, orders *[]types.Order, transections *[]types.Transection) {
 	USDBalances = usdBalances
 	StockBalances = stockBalances
 	OrderBook = orderBook
+	OrderRegistry = orderRegistry
 	MarketsMap = marketsMap
 	Orders = *orders
 	Transections = *transections
 }
 
-// processWinnings handles payout to winners when market ends
+// processWinnings handles payout to winners when market ends. Each winning
+// share pays the payout of the market's contract.
 func processWinnings(stockSymbol string, winningStock string) error {
 	fmt.Printf("Processing winnings for %s, winner: %s\n", stockSymbol, winningStock)
+	payout := MarketsMap.Contract(stockSymbol).Payout
+	if winningStock == "yes" {
+		return processPayouts(stockSymbol, payout, 0)
+	}
+	return processPayouts(stockSymbol, 0, payout)
+}
 
+// processPayouts pays every holder of a symbol yesPayout per YES share and
+// noPayout per NO share, and clears their stocks of it
+func processPayouts(stockSymbol string, yesPayout, noPayout types.Amount) error {
 	// Process payouts for all users
 	for userId, userStocks := range StockBalances {
 		if symbolStocks, exists := userStocks[stockSymbol]; exists {
-			var winningQuantity float64
-			if winningStock == "yes" {
-				winningQuantity = symbolStocks.Yes.Quantity
-			} else {
-				winningQuantity = symbolStocks.No.Quantity
-			}
-
-			// Pay out winners: quantity * 1000
-			if winningQuantity > 0 {
+			// Pay out holders: quantity * payout per side
+			amount := yesPayout.Times(symbolStocks.Yes.Quantity) + noPayout.Times(symbolStocks.No.Quantity)
+			if amount > 0 {
 				if balance, exists := USDBalances[userId]; exists {
-					balance.Balance += winningQuantity * 1000
+					balance.Balance += amount
 					USDBalances[userId] = balance
+					// Winnings pay the settlement fee
+					if fee := fees.ChargeSettlement(userId, stockSymbol, amount); fee > 0 {
+						recordFee(userId, stockSymbol, amount, fee)
+					}
 				}
 			}
 
@@ -79,38 +93,87 @@ func processWinnings(stockSymbol string, winningStock string) error {
 	return nil
 }
 
-// clearOrderBook removes all orders for a symbol and unlocks balances
-func clearOrderBook(stockSymbol string) error {
-	fmt.Printf("Clearing order book for %s\n", stockSymbol)
-
-	if _, exists := OrderBook[stockSymbol]; !exists {
-		return fmt.Errorf("order book for symbol '%s' doesn't exist", stockSymbol)
+// recordFee adds a settlement fee on a user's winnings to the ledger and
+// sends it to the database
+func recordFee(userId, stockSymbol string, winnings, fee types.Amount) {
+	transectionId, _ := gonanoid.New()
+	transection := types.Transection{
+		Id:              transectionId,
+		MakerId:         userId,
+		TakerId:         types.FeeAccount,
+		TransectionType: types.FEE,
+		Price:           winnings, // what the fee was charged on
+		Amount:          fee,
+		Symbol:          stockSymbol,
+		SymbolStockType: symbolStockType(stockSymbol),
+		CreatedAt:       time.Now().Format(time.RFC3339),
+		UpdatedAt:       time.Now().Format(time.RFC3339),
 	}
+	Transections = append(Transections, transection)
 
-	orderBook := OrderBook[stockSymbol]
+	transectionData, _ := json.Marshal(transection)
+	transectionMsg := types.IncomingMessage{
+		Type: types.TRANSECTION,
+		Data: transectionData,
+	}
+	transectionMsgBytes, _ := json.Marshal(transectionMsg)
+	engineToDatabaseQueueClient.LPush(context.Background(), types.DB_ACTIONS, transectionMsgBytes)
+}
 
-	// Process all orders in the YES order book
-	for price, entry := range orderBook.Yes {
-		for _, order := range entry.Orders {
-			processOrder(order, stockSymbol, "yes", price)
-		}
+// clearOrderBook cancels all orders for a symbol and unlocks balances. A
+// symbol without a book has nothing to clear.
+func clearOrderBook(stockSymbol string) error {
+	orderBook, exists := OrderBook[stockSymbol]
+	if !exists {
+		return nil
 	}
+	fmt.Printf("Clearing order book for %s\n", stockSymbol)
 
-	// Process all orders in the NO order book
-	for price, entry := range orderBook.No {
-		for _, order := range entry.Orders {
-			processOrder(order, stockSymbol, "no", price)
+	for _, stockType := range []string{"yes", "no"} {
+		for _, order := range orderBook.Side(stockType).Orders() {
+			processOrder(order.OrderBookEntry, stockSymbol, stockType, order.Price)
+			cancelOrderRecord(order.Id)
 		}
 	}
 
 	// Clear the order book
 	delete(OrderBook, stockSymbol)
 
+	// Subscribers see an empty book
+	publishOrderBook(stockSymbol, orderbook.NewSymbolBook().Snapshot())
+
 	return nil
 }
 
+// publishOrderBook sends the order book of a symbol to its subscribers
+func publishOrderBook(stockSymbol string, snapshot types.SymbolOrderBook) {
+	orderBookData, _ := json.Marshal(snapshot)
+	wsMsg := types.IncomingMessage{
+		Type: "ORDER_BOOK_UPDATE",
+		Data: orderBookData,
+	}
+	wsBytes, _ := json.Marshal(wsMsg)
+	engineToServerPubSubClient.Publish(context.Background(), stockSymbol, wsBytes)
+}
+
+// cancelOrderRecord marks a cleared order cancelled in the registry and the database
+func cancelOrderRecord(orderId string) {
+	OrderRegistry.Update(orderId, 0, types.CANCELLED)
+
+	orderBytes, _ := json.Marshal(types.OrderUpdate{
+		OrderId: orderId,
+		Status:  types.CANCELLED,
+	})
+	orderMsg := types.IncomingMessage{
+		Type: types.UPDATE_ORDER,
+		Data: orderBytes,
+	}
+	orderMsgBytes, _ := json.Marshal(orderMsg)
+	engineToDatabaseQueueClient.LPush(context.Background(), types.DB_ACTIONS, orderMsgBytes)
+}
+
 // processOrder unlocks balances when clearing order book
-func processOrder(order types.OrderBookEntry, stockSymbol string, stockType string, price float64) error {
+func processOrder(order types.OrderBookEntry, stockSymbol string, stockType string, price types.Amount) error {
 	if _, exists := USDBalances[order.UserId]; !exists {
 		return fmt.Errorf("invalid balances for user %s", order.UserId)
 	}
@@ -121,12 +184,21 @@ func processOrder(order types.OrderBookEntry, stockSymbol string, stockType stri
 
 	fmt.Printf("Processing order for user %s\n", order.UserId)
 
+	// Give back the maker fee the order reserved when it rested
+	if balance := USDBalances[order.UserId]; order.Fee != 0 {
+		balance.Locked -= order.Fee
+		balance.Balance += order.Fee
+		USDBalances[order.UserId] = balance
+	}
+
 	switch order.Type {
 	case "reverted":
-		// Unlock USD balance (no refund since balance was not decreased for reverted orders)
+		// Refund the locked USD of the unfilled buy order, locked at the buyer's own price
 		if balance, exists := USDBalances[order.UserId]; exists {
-			fmt.Printf("clearOrderBook: reverted order for %s, locked %.2f -> %.2f (unlock %.2f)\n", order.UserId, balance.Locked, balance.Locked-order.Quantity*order.Price, order.Quantity*order.Price)
-			balance.Locked -= order.Quantity * order.Price
+			refund := (MarketsMap.Contract(stockSymbol).Payout - order.Price).Times(order.Quantity)
+			fmt.Printf("clearOrderBook: reverted order for %s, locked %s -> %s (refund %s)\n", order.UserId, balance.Locked, balance.Locked-refund, refund)
+			balance.Locked -= refund
+			balance.Balance += refund
 			USDBalances[order.UserId] = balance
 		}
 	case "regular":
@@ -134,11 +206,11 @@ func processOrder(order types.OrderBookEntry, stockSymbol string, stockType stri
 		if userStocks, exists := StockBalances[order.UserId]; exists {
 			if symbolStocks, exists := userStocks[stockSymbol]; exists {
 				if stockType == "yes" {
-					fmt.Printf("clearOrderBook: regular order for %s yes, locked %.2f -> %.2f, quantity %.2f -> %.2f (unlock %.2f)\n", order.UserId, symbolStocks.Yes.Locked, symbolStocks.Yes.Locked-order.Quantity, symbolStocks.Yes.Quantity, symbolStocks.Yes.Quantity+order.Quantity, order.Quantity)
+					fmt.Printf("clearOrderBook: regular order for %s yes, locked %d -> %d, quantity %d -> %d (unlock %d)\n", order.UserId, symbolStocks.Yes.Locked, symbolStocks.Yes.Locked-order.Quantity, symbolStocks.Yes.Quantity, symbolStocks.Yes.Quantity+order.Quantity, order.Quantity)
 					symbolStocks.Yes.Locked -= order.Quantity
 					symbolStocks.Yes.Quantity += order.Quantity
 				} else {
-					fmt.Printf("clearOrderBook: regular order for %s no, locked %.2f -> %.2f, quantity %.2f -> %.2f (unlock %.2f)\n", order.UserId, symbolStocks.No.Locked, symbolStocks.No.Locked-order.Quantity, symbolStocks.No.Quantity, symbolStocks.No.Quantity+order.Quantity, order.Quantity)
+					fmt.Printf("clearOrderBook: regular order for %s no, locked %d -> %d, quantity %d -> %d (unlock %d)\n", order.UserId, symbolStocks.No.Locked, symbolStocks.No.Locked-order.Quantity, symbolStocks.No.Quantity, symbolStocks.No.Quantity+order.Quantity, order.Quantity)
 					symbolStocks.No.Locked -= order.Quantity
 					symbolStocks.No.Quantity += order.Quantity
 				}
@@ -166,7 +238,7 @@ func sendUSDBalancesToDB() {
 		Data: json.RawMessage(fmt.Sprintf(`{"data":%s}`, mustMarshal(usdData))),
 	}
 	usdBytes, _ := json.Marshal(usdMsg)
-	engineToDatabaseQueueClient.Publish(context.Background(), "DB_ACTIONS", usdBytes)
+	engineToDatabaseQueueClient.LPush(context.Background(), types.DB_ACTIONS, usdBytes)
 
 	// Send stock balances update
 	stockData := make(map[string]interface{})
@@ -181,7 +253,7 @@ func sendUSDBalancesToDB() {
 		Data: json.RawMessage(fmt.Sprintf(`{"data":%s}`, mustMarshal(stockData))),
 	}
 	stockBytes, _ := json.Marshal(stockMsg)
-	engineToDatabaseQueueClient.Publish(context.Background(), "DB_ACTIONS", stockBytes)
+	engineToDatabaseQueueClient.LPush(context.Background(), types.DB_ACTIONS, stockBytes)
 }
 
 func mustMarshal(v interface{}) string {
@@ -189,44 +261,167 @@ func mustMarshal(v interface{}) string {
 	return string(data)
 }
 
-// CreateMarket creates a new prediction market
+// validateRule checks a market's resolution rule fits it: a condition for a
+// YES/NO market, a value for a scalar one, with every threshold fixed
+func validateRule(createReq types.CreateMarket) (rule.Rule, error) {
+	resolution, err := rule.Parse(createReq.Rule)
+	if err != nil {
+		return rule.Rule{}, err
+	}
+	switch {
+	case len(createReq.Outcomes) > 0:
+		return rule.Rule{}, fmt.Errorf("categorical markets can't resolve on a rule")
+	case createReq.Scalar != nil && !resolution.IsValue():
+		return rule.Rule{}, fmt.Errorf("rule %s is a condition, a scalar market's rule is a value such as price(%s)", resolution, resolution.Feed)
+	case createReq.Scalar == nil && resolution.IsValue():
+		return rule.Rule{}, fmt.Errorf("rule %s is a value, a YES/NO market's rule needs a condition such as > 100", resolution)
+	case resolution.NeedsOpen():
+		return rule.Rule{}, fmt.Errorf("rule %s has to be bound to the open price before the market is created", resolution)
+	}
+	return resolution, nil
+}
+
+// CreateMarket creates a new prediction market, OPEN unless asked for a DRAFT.
+// A categorical market gets a YES/NO market per outcome to trade in.
 func CreateMarket(createReq types.CreateMarket) error {
+	if _, exists := MarketsMap[createReq.Symbol]; exists {
+		return fmt.Errorf("market %s already exists", createReq.Symbol)
+	}
+	// ":" separates a categorical market from its outcomes
+	if strings.Contains(createReq.Symbol, ":") {
+		return fmt.Errorf("invalid symbol %q, symbols can't contain ':'", createReq.Symbol)
+	}
+	if err := createReq.Contract.Validate(); err != nil {
+		return fmt.Errorf("invalid contract: %v", err)
+	}
+	if err := fees.CheckSchedule(createReq.Contract.FeeSchedule); err != nil {
+		return fmt.Errorf("invalid contract: %v", err)
+	}
+	contract := createReq.Contract.WithDefaults()
+	outcomes, err := validateOutcomes(createReq.Outcomes)
+	if err != nil {
+		return err
+	}
+	if createReq.Scalar != nil {
+		if len(outcomes) > 0 {
+			return fmt.Errorf("a market can't be both scalar and categorical")
+		}
+		if err := createReq.Scalar.Validate(); err != nil {
+			return err
+		}
+	}
+	var ammB float64
+	var ammSubsidy types.Amount
+	if createReq.AMM != nil {
+		ammB, ammSubsidy, err = validateAMM(*createReq.AMM, contract.Payout)
+		if err != nil {
+			return err
+		}
+	}
+	if createReq.DisputeWindow < 0 {
+		return fmt.Errorf("invalid dispute window %d", createReq.DisputeWindow)
+	}
+	tradedSymbols := max(len(outcomes), 1)
+	bookSubsidy, err := checkFunding(createReq, contract.Payout, ammSubsidy, tradedSymbols)
+	if err != nil {
+		return err
+	}
+	if createReq.Rule != "" {
+		resolution, err := validateRule(createReq)
+		if err != nil {
+			return err
+		}
+		// Stored in canonical form, and asked as the heading if there's none
+		createReq.Rule = resolution.String()
+		if createReq.Heading == "" {
+			createReq.Heading = resolution.Heading()
+		}
+	}
+
 	// Generate market ID
 	marketId, err := gonanoid.New()
 	if err != nil {
 		return err
 	}
 
+	marketStockType := "YES_NO" // Default for prediction markets
+	if len(outcomes) > 0 {
+		marketStockType = "CATEGORICAL"
+	} else if createReq.Scalar != nil {
+		marketStockType = "LONG_SHORT"
+	}
+
 	// Create market record
 	market := types.Market{
 		Id:                marketId,
 		Symbol:            createReq.Symbol,
-		SymbolStockType:   "YES_NO", // Default for prediction markets
+		SymbolStockType:   marketStockType,
 		SourceOfTruth:     createReq.SourceOfTruth,
 		Heading:           createReq.Heading,
 		EventType:         createReq.EventType,
 		RepeatEventTime:   fmt.Sprintf("%d", createReq.RepeatEventTime),
 		EndEventAfterTime: fmt.Sprintf("%d", createReq.EndAfterTime),
+		Contract:          contract,
+		Outcomes:          outcomes,
+		Scalar:            createReq.Scalar,
+		Oracle:            createReq.Oracle,
+		Feed:              createReq.Feed,
+		Rule:              createReq.Rule,
 		CreatedAt:         time.Now().Format(time.RFC3339),
 		UpdatedAt:         time.Now().Format(time.RFC3339),
 	}
 
-	// Initialize order book for the market
-	if _, exists := OrderBook[createReq.Symbol]; !exists {
-		OrderBook[createReq.Symbol] = types.SymbolOrderBook{
-			Yes: make(types.PriceOrderBook),
-			No:  make(types.PriceOrderBook),
-		}
-	}
-
 	// Initialize market in MarketsMap
 	MarketsMap[createReq.Symbol] = types.EnhancedMarket{
 		StockSymbol: createReq.Symbol,
-		Price:       5.0, // Default starting price
+		Price:       5 * types.USD, // Default starting price
 		Heading:     createReq.Heading,
 		EventType:   createReq.EventType,
 		Type:        types.MarketType(createReq.MarketType),
-		Status:      types.MarketActive,
+		Status:      types.MarketDraft,
+		Contract:    contract,
+		Outcomes:    outcomes,
+		Scalar:      createReq.Scalar,
+		Oracle:      createReq.Oracle,
+		Feed:        createReq.Feed,
+		Rule:        createReq.Rule,
+		// Manual markets are disputable this long after an outcome is proposed
+		DisputeWindow: createReq.DisputeWindow,
+	}
+	for _, outcome := range outcomes {
+		outcomeSymbol := types.OutcomeSymbol(createReq.Symbol, outcome)
+		MarketsMap[outcomeSymbol] = types.EnhancedMarket{
+			StockSymbol: outcomeSymbol,
+			Price:       5 * types.USD,
+			Heading:     createReq.Heading + " - " + outcome,
+			EventType:   createReq.EventType,
+			Type:        types.MarketType(createReq.MarketType),
+			Status:      types.MarketDraft,
+			Contract:    contract,
+			Parent:      createReq.Symbol,
+		}
+	}
+
+	// Initialize order books for the market, with a market maker funded from
+	// the treasury on each: the LMSR market maker if one was asked for, the
+	// sell ladders' otherwise
+	for _, symbol := range TradedSymbols(createReq.Symbol) {
+		OrderBook.Symbol(symbol)
+		if createReq.AMM != nil {
+			addAMM(symbol, ammB, ammSubsidy)
+		} else {
+			addBookLP(symbol, bookSubsidy)
+		}
+	}
+	if createReq.EndsIn > 0 {
+		market := MarketsMap[createReq.Symbol]
+		market.ClosesAt = time.Now().UnixMilli() + createReq.EndsIn
+		MarketsMap[createReq.Symbol] = market
+	}
+	if !createReq.Draft {
+		if err := transition(createReq.Symbol, types.MarketOpen); err != nil {
+			return err
+		}
 	}
 
 	// Send market to database
@@ -239,71 +434,122 @@ func CreateMarket(createReq types.CreateMarket) error {
 		Data: marketData,
 	}
 	marketMsgBytes, _ := json.Marshal(marketMsg)
-	engineToDatabaseQueueClient.Publish(context.Background(), "DB_ACTIONS", marketMsgBytes)
+	engineToDatabaseQueueClient.LPush(context.Background(), types.DB_ACTIONS, marketMsgBytes)
 
 	return nil
 }
 
-// EndMarket settles a market and processes winnings
-func EndMarket(stockSymbol string, winningStock string) error {
-	// Update market status in MarketsMap
-	if market, exists := MarketsMap[stockSymbol]; exists {
-		market.Status = types.MarketCompleted
-		MarketsMap[stockSymbol] = market
+// validateOutcomes checks the outcome names of a categorical market and
+// returns them lowercased. No outcomes makes a YES/NO market.
+func validateOutcomes(outcomes []string) ([]string, error) {
+	if len(outcomes) == 0 {
+		return nil, nil
 	}
+	if len(outcomes) < 2 {
+		return nil, fmt.Errorf("a categorical market needs at least 2 outcomes")
+	}
+	names := make([]string, len(outcomes))
+	for i, outcome := range outcomes {
+		name := strings.ToLower(strings.TrimSpace(outcome))
+		if name == "" || strings.Contains(name, ":") {
+			return nil, fmt.Errorf("invalid outcome %q", outcome)
+		}
+		if slices.Contains(names[:i], name) {
+			return nil, fmt.Errorf("outcome %q is listed twice", outcome)
+		}
+		names[i] = name
+	}
+	return names, nil
+}
 
-	// Process winnings for all users
-	err := processWinnings(stockSymbol, strings.ToLower(winningStock))
-	if err != nil {
-		return fmt.Errorf("failed to process winnings: %v", err)
+// EndMarket settles a market and processes winnings. The market is closed
+// first if it is still trading, which cancels its resting orders, then
+// resolved and settled; a market settles only once. winningStock is yes or no
+// for a binary market and the winning outcome of a categorical one.
+func EndMarket(stockSymbol string, winningStock string) error {
+	market, exists := MarketsMap[stockSymbol]
+	if !exists {
+		return fmt.Errorf("market %s doesn't exist", stockSymbol)
+	}
+	if err := checkNotOutcome(stockSymbol); err != nil {
+		return err
+	}
+	winningStock = strings.ToLower(winningStock)
+	if !slices.Contains(market.OutcomeNames(), winningStock) {
+		return fmt.Errorf("invalid winning outcome %q, expected one of %s", winningStock, strings.Join(market.OutcomeNames(), ", "))
+	}
+	if market.Scalar != nil {
+		return fmt.Errorf("market %s is scalar, end it with a value", stockSymbol)
+	}
+	if err := startSettlement(stockSymbol); err != nil {
+		return err
 	}
 
-	// Process payouts for reverted buy orders on winning side
-	if orderBook, exists := OrderBook[stockSymbol]; exists {
-		var priceMap types.PriceOrderBook
-		if strings.ToLower(winningStock) == "yes" {
-			priceMap = orderBook.Yes
-		} else {
-			priceMap = orderBook.No
+	// Process winnings for all users. Every outcome of a categorical market is
+	// a YES/NO market of its own, won by YES for the winning outcome and by NO
+	// for the others.
+	if len(market.Outcomes) == 0 {
+		if err := processWinnings(stockSymbol, winningStock); err != nil {
+			return fmt.Errorf("failed to process winnings: %v", err)
 		}
-		for _, entry := range priceMap {
-			for _, order := range entry.Orders {
-				if order.Type == "reverted" {
-					if balance, exists := USDBalances[order.UserId]; exists {
-						balance.Balance += order.Quantity * 1000
-						USDBalances[order.UserId] = balance
-					}
-				}
-			}
+	}
+	for _, outcome := range market.Outcomes {
+		outcomeWinner := "no"
+		if outcome == winningStock {
+			outcomeWinner = "yes"
+		}
+		if err := processWinnings(types.OutcomeSymbol(stockSymbol, outcome), outcomeWinner); err != nil {
+			return fmt.Errorf("failed to process winnings: %v", err)
 		}
 	}
-	// Send balance update
-	sendUSDBalancesToDB()
 
-	// Clear the order book and unlock all balances
-	err = clearOrderBook(stockSymbol)
-	if err != nil {
-		return fmt.Errorf("failed to clear order book: %v", err)
+	return finishSettlement(&types.Settlement{
+		StockSymbol: stockSymbol,
+		Outcome:     winningStock,
+	}, MarketsMap.Contract(stockSymbol).Payout)
+}
+
+// startSettlement closes a market if it is still trading, which cancels its
+// resting orders, and starts resolving it unless it already is, as a manual
+// market is from its proposal on. A market settles only once.
+func startSettlement(stockSymbol string) error {
+	market := MarketsMap[stockSymbol]
+	if market.Status == types.MarketSettled {
+		return fmt.Errorf("market %s is already settled", stockSymbol)
+	}
+	if market.Status == types.MarketResolving {
+		return nil
+	}
+	if market.Status == types.MarketOpen || market.Status == types.MarketHalted {
+		if err := closeMarket(stockSymbol); err != nil {
+			return err
+		}
 	}
+	return transition(stockSymbol, types.MarketResolving)
+}
+
+// finishSettlement records a market's settlement once its payouts are made,
+// settles it and publishes the settlement. price is what a winning share paid.
+func finishSettlement(settlement *types.Settlement, price types.Amount) error {
+	stockSymbol := settlement.StockSymbol
 
 	// Update all pending orders for this symbol to cancelled
 	for i := range Orders {
-		if Orders[i].Symbol == stockSymbol && Orders[i].Status == types.PENDING {
+		if Orders[i].Symbol == stockSymbol && (Orders[i].Status == types.PENDING || Orders[i].Status == types.PARTIALLY_FILLED) {
 			Orders[i].Status = types.CANCELLED
 			Orders[i].UpdatedAt = time.Now().Format(time.RFC3339)
 
 			// Send order update to database
-			orderUpdate := map[string]interface{}{
-				"orderId": Orders[i].Id,
-				"status":  "CANCELLED",
-			}
-			orderBytes, _ := json.Marshal(orderUpdate)
+			orderBytes, _ := json.Marshal(types.OrderUpdate{
+				OrderId: Orders[i].Id,
+				Status:  types.CANCELLED,
+			})
 			orderMsg := types.IncomingMessage{
-				Type: "UPDATE_ORDER",
+				Type: types.UPDATE_ORDER,
 				Data: orderBytes,
 			}
 			orderMsgBytes, _ := json.Marshal(orderMsg)
-			engineToDatabaseQueueClient.Publish(context.Background(), "DB_ACTIONS", orderMsgBytes)
+			engineToDatabaseQueueClient.LPush(context.Background(), types.DB_ACTIONS, orderMsgBytes)
 		}
 	}
 
@@ -319,9 +565,9 @@ func EndMarket(stockSymbol string, winningStock string) error {
 		TakerId:         "SYSTEM",
 		TransectionType: types.CANCLE, // Using CANCLE as market settlement
 		Quantity:        1,
-		Price:           0,
+		Price:           price, // what each winning share paid
 		Symbol:          stockSymbol,
-		SymbolStockType: winningStock,
+		SymbolStockType: settlement.Outcome,
 		CreatedAt:       time.Now().Format(time.RFC3339),
 		UpdatedAt:       time.Now().Format(time.RFC3339),
 	}
@@ -334,7 +580,15 @@ func EndMarket(stockSymbol string, winningStock string) error {
 		Data: transectionData,
 	}
 	transectionMsgBytes, _ := json.Marshal(transectionMsg)
-	engineToDatabaseQueueClient.Publish(context.Background(), "DB_ACTIONS", transectionMsgBytes)
+	engineToDatabaseQueueClient.LPush(context.Background(), types.DB_ACTIONS, transectionMsgBytes)
 
+	if err := transition(stockSymbol, types.MarketSettled); err != nil {
+		return err
+	}
+	defundLPs(stockSymbol)
+	sendUSDBalancesToDB()
+	settlement.Status = types.MarketSettled
+	settlement.At = time.Now().Format(time.RFC3339)
+	publishSettlement(*settlement)
 	return nil
 }
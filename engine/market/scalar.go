@@ -0,0 +1,46 @@
+package market
+
+import (
+	"fmt"
+
+	types "github.com/adityadeshlahre/probo-v1/shared/types"
+)
+
+// A scalar market asks where a value lands between a lower and an upper
+// bound. It trades in the usual YES/NO book, with LONG shares as YES and
+// SHORT shares as NO, and settles both sides pro rata instead of paying one
+// side the whole payout.
+
+// EndScalarMarket settles a scalar market on the value its oracle reported.
+// Each LONG share pays its part of the payout according to where value lands
+// in the market's range, and each SHORT share the rest.
+func EndScalarMarket(stockSymbol string, value float64) (types.Settlement, error) {
+	market, exists := MarketsMap[stockSymbol]
+	if !exists {
+		return types.Settlement{}, fmt.Errorf("market %s doesn't exist", stockSymbol)
+	}
+	if market.Scalar == nil {
+		return types.Settlement{}, fmt.Errorf("market %s isn't scalar, end it with a winning stock", stockSymbol)
+	}
+	if err := startSettlement(stockSymbol); err != nil {
+		return types.Settlement{}, err
+	}
+
+	payout := MarketsMap.Contract(stockSymbol).Payout
+	longPayout := market.Scalar.LongPayout(value, payout)
+	shortPayout := payout - longPayout
+	fmt.Printf("Settling scalar market %s at %v: LONG pays %s, SHORT pays %s\n", stockSymbol, value, longPayout, shortPayout)
+	if err := processPayouts(stockSymbol, longPayout, shortPayout); err != nil {
+		return types.Settlement{}, fmt.Errorf("failed to process payouts: %v", err)
+	}
+
+	settlement := types.Settlement{
+		StockSymbol: stockSymbol,
+		Value:       &value,
+		Payouts:     map[string]types.Amount{"long": longPayout, "short": shortPayout},
+	}
+	if err := finishSettlement(&settlement, longPayout); err != nil {
+		return types.Settlement{}, err
+	}
+	return settlement, nil
+}
@@ -0,0 +1,218 @@
+package market
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	types "github.com/adityadeshlahre/probo-v1/shared/types"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+// A complete set is one share of every outcome of a market: a YES and a NO
+// share of a binary market, or a YES share of every outcome of a categorical
+// one. Exactly one of them pays out the market's payout, so a set is always
+// worth the payout: splitting turns USD into sets at that price and merging
+// turns sets back into USD, without going through the order book.
+
+// setLeg is one share of a complete set, a stock type of a traded symbol
+type setLeg struct {
+	symbol    string
+	stockType string
+}
+
+// setLegs returns the shares a complete set of a market is made of
+func setLegs(stockSymbol string) []setLeg {
+	market := MarketsMap[stockSymbol]
+	if len(market.Outcomes) == 0 {
+		return []setLeg{{stockSymbol, "yes"}, {stockSymbol, "no"}}
+	}
+	legs := make([]setLeg, len(market.Outcomes))
+	for i, outcome := range market.Outcomes {
+		legs[i] = setLeg{types.OutcomeSymbol(stockSymbol, outcome), "yes"}
+	}
+	return legs
+}
+
+// SplitSet turns quantity × payout USD into quantity shares of every outcome
+func SplitSet(req types.CompleteSetProps) (map[string]interface{}, error) {
+	if err := validateSet(req); err != nil {
+		return nil, err
+	}
+	contract := MarketsMap.Contract(req.StockSymbol)
+	legs := setLegs(req.StockSymbol)
+	for _, leg := range legs {
+		symbolStocks := userSymbolStocks(req.UserId, leg.symbol)
+		position := symbolStocks.Side(leg.stockType)
+		if err := contract.CheckPosition(position.Quantity + position.Locked + req.Quantity); err != nil {
+			return nil, err
+		}
+	}
+
+	cost := contract.Payout.Times(req.Quantity)
+	if cost <= 0 {
+		return nil, fmt.Errorf("invalid split, %d sets would cost %s", req.Quantity, cost)
+	}
+	userBalance := USDBalances[req.UserId]
+	if userBalance.Balance < cost {
+		return nil, fmt.Errorf("insufficient balance, splitting %d sets costs %s", req.Quantity, cost)
+	}
+
+	userBalance.Balance -= cost
+	USDBalances[req.UserId] = userBalance
+
+	for i, legCost := range splitAmount(cost, len(legs)) {
+		symbolStocks := userSymbolStocks(req.UserId, legs[i].symbol)
+		position := symbolStocks.Side(legs[i].stockType)
+		position.Quantity += req.Quantity
+		position.Cost += legCost
+		StockBalances[req.UserId][legs[i].symbol] = symbolStocks
+	}
+
+	fmt.Printf("SplitSet: %s split %s into %d sets of %s\n", req.UserId, cost, req.Quantity, req.StockSymbol)
+	recordSet(types.SPLIT, req, cost)
+	sendUSDBalancesToDB()
+
+	return setResult(req, cost, fmt.Sprintf("Split %s into %d shares of each of %d outcomes", cost, req.Quantity, len(legs))), nil
+}
+
+// MergeSet turns quantity shares of every outcome back into quantity × payout
+// USD. Only free shares can be merged, not ones locked in sell orders.
+func MergeSet(req types.CompleteSetProps) (map[string]interface{}, error) {
+	if err := validateSet(req); err != nil {
+		return nil, err
+	}
+
+	legs := setLegs(req.StockSymbol)
+	for _, leg := range legs {
+		symbolStocks := userSymbolStocks(req.UserId, leg.symbol)
+		if symbolStocks.Side(leg.stockType).Quantity < req.Quantity {
+			return nil, fmt.Errorf("user doesn't have %d free shares of every outcome to merge", req.Quantity)
+		}
+	}
+
+	payout := MarketsMap.Contract(req.StockSymbol).Payout.Times(req.Quantity)
+	for i, legPayout := range splitAmount(payout, len(legs)) {
+		symbolStocks := userSymbolStocks(req.UserId, legs[i].symbol)
+		position := symbolStocks.Side(legs[i].stockType)
+		position.Quantity -= req.Quantity
+		position.Cost -= legPayout
+		StockBalances[req.UserId][legs[i].symbol] = symbolStocks
+	}
+
+	userBalance := USDBalances[req.UserId]
+	userBalance.Balance += payout
+	USDBalances[req.UserId] = userBalance
+
+	fmt.Printf("MergeSet: %s merged %d sets of %s into %s\n", req.UserId, req.Quantity, req.StockSymbol, payout)
+	recordSet(types.MERGE, req, payout)
+	sendUSDBalancesToDB()
+
+	return setResult(req, payout, fmt.Sprintf("Merged %d shares of each of %d outcomes into %s", req.Quantity, len(legs), payout)), nil
+}
+
+// splitAmount divides amount into n parts as evenly as cents allow, the
+// first parts taking the leftover cents
+func splitAmount(amount types.Amount, n int) []types.Amount {
+	parts := make([]types.Amount, n)
+	for i := range parts {
+		parts[i] = amount / types.Amount(n)
+		if types.Amount(i) < amount%types.Amount(n) {
+			parts[i]++
+		}
+	}
+	return parts
+}
+
+// validateSet checks the user, quantity and market of a split or merge
+func validateSet(req types.CompleteSetProps) error {
+	if err := types.CheckShares(req.Quantity); err != nil {
+		return err
+	}
+	if _, exists := USDBalances[req.UserId]; !exists {
+		return fmt.Errorf("user with the given id doesn't exist")
+	}
+	// Categorical markets trade in their outcomes, but sets are split from the market itself
+	if market, exists := MarketsMap[req.StockSymbol]; exists && len(market.Outcomes) > 0 {
+		if market.Status != types.MarketOpen {
+			return fmt.Errorf("market %s is %s, not taking orders", req.StockSymbol, market.Status)
+		}
+		return nil
+	}
+	return MarketsMap.CheckOpen(req.StockSymbol)
+}
+
+// userSymbolStocks returns the user's stocks of a symbol, creating empty
+// balances if they have none yet
+func userSymbolStocks(userId, stockSymbol string) types.SymbolStockBalance {
+	if _, exists := StockBalances[userId]; !exists {
+		StockBalances[userId] = make(types.UserStockBalance)
+	}
+	return StockBalances[userId][stockSymbol]
+}
+
+// recordSet adds a split or merge to the ledger and sends it to the database
+func recordSet(transectionType types.TransectionType, req types.CompleteSetProps, amount types.Amount) {
+	transectionId, _ := gonanoid.New()
+	transection := types.Transection{
+		Id:              transectionId,
+		MakerId:         req.UserId,
+		TakerId:         req.UserId,
+		TransectionType: transectionType,
+		Quantity:        req.Quantity,
+		Price:           MarketsMap.Contract(req.StockSymbol).Payout,
+		Amount:          amount,
+		Symbol:          req.StockSymbol,
+		SymbolStockType: symbolStockType(req.StockSymbol),
+		CreatedAt:       time.Now().Format(time.RFC3339),
+		UpdatedAt:       time.Now().Format(time.RFC3339),
+	}
+	Transections = append(Transections, transection)
+
+	transectionData, _ := json.Marshal(transection)
+	transectionMsg := types.IncomingMessage{
+		Type: types.TRANSECTION,
+		Data: transectionData,
+	}
+	transectionMsgBytes, _ := json.Marshal(transectionMsg)
+	engineToDatabaseQueueClient.LPush(context.Background(), types.DB_ACTIONS, transectionMsgBytes)
+}
+
+// setResult builds the response for a split or merge
+func setResult(req types.CompleteSetProps, amount types.Amount, message string) map[string]interface{} {
+	return map[string]interface{}{
+		"status":      true,
+		"userId":      req.UserId,
+		"stockSymbol": req.StockSymbol,
+		"quantity":    req.Quantity,
+		"amount":      amount,
+		"balance":     USDBalances[req.UserId],
+		"stocks":      setStocks(req.UserId, req.StockSymbol),
+		"message":     message,
+	}
+}
+
+// setStocks returns the user's stocks of a market, by outcome symbol for a
+// categorical one
+func setStocks(userId, stockSymbol string) interface{} {
+	if len(MarketsMap[stockSymbol].Outcomes) == 0 {
+		return StockBalances[userId][stockSymbol]
+	}
+	stocks := make(types.UserStockBalance)
+	for _, symbol := range TradedSymbols(stockSymbol) {
+		stocks[symbol] = StockBalances[userId][symbol]
+	}
+	return stocks
+}
+
+// symbolStockType is how ledger records name the kind of shares of a market
+func symbolStockType(stockSymbol string) string {
+	if len(MarketsMap[stockSymbol].Outcomes) > 0 {
+		return "CATEGORICAL"
+	}
+	if MarketsMap[stockSymbol].Scalar != nil {
+		return "LONG_SHORT"
+	}
+	return "YES_NO"
+}
@@ -0,0 +1,23 @@
+package market
+
+import (
+	"fmt"
+
+	types "github.com/adityadeshlahre/probo-v1/shared/types"
+)
+
+// CheckNewUser checks a user can sign up with an id: it isn't one of the
+// platform's own accounts or a resolution admin's, and nobody has it yet.
+// Signing up again would reset the user's balance.
+func CheckNewUser(userId string) error {
+	if types.PlatformAccount(userId) || ResolutionAdmin(userId) {
+		return fmt.Errorf("user id %s is reserved", userId)
+	}
+	if _, exists := USDBalances[userId]; exists {
+		return fmt.Errorf("user %s already exists", userId)
+	}
+	if _, exists := StockBalances[userId]; exists {
+		return fmt.Errorf("user %s already exists", userId)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package market
+
+import (
+	"strings"
+	"testing"
+
+	types "github.com/adityadeshlahre/probo-v1/shared/types"
+)
+
+func TestCheckNewUser(t *testing.T) {
+	setupMarkets(t, "TEST")
+	admins := resolutionAdmins
+	resolutionAdmins = []string{"judge"}
+	t.Cleanup(func() { resolutionAdmins = admins })
+	USDBalances["spender"] = types.USDBalance{Balance: 5 * types.USD}
+	StockBalances["holder"] = types.UserStockBalance{"TEST": {Yes: types.StockPosition{Quantity: 1}}}
+
+	for _, test := range []struct {
+		userId string
+		want   string // in the error, empty for none
+	}{
+		{"newcomer", ""},
+		{types.TreasuryAccount, "reserved"},
+		{types.FeeAccount, "reserved"},
+		{types.LPAccount("TEST"), "reserved"},
+		{types.AMMAccount("TEST"), "reserved"},
+		{"judge", "reserved"},
+		{"spender", "already exists"},
+		{"holder", "already exists"},
+	} {
+		err := CheckNewUser(test.userId)
+		if test.want == "" && err != nil {
+			t.Errorf("%s: %v", test.userId, err)
+		}
+		if test.want != "" && (err == nil || !strings.Contains(err.Error(), test.want)) {
+			t.Errorf("%s: got %v, want an error with %q", test.userId, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package market
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	types "github.com/adityadeshlahre/probo-v1/shared/types"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+// VoidMarket annuls a market whose event was cancelled. Every resting order
+// is cancelled, then every position is unwound to what its owner paid for it
+// net of sales, with a REFUND transection per user. The money in a market is
+// exactly what its users paid in, so the refunds always add up: someone who
+// sold for more than they paid gives the profit back in full, out of their
+// free balance. Their orders in other markets are left alone, so whatever that
+// doesn't cover is a debt, recorded as a DEBT transection and left as a
+// negative balance their next deposits pay off.
+func VoidMarket(stockSymbol string) (map[string]types.Amount, error) {
+	if err := checkNotOutcome(stockSymbol); err != nil {
+		return nil, err
+	}
+	if err := transition(stockSymbol, types.MarketVoided); err != nil {
+		return nil, err
+	}
+	symbols := TradedSymbols(stockSymbol)
+	for _, symbol := range symbols {
+		if err := clearOrderBook(symbol); err != nil {
+			return nil, err
+		}
+	}
+
+	refunds := make(map[string]types.Amount)
+	debts := make(map[string]types.Amount)
+	for userId, userStocks := range StockBalances {
+		// A categorical market's positions are spread over its outcomes
+		var refund types.Amount
+		var quantity types.Shares
+		held := false
+		for _, symbol := range symbols {
+			symbolStocks, exists := userStocks[symbol]
+			if !exists {
+				continue
+			}
+			held = true
+			refund += symbolStocks.Cost()
+			quantity += symbolStocks.Yes.Quantity + symbolStocks.No.Quantity
+			delete(userStocks, symbol)
+		}
+		if !held {
+			continue
+		}
+		StockBalances[userId] = userStocks
+
+		balance := USDBalances[userId]
+		if free := max(balance.Balance, 0); refund < 0 && -refund > free {
+			debts[userId] = -refund - free
+			fmt.Printf("VoidMarket: %s owes %s but only has %s free, %s is a debt\n", userId, -refund, free, debts[userId])
+		}
+		balance.Balance += refund
+		USDBalances[userId] = balance
+
+		if refund != 0 {
+			refunds[userId] = refund
+			recordVoid(userId, stockSymbol, types.REFUND, quantity, refund)
+		}
+		if debt, exists := debts[userId]; exists {
+			recordVoid(userId, stockSymbol, types.DEBT, 0, debt)
+		}
+	}
+
+	// Nothing was decided, so nobody loses a dispute bond
+	releaseBonds(stockSymbol)
+	defundLPs(stockSymbol)
+	sendUSDBalancesToDB()
+	publishSettlement(types.Settlement{
+		StockSymbol: stockSymbol,
+		Status:      types.MarketVoided,
+		Refunds:     refunds,
+		Debts:       debts,
+		At:          time.Now().Format(time.RFC3339),
+	})
+
+	return refunds, nil
+}
+
+// recordVoid adds a user's void refund, or the debt it left them with, to the
+// ledger and sends it to the database
+func recordVoid(userId, stockSymbol string, transectionType types.TransectionType, quantity types.Shares, amount types.Amount) {
+	transectionId, _ := gonanoid.New()
+	transection := types.Transection{
+		Id:              transectionId,
+		MakerId:         "SYSTEM",
+		TakerId:         userId,
+		TransectionType: transectionType,
+		Quantity:        quantity,
+		Amount:          amount,
+		Symbol:          stockSymbol,
+		SymbolStockType: symbolStockType(stockSymbol),
+		CreatedAt:       time.Now().Format(time.RFC3339),
+		UpdatedAt:       time.Now().Format(time.RFC3339),
+	}
+	Transections = append(Transections, transection)
+
+	transectionData, _ := json.Marshal(transection)
+	transectionMsg := types.IncomingMessage{
+		Type: types.TRANSECTION,
+		Data: transectionData,
+	}
+	transectionMsgBytes, _ := json.Marshal(transectionMsg)
+	engineToDatabaseQueueClient.LPush(context.Background(), types.DB_ACTIONS, transectionMsgBytes)
+}
+
+// publishSettlement tells subscribers of a symbol how its market finished
+func publishSettlement(settlement types.Settlement) {
+	settlementData, _ := json.Marshal(settlement)
+	settlementMsg := types.IncomingMessage{
+		Type: "SETTLEMENT",
+		Data: settlementData,
+	}
+	settlementBytes, _ := json.Marshal(settlementMsg)
+	engineToServerPubSubClient.Publish(context.Background(), types.SettlementsChannel(settlement.StockSymbol), settlementBytes)
+}
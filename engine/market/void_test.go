@@ -0,0 +1,153 @@
+package market
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/adityadeshlahre/probo-v1/engine/fees"
+	"github.com/adityadeshlahre/probo-v1/engine/orderbook"
+	"github.com/adityadeshlahre/probo-v1/engine/trading"
+	types "github.com/adityadeshlahre/probo-v1/shared/types"
+	"github.com/redis/go-redis/v9"
+)
+
+// setupMarkets gives the market and trading packages the same fresh
+// balances, books and open markets, with Redis never reachable so publishing
+// fails straight away
+func setupMarkets(t *testing.T, symbols ...string) {
+	t.Helper()
+	offline := redis.NewClient(&redis.Options{
+		Dialer: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return nil, errors.New("offline")
+		},
+		MaxRetries: -1,
+	})
+	usdBalances := make(types.USDBalances)
+	stockBalances := make(types.StockBalances)
+	orderBook := make(orderbook.Books)
+	orderRegistry := make(orderbook.Registry)
+	markets := make(types.Markets)
+	for _, symbol := range symbols {
+		markets[symbol] = types.EnhancedMarket{StockSymbol: symbol, Status: types.MarketOpen}
+	}
+	var orders []types.Order
+	var transections []types.Transection
+	SetClients(offline, offline)
+	SetDataStructures(usdBalances, stockBalances, orderBook, orderRegistry, markets, &orders, &transections)
+	trading.SetClients(offline, offline)
+	trading.SetDataStructures(usdBalances, stockBalances, orderBook, orderRegistry, markets)
+	orderbook.SetDataStructures(orderBook, orderRegistry)
+	fees.SetDataStructures(usdBalances, markets)
+}
+
+// totalUSD is every account's USD, free and locked
+func totalUSD() types.Amount {
+	var total types.Amount
+	for _, balance := range USDBalances {
+		total += balance.Balance + balance.Locked
+	}
+	return total
+}
+
+func placeOrder(t *testing.T, isBuy bool, userId, stockSymbol, stockType string, price types.Amount, quantity types.Shares) map[string]interface{} {
+	t.Helper()
+	order := types.OrderProps{UserId: userId, StockSymbol: stockSymbol, StockType: stockType, Price: price, Quantity: quantity}
+	place := trading.PlaceSellOrder
+	if isBuy {
+		place = trading.PlaceBuyOrder
+	}
+	result, err := place(order)
+	if err != nil {
+		t.Fatalf("%+v: %v", order, err)
+	}
+	return result
+}
+
+func TestVoidConservesUSD(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		profiteer   types.Amount // what the user who sells at a profit starts with
+		otherMarket func(t *testing.T)
+		wantDebt    types.Amount
+		wantLocked  types.Amount // still in its buy orders in the other market
+	}{
+		{
+			name:      "clawback out of the free balance",
+			profiteer: 100 * types.USD,
+		},
+		{
+			name:      "a buy order in another market is left alone",
+			profiteer: 100 * types.USD,
+			otherMarket: func(t *testing.T) {
+				// 150.00 of the 180.00 it has goes into a resting bid
+				placeOrder(t, true, "profiteer", "OTHER", "yes", 50*types.USD, 3)
+			},
+			wantDebt:   50 * types.USD,
+			wantLocked: 150 * types.USD,
+		},
+		{
+			name:      "clawback beyond what the user has",
+			profiteer: 10 * types.USD,
+			otherMarket: func(t *testing.T) {
+				// 60.00 of the 90.00 it has goes into shares of another market
+				USDBalances["holder"] = types.USDBalance{}
+				StockBalances["holder"] = types.UserStockBalance{"OTHER": {Yes: types.StockPosition{Quantity: 1}}}
+				placeOrder(t, false, "holder", "OTHER", "yes", 60*types.USD, 1)
+				placeOrder(t, true, "profiteer", "OTHER", "yes", 60*types.USD, 1)
+			},
+			wantDebt: 50 * types.USD,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			setupMarkets(t, "VOID", "OTHER")
+			USDBalances["profiteer"] = types.USDBalance{Balance: test.profiteer}
+			USDBalances["minter"] = types.USDBalance{Balance: 100 * types.USD}
+			USDBalances["buyer"] = types.USDBalance{Balance: 100 * types.USD}
+			before := totalUSD()
+
+			// The profiteer mints a YES at 10 and sells it at 90
+			placeOrder(t, true, "minter", "VOID", "no", 90*types.USD, 1)
+			placeOrder(t, true, "profiteer", "VOID", "yes", 10*types.USD, 1)
+			placeOrder(t, true, "buyer", "VOID", "yes", 90*types.USD, 1)
+			placeOrder(t, false, "profiteer", "VOID", "yes", 90*types.USD, 1)
+			if test.otherMarket != nil {
+				test.otherMarket(t)
+			}
+
+			refunds, err := VoidMarket("VOID")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := refunds["profiteer"]; got != -80*types.USD {
+				t.Errorf("profiteer refunded %s, want -80.00", got)
+			}
+			if after := totalUSD(); after != before {
+				t.Errorf("%s USD after the void, want the %s there was before trading", after, before)
+			}
+			balance := USDBalances["profiteer"]
+			if balance.Locked != test.wantLocked {
+				t.Errorf("profiteer has %s locked, want %s", balance.Locked, test.wantLocked)
+			}
+			if got := OrderBook.Symbol("OTHER").No.AvailableQuantity(types.MaxPrice, "reverted"); got != types.Shares(test.wantLocked/(50*types.USD)) {
+				t.Errorf("%d shares of buy orders rest in the other market, want them untouched", got)
+			}
+			if got := max(-balance.Balance, 0); got != test.wantDebt {
+				t.Errorf("profiteer owes %s, want %s", got, test.wantDebt)
+			}
+			var debts []types.Transection
+			for _, transection := range Transections {
+				if transection.TransectionType == types.DEBT {
+					debts = append(debts, transection)
+				}
+			}
+			if test.wantDebt == 0 && len(debts) != 0 {
+				t.Errorf("recorded debts %+v, want none", debts)
+			}
+			if test.wantDebt != 0 && (len(debts) != 1 || debts[0].TakerId != "profiteer" || debts[0].Amount != test.wantDebt) {
+				t.Errorf("recorded debts %+v, want the profiteer's %s", debts, test.wantDebt)
+			}
+		})
+	}
+}
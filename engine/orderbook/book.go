@@ -0,0 +1,300 @@
+package orderbook
+
+import (
+	"iter"
+	"sort"
+
+	types "github.com/adityadeshlahre/probo-v1/shared/types"
+)
+
+// Book is one side (yes or no) of a symbol's order book. Price levels are
+// kept sorted by price, each level is a first-in first-out queue of orders and
+// every order is indexed by id, so the best price is the first level, matching
+// walks orders in arrival order and cancelling an order doesn't search for it.
+type Book struct {
+	prices   []types.Amount // ascending, one per level
+	levels   map[types.Amount]*Level
+	orders   map[string]*Order
+	expiring map[string]*Order // GTD orders
+}
+
+// Level is the queue of orders resting at one price
+type Level struct {
+	Price    types.Amount
+	Total    types.Shares
+	Reverted types.Shares // part of Total held by reverted orders
+	head     *Order
+	tail     *Order
+}
+
+// Order is a resting order in a Book
+type Order struct {
+	Id string
+	types.OrderBookEntry
+	level *Level
+	prev  *Order
+	next  *Order
+}
+
+// Books holds the yes and no side of every symbol
+type Books map[string]*SymbolBook
+
+// SymbolBook is the order book of one symbol
+type SymbolBook struct {
+	Yes *Book
+	No  *Book
+}
+
+func NewBook() *Book {
+	return &Book{
+		levels:   make(map[types.Amount]*Level),
+		orders:   make(map[string]*Order),
+		expiring: make(map[string]*Order),
+	}
+}
+
+func NewSymbolBook() *SymbolBook {
+	return &SymbolBook{Yes: NewBook(), No: NewBook()}
+}
+
+// Side returns the book of a stock type, "yes" or "no"
+func (s *SymbolBook) Side(stockType string) *Book {
+	if stockType == "yes" {
+		return s.Yes
+	}
+	return s.No
+}
+
+// Snapshot returns the symbol's order book in its JSON shape
+func (s *SymbolBook) Snapshot() types.SymbolOrderBook {
+	return types.SymbolOrderBook{Yes: s.Yes.Snapshot(), No: s.No.Snapshot()}
+}
+
+// Symbol returns the order book of a symbol, creating it if needed
+func (b Books) Symbol(symbol string) *SymbolBook {
+	symbolBook, exists := b[symbol]
+	if !exists {
+		symbolBook = NewSymbolBook()
+		b[symbol] = symbolBook
+	}
+	return symbolBook
+}
+
+// Snapshot returns every symbol's order book in its JSON shape
+func (b Books) Snapshot() types.YesNoOrderBook {
+	snapshot := make(types.YesNoOrderBook, len(b))
+	for symbol, symbolBook := range b {
+		snapshot[symbol] = symbolBook.Snapshot()
+	}
+	return snapshot
+}
+
+// Add rests an order at the back of its price level's queue. The entry's
+// Sequence is set to its arrival number.
+func (b *Book) Add(orderId string, entry types.OrderBookEntry) *Order {
+	if existing, exists := b.orders[orderId]; exists {
+		b.remove(existing)
+	}
+
+	level, exists := b.levels[entry.Price]
+	if !exists {
+		level = &Level{Price: entry.Price}
+		b.levels[entry.Price] = level
+		i := sort.Search(len(b.prices), func(i int) bool { return b.prices[i] >= entry.Price })
+		b.prices = append(b.prices, 0)
+		copy(b.prices[i+1:], b.prices[i:])
+		b.prices[i] = entry.Price
+	}
+
+	entry.Sequence = NextSequence()
+	order := &Order{Id: orderId, OrderBookEntry: entry, level: level, prev: level.tail}
+	if level.tail != nil {
+		level.tail.next = order
+	} else {
+		level.head = order
+	}
+	level.tail = order
+	level.add(order, entry.Quantity)
+	b.orders[orderId] = order
+	if entry.ExpiresAt != 0 {
+		b.expiring[orderId] = order
+	}
+	return order
+}
+
+// Get returns a resting order by id
+func (b *Book) Get(orderId string) (*Order, bool) {
+	order, exists := b.orders[orderId]
+	return order, exists
+}
+
+// Remove takes an order out of the book, dropping its level once empty
+func (b *Book) Remove(orderId string) (*Order, bool) {
+	order, exists := b.orders[orderId]
+	if !exists {
+		return nil, false
+	}
+	b.remove(order)
+	return order, true
+}
+
+// Fill takes quantity off a resting order, removing it once nothing is left.
+// It returns the quantity the order still has.
+func (b *Book) Fill(orderId string, quantity types.Shares) types.Shares {
+	order, exists := b.orders[orderId]
+	if !exists {
+		return 0
+	}
+	quantity = min(quantity, order.Quantity)
+	order.Quantity -= quantity
+	order.level.add(order, -quantity)
+	if order.Quantity <= 0 {
+		b.remove(order)
+	}
+	return order.Quantity
+}
+
+func (b *Book) remove(order *Order) {
+	level := order.level
+	level.add(order, -order.Quantity)
+	if order.prev != nil {
+		order.prev.next = order.next
+	} else {
+		level.head = order.next
+	}
+	if order.next != nil {
+		order.next.prev = order.prev
+	} else {
+		level.tail = order.prev
+	}
+	order.prev, order.next = nil, nil
+	delete(b.orders, order.Id)
+	delete(b.expiring, order.Id)
+
+	if level.head == nil {
+		delete(b.levels, level.Price)
+		i := sort.Search(len(b.prices), func(i int) bool { return b.prices[i] >= level.Price })
+		if i < len(b.prices) && b.prices[i] == level.Price {
+			b.prices = append(b.prices[:i], b.prices[i+1:]...)
+		}
+	}
+}
+
+func (l *Level) add(order *Order, quantity types.Shares) {
+	l.Total += quantity
+	if order.Type == "reverted" {
+		l.Reverted += quantity
+	}
+}
+
+// Front returns the oldest order of the level
+func (l *Level) Front() *Order {
+	return l.head
+}
+
+// Next returns the order that arrived after o at the same price
+func (o *Order) Next() *Order {
+	return o.next
+}
+
+// Len returns the number of resting orders
+func (b *Book) Len() int {
+	return len(b.orders)
+}
+
+// Best returns the lowest price holding orders of entryType ("reverted" or
+// "regular", "" for any), ok is false when there is none
+func (b *Book) Best(entryType string) (types.Amount, bool) {
+	for _, price := range b.prices {
+		if b.levels[price].quantity(entryType) > 0 {
+			return price, true
+		}
+	}
+	return 0, false
+}
+
+// Crossing iterates over the levels priced at or below limit, best (lowest)
+// first. Orders of the level being visited may be filled or removed.
+func (b *Book) Crossing(limit types.Amount) iter.Seq[*Level] {
+	return func(yield func(*Level) bool) {
+		i := 0
+		for i < len(b.prices) && b.prices[i] <= limit {
+			level := b.levels[b.prices[i]]
+			if !yield(level) {
+				return
+			}
+			// the level is gone if it was emptied, pick up at the next price
+			i = sort.Search(len(b.prices), func(i int) bool { return b.prices[i] > level.Price })
+		}
+	}
+}
+
+// AvailableQuantity returns the quantity of entryType orders ("" for any)
+// resting at or below limit
+func (b *Book) AvailableQuantity(limit types.Amount, entryType string) types.Shares {
+	var total types.Shares
+	for _, price := range b.prices {
+		if price > limit {
+			break
+		}
+		total += b.levels[price].quantity(entryType)
+	}
+	return total
+}
+
+func (l *Level) quantity(entryType string) types.Shares {
+	switch entryType {
+	case "":
+		return l.Total
+	case "reverted":
+		return l.Reverted
+	default:
+		return l.Total - l.Reverted
+	}
+}
+
+// Orders returns every resting order, by price then arrival
+func (b *Book) Orders() []*Order {
+	orders := make([]*Order, 0, len(b.orders))
+	for _, price := range b.prices {
+		for order := b.levels[price].head; order != nil; order = order.next {
+			orders = append(orders, order)
+		}
+	}
+	return orders
+}
+
+// Expired returns the GTD orders whose expiry is at or before nowMillis,
+// soonest expiry first and in arrival order within one
+func (b *Book) Expired(nowMillis int64) []*Order {
+	var expired []*Order
+	for _, order := range b.expiring {
+		if order.ExpiresAt <= nowMillis {
+			expired = append(expired, order)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool {
+		if expired[i].ExpiresAt != expired[j].ExpiresAt {
+			return expired[i].ExpiresAt < expired[j].ExpiresAt
+		}
+		return expired[i].Sequence < expired[j].Sequence
+	})
+	return expired
+}
+
+// Snapshot returns the book in its JSON shape
+func (b *Book) Snapshot() types.PriceOrderBook {
+	snapshot := make(types.PriceOrderBook, len(b.prices))
+	for _, price := range b.prices {
+		level := b.levels[price]
+		priceLevel := types.PriceLevel{
+			Total:  level.Total,
+			Orders: make(map[string]types.OrderBookEntry),
+		}
+		for order := level.head; order != nil; order = order.next {
+			priceLevel.Orders[order.Id] = order.OrderBookEntry
+		}
+		snapshot[price] = priceLevel
+	}
+	return snapshot
+}
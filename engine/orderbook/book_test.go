@@ -0,0 +1,500 @@
+package orderbook
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"sort"
+	"testing"
+
+	types "github.com/adityadeshlahre/probo-v1/shared/types"
+)
+
+// The benchmarks compare Book against the map based book it replaced
+// (types.PriceOrderBook driven the way trading used to drive it) on the
+// three operations the engine does the most: resting an order, cancelling
+// one and sweeping the book with an incoming order.
+//
+//	go test ./orderbook -bench . -benchmem
+
+var benchSizes = []int{1_000, 10_000, 100_000}
+
+type benchOrder struct {
+	id       string
+	price    types.Amount
+	quantity types.Shares
+}
+
+// benchOrders returns n orders spread over 1000 price levels between 1.00
+// and 10.99, in random arrival order
+func benchOrders(n int) []benchOrder {
+	r := rand.New(rand.NewSource(1))
+	orders := make([]benchOrder, n)
+	for i := range orders {
+		orders[i] = benchOrder{
+			id:       fmt.Sprintf("order-%d", i),
+			price:    types.Amount(100 + r.Intn(1000)),
+			quantity: types.Shares(1 + r.Intn(10)),
+		}
+	}
+	return orders
+}
+
+func fillBook(orders []benchOrder) *Book {
+	book := NewBook()
+	for _, o := range orders {
+		book.Add(o.id, types.OrderBookEntry{Quantity: o.quantity, Price: o.price, Type: "regular"})
+	}
+	return book
+}
+
+// map based book, as it was before Book
+
+func mapAdd(priceMap types.PriceOrderBook, o benchOrder) {
+	level, exists := priceMap[o.price]
+	if !exists {
+		level = types.PriceLevel{Orders: make(map[string]types.OrderBookEntry)}
+	}
+	level.Total += o.quantity
+	level.Orders[o.id] = types.OrderBookEntry{Quantity: o.quantity, Price: o.price, Type: "regular", Sequence: NextSequence()}
+	priceMap[o.price] = level
+}
+
+func mapRemove(priceMap types.PriceOrderBook, orderId string, price types.Amount) {
+	level, exists := priceMap[price]
+	if !exists {
+		return
+	}
+	delete(level.Orders, orderId)
+	var total types.Shares
+	for _, entry := range level.Orders {
+		total += entry.Quantity
+	}
+	level.Total = total
+	priceMap[price] = level
+	if len(level.Orders) == 0 {
+		delete(priceMap, price)
+	}
+}
+
+func mapCrossingPrices(priceMap types.PriceOrderBook, limit types.Amount) []types.Amount {
+	prices := make([]types.Amount, 0, len(priceMap))
+	for price, level := range priceMap {
+		if price <= limit && level.Total > 0 {
+			prices = append(prices, price)
+		}
+	}
+	sort.Slice(prices, func(i, j int) bool { return prices[i] < prices[j] })
+	return prices
+}
+
+func mapOrdersByTime(level types.PriceLevel) []string {
+	orderIds := make([]string, 0, len(level.Orders))
+	for orderId := range level.Orders {
+		orderIds = append(orderIds, orderId)
+	}
+	sort.Slice(orderIds, func(i, j int) bool {
+		return level.Orders[orderIds[i]].Sequence < level.Orders[orderIds[j]].Sequence
+	})
+	return orderIds
+}
+
+func mapMatch(priceMap types.PriceOrderBook, limit types.Amount, quantity types.Shares) types.Shares {
+	for _, price := range mapCrossingPrices(priceMap, limit) {
+		level := priceMap[price]
+		for _, orderId := range mapOrdersByTime(level) {
+			entry := level.Orders[orderId]
+			fill := min(entry.Quantity, quantity)
+			quantity -= fill
+			entry.Quantity -= fill
+			level.Total -= fill
+			if entry.Quantity == 0 {
+				delete(level.Orders, orderId)
+			} else {
+				level.Orders[orderId] = entry
+			}
+			if quantity == 0 {
+				break
+			}
+		}
+		if len(level.Orders) == 0 {
+			delete(priceMap, price)
+		} else {
+			priceMap[price] = level
+		}
+		if quantity == 0 {
+			break
+		}
+	}
+	return quantity
+}
+
+func bookMatch(book *Book, limit types.Amount, quantity types.Shares) types.Shares {
+	for level := range book.Crossing(limit) {
+		for order := level.Front(); order != nil && quantity > 0; {
+			next := order.Next()
+			fill := min(order.Quantity, quantity)
+			quantity -= fill
+			book.Fill(order.Id, fill)
+			order = next
+		}
+		if quantity == 0 {
+			break
+		}
+	}
+	return quantity
+}
+
+func BenchmarkInsert(b *testing.B) {
+	for _, n := range benchSizes {
+		orders := benchOrders(n)
+
+		b.Run(fmt.Sprintf("map/%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				priceMap := make(types.PriceOrderBook)
+				for _, o := range orders {
+					mapAdd(priceMap, o)
+				}
+			}
+			b.ReportMetric(float64(b.N*n)/b.Elapsed().Seconds(), "orders/s")
+		})
+
+		b.Run(fmt.Sprintf("book/%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				fillBook(orders)
+			}
+			b.ReportMetric(float64(b.N*n)/b.Elapsed().Seconds(), "orders/s")
+		})
+	}
+}
+
+// BenchmarkCancel cancels every order of a full book. The map book is given
+// the price of each order, as its cancel needed; Book only needs the id.
+func BenchmarkCancel(b *testing.B) {
+	for _, n := range benchSizes {
+		orders := benchOrders(n)
+
+		b.Run(fmt.Sprintf("map/%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				priceMap := make(types.PriceOrderBook)
+				for _, o := range orders {
+					mapAdd(priceMap, o)
+				}
+				b.StartTimer()
+				for _, o := range orders {
+					mapRemove(priceMap, o.id, o.price)
+				}
+				if len(priceMap) != 0 {
+					b.Fatalf("%d levels left after cancelling everything", len(priceMap))
+				}
+			}
+			b.ReportMetric(float64(b.N*n)/b.Elapsed().Seconds(), "orders/s")
+		})
+
+		b.Run(fmt.Sprintf("book/%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				book := fillBook(orders)
+				b.StartTimer()
+				for _, o := range orders {
+					book.Remove(o.id)
+				}
+				if book.Len() != 0 {
+					b.Fatalf("%d orders left after cancelling everything", book.Len())
+				}
+			}
+			b.ReportMetric(float64(b.N*n)/b.Elapsed().Seconds(), "orders/s")
+		})
+	}
+}
+
+// BenchmarkMatch sweeps a full book with incoming orders of 50 shares until
+// it is empty
+func BenchmarkMatch(b *testing.B) {
+	const sweep = 50
+
+	for _, n := range benchSizes {
+		orders := benchOrders(n)
+		var total types.Shares
+		for _, o := range orders {
+			total += o.quantity
+		}
+		sweeps := int((total + sweep - 1) / sweep)
+
+		b.Run(fmt.Sprintf("map/%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				priceMap := make(types.PriceOrderBook)
+				for _, o := range orders {
+					mapAdd(priceMap, o)
+				}
+				b.StartTimer()
+				for len(priceMap) > 0 {
+					mapMatch(priceMap, types.MaxPrice, sweep)
+				}
+			}
+			b.ReportMetric(float64(b.N*sweeps)/b.Elapsed().Seconds(), "matches/s")
+		})
+
+		b.Run(fmt.Sprintf("book/%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				book := fillBook(orders)
+				b.StartTimer()
+				for book.Len() > 0 {
+					bookMatch(book, types.MaxPrice, sweep)
+				}
+			}
+			b.ReportMetric(float64(b.N*sweeps)/b.Elapsed().Seconds(), "matches/s")
+		})
+	}
+}
+
+// correctness
+
+// ids returns the ids of orders, in order
+func ids(orders []*Order) []string {
+	orderIds := make([]string, len(orders))
+	for i, order := range orders {
+		orderIds[i] = order.Id
+	}
+	return orderIds
+}
+
+// levelIds returns the ids of a level's orders, front to back
+func levelIds(level *Level) []string {
+	var orderIds []string
+	for order := level.Front(); order != nil; order = order.Next() {
+		orderIds = append(orderIds, order.Id)
+	}
+	return orderIds
+}
+
+// checkBook checks every index of a book against the orders it should hold,
+// by price then arrival
+func checkBook(t *testing.T, book *Book, want ...string) {
+	t.Helper()
+	if got := ids(book.Orders()); !slices.Equal(got, want) {
+		t.Fatalf("book holds %v, want %v", got, want)
+	}
+	if book.Len() != len(want) {
+		t.Errorf("book has length %d, want %d", book.Len(), len(want))
+	}
+	var walked []string
+	for level := range book.Crossing(types.MaxPrice) {
+		var total, reverted types.Shares
+		for order := level.Front(); order != nil; order = order.Next() {
+			if order.level != level || order.Price != level.Price {
+				t.Errorf("order %s at %s is in the %s level", order.Id, order.Price, level.Price)
+			}
+			if order.next != nil && order.next.prev != order {
+				t.Errorf("order %s's next doesn't point back to it", order.Id)
+			}
+			total += order.Quantity
+			if order.Type == "reverted" {
+				reverted += order.Quantity
+			}
+			walked = append(walked, order.Id)
+		}
+		if level.head == nil || level.tail == nil || level.tail.next != nil || level.head.prev != nil {
+			t.Errorf("level %s has head %v and tail %v", level.Price, level.head, level.tail)
+		}
+		if level.Total != total || level.Reverted != reverted {
+			t.Errorf("level %s totals %d (%d reverted), its orders %d (%d)", level.Price, level.Total, level.Reverted, total, reverted)
+		}
+	}
+	if !slices.Equal(walked, want) {
+		t.Errorf("walking the levels finds %v, want %v", walked, want)
+	}
+	if len(book.levels) != len(book.prices) || !sort.SliceIsSorted(book.prices, func(i, j int) bool { return book.prices[i] < book.prices[j] }) {
+		t.Errorf("%d levels for prices %v", len(book.levels), book.prices)
+	}
+	for _, orderId := range want {
+		if order, exists := book.Get(orderId); !exists || order.Id != orderId {
+			t.Errorf("order %s isn't indexed", orderId)
+		}
+	}
+}
+
+func entry(price types.Amount, quantity types.Shares) types.OrderBookEntry {
+	return types.OrderBookEntry{Price: price, Quantity: quantity, Type: "regular"}
+}
+
+func TestPriceTimePriority(t *testing.T) {
+	book := NewBook()
+	book.Add("b1", entry(50*types.USD, 1))
+	book.Add("a1", entry(40*types.USD, 1))
+	book.Add("b2", entry(50*types.USD, 1))
+	book.Add("c1", entry(60*types.USD, 1))
+	book.Add("a2", entry(40*types.USD, 1))
+	checkBook(t, book, "a1", "a2", "b1", "b2", "c1")
+
+	if best, ok := book.Best(""); !ok || best != 40*types.USD {
+		t.Errorf("best price is %s %v, want 40.00", best, ok)
+	}
+
+	// Adding an order that's already resting sends it to the back of its new level
+	book.Add("a1", entry(50*types.USD, 1))
+	checkBook(t, book, "a2", "b1", "b2", "a1", "c1")
+}
+
+func TestRemove(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		remove []string
+		want   []string
+	}{
+		{"head", []string{"a"}, []string{"b", "c", "d"}},
+		{"middle", []string{"b"}, []string{"a", "c", "d"}},
+		{"tail", []string{"c"}, []string{"a", "b", "d"}},
+		{"the whole level", []string{"b", "a", "c"}, []string{"d"}},
+		{"the only order of a level", []string{"d"}, []string{"a", "b", "c"}},
+		{"everything", []string{"d", "c", "a", "b"}, nil},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			book := NewBook()
+			book.Add("a", entry(50*types.USD, 1))
+			book.Add("b", types.OrderBookEntry{Price: 50 * types.USD, Quantity: 2, Type: "reverted"})
+			book.Add("c", entry(50*types.USD, 3))
+			book.Add("d", entry(60*types.USD, 4))
+
+			for _, orderId := range test.remove {
+				if _, removed := book.Remove(orderId); !removed {
+					t.Fatalf("%s wasn't removed", orderId)
+				}
+			}
+			checkBook(t, book, test.want...)
+			for _, orderId := range test.remove {
+				if _, exists := book.Get(orderId); exists {
+					t.Errorf("removed order %s is still indexed", orderId)
+				}
+			}
+			if _, removed := book.Remove(test.remove[0]); removed {
+				t.Errorf("%s was removed twice", test.remove[0])
+			}
+
+			// An emptied level is gone, not left behind empty
+			if _, exists := book.levels[50*types.USD]; exists != slices.ContainsFunc(test.want, func(id string) bool { return id != "d" }) {
+				t.Errorf("the 50.00 level exists %v with %v left", exists, test.want)
+			}
+		})
+	}
+}
+
+func TestFill(t *testing.T) {
+	book := NewBook()
+	book.Add("a", entry(50*types.USD, 5))
+	book.Add("b", types.OrderBookEntry{Price: 50 * types.USD, Quantity: 3, Type: "reverted"})
+
+	if left := book.Fill("a", 2); left != 3 {
+		t.Errorf("a has %d left after 2 of 5, want 3", left)
+	}
+	if left := book.Fill("b", 1); left != 2 {
+		t.Errorf("b has %d left after 1 of 3, want 2", left)
+	}
+	checkBook(t, book, "a", "b")
+
+	// Filling more than is left fills what's left and removes the order
+	if left := book.Fill("a", 10); left != 0 {
+		t.Errorf("a has %d left after overfilling it, want 0", left)
+	}
+	checkBook(t, book, "b")
+	if left := book.Fill("a", 1); left != 0 {
+		t.Errorf("filling a removed order leaves %d, want 0", left)
+	}
+
+	book.Fill("b", 2)
+	checkBook(t, book)
+	if _, ok := book.Best(""); ok || len(book.levels) != 0 {
+		t.Errorf("filled book still has levels %v", book.prices)
+	}
+}
+
+func TestCrossing(t *testing.T) {
+	book := NewBook()
+	book.Add("a", entry(40*types.USD, 1))
+	book.Add("b", types.OrderBookEntry{Price: 50 * types.USD, Quantity: 2, Type: "reverted"})
+	book.Add("c", entry(50*types.USD, 3))
+	book.Add("d", entry(60*types.USD, 4))
+
+	for _, test := range []struct {
+		limit     types.Amount
+		levels    []types.Amount
+		available map[string]types.Shares
+	}{
+		{39 * types.USD, nil, map[string]types.Shares{"": 0, "reverted": 0, "regular": 0}},
+		{40 * types.USD, []types.Amount{40 * types.USD}, map[string]types.Shares{"": 1, "reverted": 0, "regular": 1}},
+		{55 * types.USD, []types.Amount{40 * types.USD, 50 * types.USD}, map[string]types.Shares{"": 6, "reverted": 2, "regular": 4}},
+		{types.MaxPrice, []types.Amount{40 * types.USD, 50 * types.USD, 60 * types.USD}, map[string]types.Shares{"": 10, "reverted": 2, "regular": 8}},
+	} {
+		var levels []types.Amount
+		for level := range book.Crossing(test.limit) {
+			levels = append(levels, level.Price)
+		}
+		if !slices.Equal(levels, test.levels) {
+			t.Errorf("crossing %s visits %v, want %v", test.limit, levels, test.levels)
+		}
+		for entryType, want := range test.available {
+			if got := book.AvailableQuantity(test.limit, entryType); got != want {
+				t.Errorf("%d %q available at %s, want %d", got, entryType, test.limit, want)
+			}
+		}
+	}
+
+	if best, ok := book.Best("reverted"); !ok || best != 50*types.USD {
+		t.Errorf("best reverted price is %s %v, want 50.00", best, ok)
+	}
+
+	// Emptying levels while crossing them moves on to the next price
+	var visited []types.Amount
+	for level := range book.Crossing(types.MaxPrice) {
+		visited = append(visited, level.Price)
+		for order := level.Front(); order != nil; {
+			next := order.Next()
+			book.Fill(order.Id, order.Quantity)
+			order = next
+		}
+	}
+	if want := []types.Amount{40 * types.USD, 50 * types.USD, 60 * types.USD}; !slices.Equal(visited, want) {
+		t.Errorf("crossing while emptying visits %v, want %v", visited, want)
+	}
+	checkBook(t, book)
+}
+
+func TestExpired(t *testing.T) {
+	book := NewBook()
+	gtd := func(price types.Amount, expiresAt int64) types.OrderBookEntry {
+		return types.OrderBookEntry{Price: price, Quantity: 1, Type: "regular", ExpiresAt: expiresAt}
+	}
+	book.Add("late", gtd(40*types.USD, 300))
+	book.Add("first", gtd(60*types.USD, 100))
+	book.Add("gtc", entry(50*types.USD, 1))
+	book.Add("second", gtd(50*types.USD, 200))
+	book.Add("third", gtd(40*types.USD, 200))
+	book.Add("never", gtd(40*types.USD, 1000))
+
+	for range 10 {
+		if got, want := ids(book.Expired(300)), []string{"first", "second", "third", "late"}; !slices.Equal(got, want) {
+			t.Fatalf("expired at 300: %v, want %v", got, want)
+		}
+	}
+	if got := book.Expired(99); len(got) != 0 {
+		t.Errorf("expired at 99: %v, want none", ids(got))
+	}
+
+	book.Remove("first")
+	book.Fill("second", 1)
+	if got, want := ids(book.Expired(300)), []string{"third", "late"}; !slices.Equal(got, want) {
+		t.Errorf("expired at 300 after removing two: %v, want %v", got, want)
+	}
+	if got := levelIds(book.levels[40*types.USD]); !slices.Equal(got, []string{"late", "third", "never"}) {
+		t.Errorf("the 40.00 level is %v", got)
+	}
+}
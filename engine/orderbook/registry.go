@@ -0,0 +1,40 @@
+package orderbook
+
+import (
+	types "github.com/adityadeshlahre/probo-v1/shared/types"
+)
+
+// OrderRecord is what the engine knows about an order by its id alone: who
+// owns it, which book it rests in and how far it got
+type OrderRecord struct {
+	Id        string
+	UserId    string
+	Symbol    string
+	StockType string // side of the book the order rests on, "yes" | "no"
+	Quantity  types.Shares
+	FilledQty types.Shares
+	Status    types.OrderStatus
+}
+
+// Open reports whether the order may still be resting in the book
+func (r *OrderRecord) Open() bool {
+	return r.Status == types.PENDING || r.Status == types.PARTIALLY_FILLED
+}
+
+// Registry indexes every order the engine has taken by id
+type Registry map[string]*OrderRecord
+
+// Register records a new order
+func (r Registry) Register(record OrderRecord) {
+	r[record.Id] = &record
+}
+
+// Update adds a fill to an order and sets its status. Unknown ids are ignored.
+func (r Registry) Update(orderId string, filledQty types.Shares, status types.OrderStatus) {
+	record, exists := r[orderId]
+	if !exists {
+		return
+	}
+	record.FilledQty += filledQty
+	record.Status = status
+}
@@ -11,6 +11,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/adityadeshlahre/probo-v1/engine/core"
 	types "github.com/adityadeshlahre/probo-v1/shared/types"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -160,16 +161,19 @@ func uploadFileToS3(localPath, s3Key string) error {
 	return err
 }
 
-// StartPeriodicUpload starts a goroutine that uploads logs to S3 every 10 seconds
-func StartPeriodicUpload(orderBook types.YesNoOrderBook, usdBalances types.USDBalances, stockBalances types.StockBalances) {
+// StartPeriodicUpload starts a goroutine that uploads logs to S3 every 10 seconds.
+// takeSnapshot must return a copy of the engine state that is safe to read from
+// another goroutine.
+func StartPeriodicUpload(takeSnapshot func() core.Snapshot) {
 	go func() {
 		ticker := time.NewTicker(10 * time.Second)
 		defer ticker.Stop()
 
 		for range ticker.C {
 			// Append current snapshot to local files for all markets
-			for marketName := range orderBook {
-				if err := AppendOrderBookSnapshot(marketName, orderBook, usdBalances, stockBalances); err != nil {
+			snapshot := takeSnapshot()
+			for marketName := range snapshot.OrderBook {
+				if err := AppendOrderBookSnapshot(marketName, snapshot.OrderBook, snapshot.USDBalances, snapshot.StockBalances); err != nil {
 					log.Printf("Failed to append order book snapshot for %s: %v", marketName, err)
 				}
 			}
@@ -0,0 +1,222 @@
+package trading
+
+import (
+	"fmt"
+
+	"github.com/adityadeshlahre/probo-v1/engine/amm"
+	types "github.com/adityadeshlahre/probo-v1/shared/types"
+)
+
+// A market with an LMSR market maker trades against it as well as against the
+// book. Orders go to whichever is cheaper, share by share: before each price
+// level an order takes what the market maker sells (or buys) at a better
+// price than the level, and once the crossing levels are used up it takes
+// what the market maker offers up to the order's limit. The market maker
+// trades from its own account, minting YES/NO pairs when it sells shares it
+// doesn't hold and burning pairs when it buys back the other side's shares.
+
+// ammBuyable returns how many of up to quantity stockType shares of a symbol
+// its market maker sells for at most maxPrice each, 0 if it has none
+func ammBuyable(stockSymbol, stockType string, maxPrice types.Amount, quantity types.Shares) types.Shares {
+	market := MarketsMap[stockSymbol]
+	if market.AMM == nil {
+		return 0
+	}
+	return amm.Buyable(*market.AMM, stockType, maxPrice, quantity, MarketsMap.Contract(stockSymbol).Payout)
+}
+
+// ammSellable returns how many of up to quantity stockType shares of a symbol
+// its market maker buys for at least minPrice each, 0 if it has none
+func ammSellable(stockSymbol, stockType string, minPrice types.Amount, quantity types.Shares) types.Shares {
+	market := MarketsMap[stockSymbol]
+	if market.AMM == nil {
+		return 0
+	}
+	return amm.Sellable(*market.AMM, stockType, minPrice, quantity, MarketsMap.Contract(stockSymbol).Payout)
+}
+
+// ammBestPrice returns the price of the next stockType share the market maker
+// of a symbol sells (buy) or buys (sell), false if it has none
+func ammBestPrice(stockSymbol, stockType string, isBuy bool) (types.Amount, bool) {
+	market := MarketsMap[stockSymbol]
+	if market.AMM == nil {
+		return 0, false
+	}
+	payout := MarketsMap.Contract(stockSymbol).Payout
+	if isBuy {
+		return amm.BuyCost(*market.AMM, stockType, 1, payout), true
+	}
+	return amm.SellProceeds(*market.AMM, stockType, 1, payout), true
+}
+
+// buyFromAMM buys up to quantity stockType shares from the market maker of a
+// symbol for at most maxPrice each. It returns the quantity left to buy and
+// fills with the market maker's fill added.
+func buyFromAMM(userId, stockSymbol, stockType string, maxPrice types.Amount, quantity types.Shares, fills []types.Fill) (types.Shares, []types.Fill) {
+	bought := ammBuyable(stockSymbol, stockType, maxPrice, quantity)
+	if bought == 0 {
+		return quantity, fills
+	}
+	market := MarketsMap[stockSymbol]
+	maker := market.AMM
+	payout := MarketsMap.Contract(stockSymbol).Payout
+
+	// Every share goes at the average price, rounded up to the cent
+	total := amm.BuyCost(*maker, stockType, bought, payout)
+	price := (total + types.Amount(bought) - 1) / types.Amount(bought)
+	paid := price.Times(bought)
+
+	// Shares the market maker doesn't hold are minted, with the other side kept
+	makerStocks := StockBalances[maker.Account][stockSymbol]
+	minted := max(bought-makerStocks.Side(stockType).Quantity, 0)
+	makerBalance := USDBalances[maker.Account]
+	if makerBalance.Balance+paid < payout.Times(minted) {
+		fmt.Printf("buyFromAMM: market maker of %s can't fund %d pairs\n", stockSymbol, minted)
+		return quantity, fills
+	}
+	makerBalance.Balance += paid - payout.Times(minted)
+	USDBalances[maker.Account] = makerBalance
+	makerStocks.Side(stockType).Quantity -= bought - minted
+	makerStocks.Side(oppositeOf(stockType)).Quantity += minted
+	StockBalances[maker.Account][stockSymbol] = makerStocks
+	StockBalances.AddCost(maker.Account, stockSymbol, oppositeOf(stockType), payout.Times(minted))
+	StockBalances.AddCost(maker.Account, stockSymbol, stockType, -paid)
+
+	buyerBalance := USDBalances[userId]
+	buyerBalance.Balance -= paid
+	USDBalances[userId] = buyerBalance
+	buyerStocks := StockBalances[userId][stockSymbol]
+	buyerStocks.Side(stockType).Quantity += bought
+	StockBalances[userId][stockSymbol] = buyerStocks
+	StockBalances.AddCost(userId, stockSymbol, stockType, paid)
+
+	if stockType == "yes" {
+		maker.Yes += bought
+	} else {
+		maker.No += bought
+	}
+	fmt.Printf("buyFromAMM: %s bought %d %s of %s from the market maker at %s\n", userId, bought, stockType, stockSymbol, price)
+
+	return quantity - bought, append(fills, types.Fill{
+		OrderId:  "amm",
+		UserId:   maker.Account,
+		Price:    price,
+		Quantity: bought,
+		Type:     "amm",
+	})
+}
+
+// sellToAMM sells up to quantity of a seller's locked stockType shares to the
+// market maker of a symbol for at least minPrice each. It returns the quantity
+// left to sell and fills with the market maker's fill added.
+func sellToAMM(sellerId, stockSymbol, stockType string, minPrice types.Amount, quantity types.Shares, fills []types.Fill) (types.Shares, []types.Fill) {
+	sold := ammSellable(stockSymbol, stockType, minPrice, quantity)
+	if sold == 0 {
+		return quantity, fills
+	}
+	market := MarketsMap[stockSymbol]
+	maker := market.AMM
+	payout := MarketsMap.Contract(stockSymbol).Payout
+
+	// Every share goes at the average price, rounded down to the cent
+	price := amm.SellProceeds(*maker, stockType, sold, payout) / types.Amount(sold)
+	paid := price.Times(sold)
+
+	// Shares bought back are burned with the other side's shares the market
+	// maker holds, and kept once it holds none
+	makerStocks := StockBalances[maker.Account][stockSymbol]
+	burned := min(sold, makerStocks.Side(oppositeOf(stockType)).Quantity)
+	makerBalance := USDBalances[maker.Account]
+	if makerBalance.Balance+payout.Times(burned) < paid {
+		fmt.Printf("sellToAMM: market maker of %s can't pay %s\n", stockSymbol, paid)
+		return quantity, fills
+	}
+	makerBalance.Balance += payout.Times(burned) - paid
+	USDBalances[maker.Account] = makerBalance
+	makerStocks.Side(oppositeOf(stockType)).Quantity -= burned
+	makerStocks.Side(stockType).Quantity += sold - burned
+	StockBalances[maker.Account][stockSymbol] = makerStocks
+	StockBalances.AddCost(maker.Account, stockSymbol, stockType, paid)
+	StockBalances.AddCost(maker.Account, stockSymbol, oppositeOf(stockType), -payout.Times(burned))
+
+	sellerStocks := StockBalances[sellerId][stockSymbol]
+	sellerStocks.Side(stockType).Locked -= sold
+	StockBalances[sellerId][stockSymbol] = sellerStocks
+	sellerBalance := USDBalances[sellerId]
+	sellerBalance.Balance += paid
+	USDBalances[sellerId] = sellerBalance
+	StockBalances.AddCost(sellerId, stockSymbol, stockType, -paid)
+
+	if stockType == "yes" {
+		maker.Yes -= sold
+	} else {
+		maker.No -= sold
+	}
+	fmt.Printf("sellToAMM: %s sold %d %s of %s to the market maker at %s\n", sellerId, sold, stockType, stockSymbol, price)
+
+	return quantity - sold, append(fills, types.Fill{
+		OrderId:  "amm",
+		UserId:   maker.Account,
+		Price:    price,
+		Quantity: sold,
+		Type:     "amm",
+	})
+}
+
+// QuoteAMM returns a market maker's prices, what trading with it would cost and
+// what it can lose by settlement
+func QuoteAMM(req types.AMMQuoteProps) (map[string]interface{}, error) {
+	market, exists := MarketsMap[req.StockSymbol]
+	if !exists {
+		return nil, fmt.Errorf("market %s doesn't exist", req.StockSymbol)
+	}
+	if market.AMM == nil {
+		return nil, fmt.Errorf("market %s has no market maker", req.StockSymbol)
+	}
+	maker := *market.AMM
+	payout := MarketsMap.Contract(req.StockSymbol).Payout
+
+	result := map[string]interface{}{
+		"stockSymbol": req.StockSymbol,
+		"account":     maker.Account,
+		"b":           maker.B,
+		"subsidy":     maker.Subsidy,
+		"sold":        map[string]types.Shares{"yes": maker.Yes, "no": maker.No},
+		"prices": map[string]float64{
+			"yes": amm.Price(maker, "yes", payout) / float64(types.USD),
+			"no":  amm.Price(maker, "no", payout) / float64(types.USD),
+		},
+		"balance":       USDBalances[maker.Account].Balance,
+		"maxLoss":       amm.MaxLoss(maker.B, payout),
+		"worstCaseLoss": amm.WorstCaseLoss(maker, payout),
+	}
+	if req.Quantity == 0 {
+		return result, nil
+	}
+
+	if req.Quantity < 0 {
+		return nil, fmt.Errorf("quantity should be greater than 0")
+	}
+	stockType, err := MarketsMap.StockType(req.StockSymbol, req.StockType)
+	if err != nil {
+		return nil, err
+	}
+	var total types.Amount
+	switch req.Side {
+	case "buy":
+		total = amm.BuyCost(maker, stockType, req.Quantity, payout)
+		total = (total + types.Amount(req.Quantity) - 1) / types.Amount(req.Quantity) * types.Amount(req.Quantity)
+	case "sell":
+		total = amm.SellProceeds(maker, stockType, req.Quantity, payout) / types.Amount(req.Quantity) * types.Amount(req.Quantity)
+	default:
+		return nil, fmt.Errorf("invalid side %q, expected buy or sell", req.Side)
+	}
+	result["quote"] = map[string]interface{}{
+		"stockType":    stockType,
+		"side":         req.Side,
+		"quantity":     req.Quantity,
+		"total":        total,
+		"averagePrice": total / types.Amount(req.Quantity),
+	}
+	return result, nil
+}
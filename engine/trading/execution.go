@@ -0,0 +1,160 @@
+package trading
+
+import (
+	"fmt"
+	"time"
+
+	types "github.com/adityadeshlahre/probo-v1/shared/types"
+)
+
+// orderExecution is the resolved kind, time in force and limit price of an order
+type orderExecution struct {
+	kind        types.OrderKind
+	timeInForce types.TimeInForce
+	limitPrice  types.Amount
+	expiresAt   int64
+	crossable   bool // false when a market order finds nothing to cross
+}
+
+// rests reports whether the unfilled part of the order stays in the book
+func (e orderExecution) rests() bool {
+	return e.timeInForce == types.GoodTillCancelled || e.timeInForce == types.GoodTillDate
+}
+
+// resolveExecution validates the kind and time in force of an order and works
+// out the price it may trade at. bestPrice is the best price on the other side
+// of the book, expressed in the order's own stock type; hasBest is false when
+// that side is empty. Limit prices have to fit the market's contract; market
+// orders are capped at bestPrice plus (buy) or minus (sell) the requested
+// slippage.
+func resolveExecution(orderData types.OrderProps, isBuy bool, bestPrice types.Amount, hasBest bool, contract types.ContractSpec) (orderExecution, error) {
+	execution := orderExecution{
+		kind:        orderData.Kind,
+		timeInForce: orderData.TimeInForce,
+		crossable:   true,
+	}
+	if execution.kind == "" {
+		execution.kind = types.LimitOrder
+	}
+
+	switch execution.kind {
+	case types.LimitOrder:
+		if execution.timeInForce == "" {
+			execution.timeInForce = types.GoodTillCancelled
+		}
+		if err := contract.CheckPrice(orderData.Price); err != nil {
+			return execution, err
+		}
+		execution.limitPrice = orderData.Price
+	case types.MarketOrder:
+		if execution.timeInForce == "" {
+			execution.timeInForce = types.ImmediateOrCancel
+		}
+		if execution.timeInForce != types.ImmediateOrCancel && execution.timeInForce != types.FillOrKill {
+			return execution, fmt.Errorf("market orders can only be IOC or FOK")
+		}
+		if orderData.Slippage < 0 {
+			return execution, fmt.Errorf("slippage can't be negative")
+		}
+		execution.crossable = hasBest
+		if isBuy {
+			execution.limitPrice = min(bestPrice+orderData.Slippage, contract.Payout)
+		} else {
+			execution.limitPrice = max(bestPrice-orderData.Slippage, 0)
+		}
+	default:
+		return execution, fmt.Errorf("invalid order kind %q, expected limit or market", execution.kind)
+	}
+
+	switch execution.timeInForce {
+	case types.GoodTillCancelled, types.ImmediateOrCancel, types.FillOrKill:
+	case types.GoodTillDate:
+		if orderData.ExpiresAt <= time.Now().UnixMilli() {
+			return execution, fmt.Errorf("GTD orders need an expiresAt in the future")
+		}
+		execution.expiresAt = orderData.ExpiresAt
+	default:
+		return execution, fmt.Errorf("invalid time in force %q, expected GTC, IOC, FOK or GTD", execution.timeInForce)
+	}
+
+	return execution, nil
+}
+
+// finalOrderStatus returns the status of an incoming order once matching is
+// done. Orders that can't rest are cancelled for whatever didn't fill.
+func finalOrderStatus(execution orderExecution, filledQty, quantity types.Shares) types.OrderStatus {
+	if filledQty >= quantity {
+		return types.COMPLETED
+	}
+	if !execution.rests() {
+		return types.CANCELLED
+	}
+	return incomingOrderStatus(filledQty, quantity)
+}
+
+// ExpireOrders removes every GTD order whose expiry has passed and releases
+// what it had locked
+func ExpireOrders(now time.Time) {
+	for stockSymbol := range OrderBook {
+		expireSymbolOrders(stockSymbol, now)
+	}
+}
+
+// expireSymbolOrders removes the expired GTD orders of one symbol
+func expireSymbolOrders(stockSymbol string, now time.Time) {
+	symbolBook, exists := OrderBook[stockSymbol]
+	if !exists {
+		return
+	}
+	nowMillis := now.UnixMilli()
+	expired := false
+
+	for _, stockType := range []string{"yes", "no"} {
+		book := symbolBook.Side(stockType)
+		for _, order := range book.Expired(nowMillis) {
+			fmt.Printf("expireOrders: order %s of %s expired\n", order.Id, order.UserId)
+			releaseOrder(order.OrderBookEntry, stockSymbol, stockType)
+			book.Remove(order.Id)
+			publishOrderUpdate(order.Id, 0, types.EXPIRED)
+			expired = true
+		}
+	}
+
+	if expired {
+		sendUSDBalancesToDB()
+		publishOrderBook(stockSymbol)
+	}
+}
+
+// releaseOrder gives back what a resting order had locked: USD for reverted
+// (buy) orders, locked at the buyer's own price, and stocks for regular (sell)
+// orders, and the maker fee it reserved either way
+func releaseOrder(order types.OrderBookEntry, stockSymbol string, stockType string) {
+	refund := order.Fee
+	if order.Type == "reverted" {
+		refund += (MarketsMap.Contract(stockSymbol).Payout - order.Price).Times(order.Quantity)
+	}
+	if refund != 0 {
+		userBalance := USDBalances[order.UserId]
+		userBalance.Locked -= refund
+		userBalance.Balance += refund
+		USDBalances[order.UserId] = userBalance
+	}
+	if order.Type == "reverted" {
+		return
+	}
+
+	if userStocks, exists := StockBalances[order.UserId]; exists {
+		if symbolStocks, exists := userStocks[stockSymbol]; exists {
+			if stockType == "yes" {
+				symbolStocks.Yes.Locked -= order.Quantity
+				symbolStocks.Yes.Quantity += order.Quantity
+			} else {
+				symbolStocks.No.Locked -= order.Quantity
+				symbolStocks.No.Quantity += order.Quantity
+			}
+			userStocks[stockSymbol] = symbolStocks
+			StockBalances[order.UserId] = userStocks
+		}
+	}
+}
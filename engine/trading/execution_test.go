@@ -0,0 +1,175 @@
+package trading
+
+import (
+	"testing"
+	"time"
+
+	types "github.com/adityadeshlahre/probo-v1/shared/types"
+)
+
+func withTIF(order types.OrderProps, timeInForce types.TimeInForce) types.OrderProps {
+	order.TimeInForce = timeInForce
+	return order
+}
+
+// addAMM gives the test market an LMSR market maker with liquidity b and
+// enough USD to mint whatever it sells
+func addAMM(b float64) {
+	account := types.AMMAccount(testSymbol)
+	market := MarketsMap[testSymbol]
+	market.AMM = &types.AMM{Account: account, B: b}
+	MarketsMap[testSymbol] = market
+	fund(account, 10000*types.USD, 0, 0)
+}
+
+func TestIOCReleasesRemainder(t *testing.T) {
+	t.Run("buy", func(t *testing.T) {
+		setupEngine(t)
+		fund("seller", 0, 2, 0)
+		fund("buyer", 1000*types.USD, 0, 0)
+		sell(t, limit("seller", "yes", 40*types.USD, 2))
+
+		result := buy(t, withTIF(limit("buyer", "yes", 50*types.USD, 5), types.ImmediateOrCancel))
+		if result["orderStatus"] != types.CANCELLED || result["filledQty"] != types.Shares(2) {
+			t.Errorf("order is %v with %v filled, want CANCELLED with 2", result["orderStatus"], result["filledQty"])
+		}
+		if got := USDBalances["buyer"]; got != (types.USDBalance{Balance: 920 * types.USD}) {
+			t.Errorf("buyer has %+v, want 80.00 spent and nothing locked", got)
+		}
+		if got := OrderBook.Symbol(testSymbol).No.Len(); got != 0 {
+			t.Errorf("%d orders rest for the remainder, want none", got)
+		}
+	})
+
+	t.Run("sell", func(t *testing.T) {
+		setupEngine(t)
+		fund("seller", 0, 5, 0)
+
+		result := sell(t, withTIF(limit("seller", "yes", 60*types.USD, 5), types.ImmediateOrCancel))
+		if result["orderStatus"] != types.CANCELLED {
+			t.Errorf("order is %v, want CANCELLED", result["orderStatus"])
+		}
+		if got := StockBalances["seller"][testSymbol].Yes; got != (types.StockPosition{Quantity: 5}) {
+			t.Errorf("seller has %+v yes, want all 5 back and nothing locked", got)
+		}
+		if got := OrderBook.Symbol(testSymbol).Yes.Len(); got != 0 {
+			t.Errorf("%d orders rest for the remainder, want none", got)
+		}
+	})
+}
+
+func TestFOK(t *testing.T) {
+	setupEngine(t)
+	fund("seller", 0, 3, 0)
+	fund("buyer", 1000*types.USD, 0, 0)
+	sell(t, limit("seller", "yes", 40*types.USD, 3))
+
+	result := buy(t, withTIF(limit("buyer", "yes", 50*types.USD, 5), types.FillOrKill))
+	if result["orderStatus"] != types.CANCELLED || result["filledQty"] != types.Shares(0) {
+		t.Errorf("order is %v with %v filled, want CANCELLED with nothing", result["orderStatus"], result["filledQty"])
+	}
+	if got := USDBalances["buyer"]; got != (types.USDBalance{Balance: 1000 * types.USD}) {
+		t.Errorf("buyer has %+v, want it untouched", got)
+	}
+	if got := OrderBook.Symbol(testSymbol).Yes.AvailableQuantity(types.MaxPrice, ""); got != 3 {
+		t.Errorf("%d yes left in the book, want all 3", got)
+	}
+
+	result = buy(t, withTIF(limit("buyer", "yes", 50*types.USD, 3), types.FillOrKill))
+	if result["orderStatus"] != types.COMPLETED {
+		t.Errorf("order is %v, want COMPLETED", result["orderStatus"])
+	}
+}
+
+func TestFOKCountsAMM(t *testing.T) {
+	t.Run("buy", func(t *testing.T) {
+		setupEngine(t)
+		fund("seller", 0, 2, 0)
+		fund("buyer", 1000*types.USD, 0, 0)
+		sell(t, limit("seller", "yes", 45*types.USD, 2))
+		addAMM(10)
+
+		// Too much for the book and the market maker together
+		result := buy(t, withTIF(limit("buyer", "yes", 60*types.USD, 15), types.FillOrKill))
+		if result["orderStatus"] != types.CANCELLED || MarketsMap[testSymbol].AMM.Yes != 0 {
+			t.Errorf("order is %v with the market maker %d yes down, want CANCELLED and untouched", result["orderStatus"], MarketsMap[testSymbol].AMM.Yes)
+		}
+
+		// 2 from the book, the rest from the market maker
+		result = buy(t, withTIF(limit("buyer", "yes", 60*types.USD, 5), types.FillOrKill))
+		if result["orderStatus"] != types.COMPLETED {
+			t.Fatalf("order is %v, want COMPLETED", result["orderStatus"])
+		}
+		fills := result["fills"].([]types.Fill)
+		if len(fills) != 2 || fills[0].Type != "swap" || fills[1].Type != "amm" || fills[1].Quantity != 3 {
+			t.Errorf("fills are %+v, want 2 from the book then 3 from the market maker", fills)
+		}
+	})
+
+	t.Run("sell", func(t *testing.T) {
+		setupEngine(t)
+		addAMM(10)
+		fund("seller", 0, 3, 0)
+
+		result := sell(t, withTIF(limit("seller", "yes", 40*types.USD, 3), types.FillOrKill))
+		if result["orderStatus"] != types.COMPLETED {
+			t.Fatalf("order is %v, want COMPLETED against the market maker", result["orderStatus"])
+		}
+		if got := MarketsMap[testSymbol].AMM.Yes; got != -3 {
+			t.Errorf("market maker's yes sold is %d, want -3", got)
+		}
+
+		// The market maker's bid falls below 40 long before 200 shares
+		fund("seller", 0, 200, 0)
+		result = sell(t, withTIF(limit("seller", "yes", 40*types.USD, 200), types.FillOrKill))
+		if result["orderStatus"] != types.CANCELLED {
+			t.Errorf("order is %v, want CANCELLED", result["orderStatus"])
+		}
+		if got := StockBalances["seller"][testSymbol].Yes; got != (types.StockPosition{Quantity: 200}) {
+			t.Errorf("seller has %+v yes, want all 200 back", got)
+		}
+	})
+}
+
+func TestGTDExpiry(t *testing.T) {
+	setupEngine(t)
+	fund("buyer", 1000*types.USD, 0, 0)
+	fund("seller", 0, 4, 0)
+	expiresAt := time.Now().Add(time.Second).UnixMilli()
+
+	gtd := withTIF(limit("buyer", "yes", 40*types.USD, 3), types.GoodTillDate)
+	gtd.ExpiresAt = expiresAt
+	bid := buy(t, gtd)
+	gtd = withTIF(limit("seller", "yes", 90*types.USD, 4), types.GoodTillDate)
+	gtd.ExpiresAt = expiresAt
+	ask := sell(t, gtd)
+	if got := USDBalances["buyer"].Locked; got != 120*types.USD {
+		t.Fatalf("buyer has %s locked, want 120.00", got)
+	}
+
+	ExpireOrders(time.UnixMilli(expiresAt - 1))
+	if got := OrderRegistry[bid["orderId"].(string)].Status; got != types.PENDING {
+		t.Errorf("bid is %s before it expires, want PENDING", got)
+	}
+
+	ExpireOrders(time.UnixMilli(expiresAt))
+	for _, orderId := range []string{bid["orderId"].(string), ask["orderId"].(string)} {
+		if got := OrderRegistry[orderId].Status; got != types.EXPIRED {
+			t.Errorf("order %s is %s, want EXPIRED", orderId, got)
+		}
+	}
+	if got := USDBalances["buyer"]; got != (types.USDBalance{Balance: 1000 * types.USD}) {
+		t.Errorf("buyer has %+v, want everything unlocked", got)
+	}
+	if got := StockBalances["seller"][testSymbol].Yes; got != (types.StockPosition{Quantity: 4}) {
+		t.Errorf("seller has %+v yes, want all 4 unlocked", got)
+	}
+	if got := OrderBook.Symbol(testSymbol).Yes.Len() + OrderBook.Symbol(testSymbol).No.Len(); got != 0 {
+		t.Errorf("%d orders left in the book, want none", got)
+	}
+
+	gtd.ExpiresAt = time.Now().Add(-time.Second).UnixMilli()
+	if _, err := PlaceSellOrder(gtd); err == nil {
+		t.Error("GTD order expiring in the past was taken")
+	}
+}
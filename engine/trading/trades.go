@@ -0,0 +1,53 @@
+package trading
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/adityadeshlahre/probo-v1/engine/fees"
+	types "github.com/adityadeshlahre/probo-v1/shared/types"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+// recordTrades gives every fill of an incoming order a trade id, charges its
+// fees and records it as a TRADE transection. The maker is the owner of the
+// resting order.
+func recordTrades(orderId, userId, stockSymbol, stockType string, fills []types.Fill) {
+	now := time.Now().Format(time.RFC3339)
+	for i := range fills {
+		tradeId, _ := gonanoid.New()
+		fills[i].TradeId = tradeId
+		fees.ChargeTrade(userId, stockSymbol, &fills[i])
+
+		publishTrade(types.Transection{
+			Id:              tradeId,
+			MakerId:         fills[i].UserId,
+			TakerId:         userId,
+			MakerOrderId:    fills[i].OrderId,
+			TakerOrderId:    orderId,
+			TransectionType: types.TRADE,
+			FillType:        fills[i].Type,
+			Quantity:        fills[i].Quantity,
+			Price:           fills[i].Price,
+			MakerFee:        fills[i].MakerFee,
+			TakerFee:        fills[i].TakerFee,
+			Symbol:          stockSymbol,
+			SymbolStockType: stockType,
+			CreatedAt:       now,
+			UpdatedAt:       now,
+		})
+	}
+}
+
+// publishTrade sends a trade to the database and to the symbol's trades channel
+func publishTrade(trade types.Transection) {
+	tradeBytes, _ := json.Marshal(trade)
+	tradeMsg := types.IncomingMessage{
+		Type: types.TRANSECTION,
+		Data: tradeBytes,
+	}
+	tradeMsgBytes, _ := json.Marshal(tradeMsg)
+	engineToDatabaseQueueClient.LPush(context.Background(), types.DB_ACTIONS, tradeMsgBytes)
+	engineToServerPubSubClient.Publish(context.Background(), types.TradesChannel(trade.Symbol), tradeMsgBytes)
+}
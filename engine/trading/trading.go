@@ -4,9 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"math"
 	"time"
 
+	"github.com/adityadeshlahre/probo-v1/engine/fees"
 	"github.com/adityadeshlahre/probo-v1/engine/orderbook"
 	types "github.com/adityadeshlahre/probo-v1/shared/types"
 	gonanoid "github.com/matoous/go-nanoid/v2"
@@ -25,13 +25,17 @@ func SetClients(dbClient, pubSubClient *redis.Client) {
 // Import these from main (will need to be passed or made accessible)
 var USDBalances types.USDBalances
 var StockBalances types.StockBalances
-var OrderBook types.YesNoOrderBook
+var OrderBook orderbook.Books
+var OrderRegistry orderbook.Registry
+var MarketsMap types.Markets
 
 // SetDataStructures sets references to shared data structures
-func SetDataStructures(usdBalances types.USDBalances, stockBalances types.StockBalances, orderBook types.YesNoOrderBook) {
+func SetDataStructures(usdBalances types.USDBalances, stockBalances types.StockBalances, orderBook orderbook.Books, orderRegistry orderbook.Registry, marketsMap types.Markets) {
 	USDBalances = usdBalances
 	StockBalances = stockBalances
 	OrderBook = orderBook
+	OrderRegistry = orderRegistry
+	MarketsMap = marketsMap
 }
 
 // sendUSDBalancesToDB sends USD and stock balance updates to database
@@ -47,7 +51,7 @@ func sendUSDBalancesToDB() {
 		Data: json.RawMessage(fmt.Sprintf(`{"data":%s}`, mustMarshal(usdData))),
 	}
 	usdBytes, _ := json.Marshal(usdMsg)
-	engineToDatabaseQueueClient.Publish(context.Background(), "DB_ACTIONS", usdBytes)
+	engineToDatabaseQueueClient.LPush(context.Background(), types.DB_ACTIONS, usdBytes)
 
 	// Send stock balances update
 	stockData := make(map[string]interface{})
@@ -62,7 +66,7 @@ func sendUSDBalancesToDB() {
 		Data: json.RawMessage(fmt.Sprintf(`{"data":%s}`, mustMarshal(stockData))),
 	}
 	stockBytes, _ := json.Marshal(stockMsg)
-	engineToDatabaseQueueClient.Publish(context.Background(), "DB_ACTIONS", stockBytes)
+	engineToDatabaseQueueClient.LPush(context.Background(), types.DB_ACTIONS, stockBytes)
 }
 
 func mustMarshal(v interface{}) string {
@@ -70,15 +74,13 @@ func mustMarshal(v interface{}) string {
 	return string(data)
 }
 
-// mintStocks creates new stocks when no sellers exist
-func mintStocks(userId, stockSymbol, sellerId string, price float64, stockType string, availableQuantity float64) error {
-	oppositeStockType := "no"
-	if stockType == "yes" {
-		oppositeStockType = "no"
-	} else {
-		oppositeStockType = "yes"
-	}
-	correspondingPrice := 100.0 - price
+// mintStocks creates a new YES/NO pair when a buyer crosses a reverted order.
+// The buyer pays price per share; the reverted order's owner already moved
+// payout - price per share into Locked when the order rested, so that amount
+// is consumed from Locked.
+func mintStocks(userId, stockSymbol, sellerId string, price types.Amount, stockType string, availableQuantity types.Shares) error {
+	oppositeStockType := oppositeOf(stockType)
+	correspondingPrice := MarketsMap.Contract(stockSymbol).Payout - price
 
 	// Initialize stock balances if they don't exist
 	if _, exists := StockBalances[sellerId]; !exists {
@@ -101,20 +103,16 @@ func mintStocks(userId, stockSymbol, sellerId string, price float64, stockType s
 	}
 
 	// Update USD balances
-	// For buyer: unlock if locked, and subtract price from balance
+	// For buyer: pay the level price out of the free balance
 	if buyerBalance, exists := USDBalances[userId]; exists {
-		if buyerBalance.Locked >= availableQuantity*correspondingPrice {
-			buyerBalance.Locked -= availableQuantity * correspondingPrice
-		}
-		buyerBalance.Balance -= availableQuantity * price
+		buyerBalance.Balance -= price.Times(availableQuantity)
 		USDBalances[userId] = buyerBalance
 	}
 
-	// For seller: unlock locked funds and add corresponding price to balance
+	// For seller: the reverted order's funds were locked when it rested, spend them now
 	if sellerBalance, exists := USDBalances[sellerId]; exists {
-		fmt.Printf("mintStocks: seller %s locked %.2f -> %.2f, balance %.2f -> %.2f (unlock %.2f, add %.2f)\n", sellerId, sellerBalance.Locked, sellerBalance.Locked-availableQuantity*correspondingPrice, sellerBalance.Balance, sellerBalance.Balance+availableQuantity*correspondingPrice, availableQuantity*correspondingPrice, availableQuantity*correspondingPrice)
-		sellerBalance.Locked -= availableQuantity * correspondingPrice
-		sellerBalance.Balance += availableQuantity * correspondingPrice
+		fmt.Printf("mintStocks: seller %s locked %s -> %s (spend %s)\n", sellerId, sellerBalance.Locked, sellerBalance.Locked-correspondingPrice.Times(availableQuantity), correspondingPrice.Times(availableQuantity))
+		sellerBalance.Locked -= correspondingPrice.Times(availableQuantity)
 		USDBalances[sellerId] = sellerBalance
 	}
 
@@ -139,16 +137,16 @@ func mintStocks(userId, stockSymbol, sellerId string, price float64, stockType s
 		StockBalances[userId][stockSymbol] = buyerStock
 	}
 
-	// Send database updates
-	sendUSDBalancesToDB()
+	// Each side paid its own price for the stocks it got
+	StockBalances.AddCost(userId, stockSymbol, stockType, price.Times(availableQuantity))
+	StockBalances.AddCost(sellerId, stockSymbol, oppositeStockType, correspondingPrice.Times(availableQuantity))
 
 	return nil
 }
 
-// swapStocks transfers existing stocks between users
-func swapStocks(userId, stockSymbol, sellerId string, price float64, stockType string, availableQuantity float64) error {
-	correspondingPrice := 100.0 - price
-
+// swapStocks transfers existing stocks from a resting seller to the buyer at
+// the seller's price
+func swapStocks(userId, stockSymbol, sellerId string, price types.Amount, stockType string, availableQuantity types.Shares) error {
 	// Initialize stock balances if they don't exist
 	if _, exists := StockBalances[userId]; !exists {
 		StockBalances[userId] = make(types.UserStockBalance)
@@ -197,45 +195,87 @@ func swapStocks(userId, stockSymbol, sellerId string, price float64, stockType s
 
 	// Update USD balances
 	if buyerBalance, exists := USDBalances[userId]; exists {
-		if buyerBalance.Locked >= availableQuantity*correspondingPrice {
-			fmt.Printf("swapStocks: buyer %s locked %.2f -> %.2f (unlock %.2f)\n", userId, buyerBalance.Locked, buyerBalance.Locked-availableQuantity*correspondingPrice, availableQuantity*correspondingPrice)
-			buyerBalance.Locked -= availableQuantity * correspondingPrice
-		} else {
-			fmt.Printf("swapStocks: buyer %s locked %.2f, not enough to unlock %.2f\n", userId, buyerBalance.Locked, availableQuantity*correspondingPrice)
-		}
+		fmt.Printf("swapStocks: buyer %s balance %s -> %s (pay %s)\n", userId, buyerBalance.Balance, buyerBalance.Balance-price.Times(availableQuantity), price.Times(availableQuantity))
+		buyerBalance.Balance -= price.Times(availableQuantity)
 		USDBalances[userId] = buyerBalance
 	}
 
 	if sellerBalance, exists := USDBalances[sellerId]; exists {
-		fmt.Printf("swapStocks: seller %s balance %.2f -> %.2f (add %.2f)\n", sellerId, sellerBalance.Balance, sellerBalance.Balance+availableQuantity*price, availableQuantity*price)
-		sellerBalance.Balance += availableQuantity * price
+		fmt.Printf("swapStocks: seller %s balance %s -> %s (add %s)\n", sellerId, sellerBalance.Balance, sellerBalance.Balance+price.Times(availableQuantity), price.Times(availableQuantity))
+		sellerBalance.Balance += price.Times(availableQuantity)
 		USDBalances[sellerId] = sellerBalance
 	}
 
-	// Send database updates
-	sendUSDBalancesToDB()
+	StockBalances.AddCost(userId, stockSymbol, stockType, price.Times(availableQuantity))
+	StockBalances.AddCost(sellerId, stockSymbol, stockType, -price.Times(availableQuantity))
 
 	return nil
 }
 
-// placeBuyOrder handles buy order placement and matching
+// publishOrderUpdate records a fill or cancellation in the order registry and
+// sends an UPDATE_ORDER message for it to the database
+func publishOrderUpdate(orderId string, filledQty types.Shares, status types.OrderStatus) {
+	OrderRegistry.Update(orderId, filledQty, status)
+
+	updateBytes, _ := json.Marshal(types.OrderUpdate{
+		OrderId:   orderId,
+		FilledQty: filledQty,
+		Status:    status,
+	})
+	updateMsg := types.IncomingMessage{
+		Type: types.UPDATE_ORDER,
+		Data: updateBytes,
+	}
+	updateMsgBytes, _ := json.Marshal(updateMsg)
+	engineToDatabaseQueueClient.LPush(context.Background(), types.DB_ACTIONS, updateMsgBytes)
+}
+
+// restingOrderStatus returns the status of a resting order after a fill
+func restingOrderStatus(remainingQty types.Shares) types.OrderStatus {
+	if remainingQty == 0 {
+		return types.COMPLETED
+	}
+	return types.PARTIALLY_FILLED
+}
+
+// incomingOrderStatus returns the status of an incoming order once matching is done
+func incomingOrderStatus(filledQty, quantity types.Shares) types.OrderStatus {
+	switch {
+	case filledQty == 0:
+		return types.PENDING
+	case filledQty >= quantity:
+		return types.COMPLETED
+	default:
+		return types.PARTIALLY_FILLED
+	}
+}
+
+// placeBuyOrder handles buy order placement and matching.
+//
+// The order sweeps the book of the requested stock type from the best
+// (lowest) price up to its limit price, filling resting orders first-in
+// first-out within each price level. Only when the crossing liquidity is used
+// up does the remainder rest, as a reverted order on the opposite side, and
+// only if its time in force lets it rest.
 func PlaceBuyOrder(orderData types.OrderProps) (map[string]interface{}, error) {
 	userId := orderData.UserId
 	stockSymbol := orderData.StockSymbol
 	quantity := orderData.Quantity
-	price := orderData.Price
-	stockType := orderData.StockType
 
-	// Price validation: USD per share (0-100 range)
-	if price > 100 || price < 0 {
-		return nil, fmt.Errorf("invalid price, price should be between 0 and 100 USD")
+	if err := types.CheckShares(quantity); err != nil {
+		return nil, err
 	}
-	stockPrice := price
-	oppositeStockType := "no"
-	if stockType == "yes" {
-		oppositeStockType = "no"
-	} else {
-		oppositeStockType = "yes"
+
+	if err := MarketsMap.CheckOpen(stockSymbol); err != nil {
+		return nil, err
+	}
+	stockType, err := MarketsMap.StockType(stockSymbol, orderData.StockType)
+	if err != nil {
+		return nil, err
+	}
+	contract := MarketsMap.Contract(stockSymbol)
+	if err := contract.CheckQuantity(quantity); err != nil {
+		return nil, err
 	}
 
 	// Validate user balance
@@ -243,16 +283,38 @@ func PlaceBuyOrder(orderData types.OrderProps) (map[string]interface{}, error) {
 		return nil, fmt.Errorf("user with the given id doesn't exist")
 	}
 
-	// Initialize order book for symbol if it doesn't exist
-	if _, exists := OrderBook[stockSymbol]; !exists {
-		OrderBook[stockSymbol] = types.SymbolOrderBook{
-			Yes: make(types.PriceOrderBook),
-			No:  make(types.PriceOrderBook),
+	// Count what the user holds and is already bidding for, in case all of it fills
+	if contract.MaxPosition != 0 {
+		if err := contract.CheckPosition(heldShares(userId, stockSymbol, stockType) + quantity); err != nil {
+			return nil, err
 		}
 	}
 
-	// Check sufficient balance
-	if USDBalances[userId].Balance < quantity*price {
+	// Initialize order book for symbol if it doesn't exist
+	book := OrderBook.Symbol(stockSymbol).Side(stockType)
+	expireSymbolOrders(stockSymbol, time.Now())
+
+	// Price validation and market order pricing: USD per share (0 to the payout),
+	// from the book or the market maker, whichever is cheaper
+	bestPrice, hasBest := book.Best("")
+	if ammPrice, hasAMM := ammBestPrice(stockSymbol, stockType, true); hasAMM && (!hasBest || ammPrice < bestPrice) {
+		bestPrice, hasBest = ammPrice, true
+	}
+	execution, err := resolveExecution(orderData, true, bestPrice, hasBest, contract)
+	if err != nil {
+		return nil, err
+	}
+	stockPrice := execution.limitPrice
+
+	// Check sufficient balance, assuming the worst case where everything
+	// fills (or rests) at the limit price and pays the taker fee, or the maker
+	// fee reserved when it rests
+	cost := stockPrice.Times(quantity)
+	required := cost + max(fees.TakerFee(userId, stockSymbol, cost, quantity), fees.MakerFee(userId, stockSymbol, cost, quantity))
+	if cost <= 0 || required < cost {
+		return nil, fmt.Errorf("invalid order, %d at %s would cost %s", quantity, stockPrice, required)
+	}
+	if USDBalances[userId].Balance < required {
 		return nil, fmt.Errorf("insufficient balance")
 	}
 
@@ -267,195 +329,298 @@ func PlaceBuyOrder(orderData types.OrderProps) (map[string]interface{}, error) {
 		}
 	}
 
-	requiredQuantity := quantity
 	orderId, _ := gonanoid.New()
+	requiredQuantity := quantity
+	fills := []types.Fill{}
 
-	// Create order record
+	available := book.AvailableQuantity(stockPrice, "") + ammBuyable(stockSymbol, stockType, stockPrice, quantity)
+	if execution.timeInForce == types.FillOrKill && available < quantity {
+		fmt.Printf("PlaceBuyOrder: FOK order %s of %s can't be filled completely, killing it\n", orderId, userId)
+	} else if execution.crossable {
+		requiredQuantity, fills = fillBuyOrder(userId, stockSymbol, stockType, book, stockPrice, quantity)
+		recordTrades(orderId, userId, stockSymbol, stockType, fills)
+	}
+
+	var reservedFee types.Amount
+	if requiredQuantity > 0 && execution.rests() {
+		reservedFee = restRevertedOrder(orderId, userId, stockSymbol, stockType, stockPrice, requiredQuantity, execution.expiresAt)
+	}
+
+	// Create order record with the outcome of matching and send it to the database
+	filledQty := quantity - requiredQuantity
 	orderRecord := types.Order{
 		Id:              orderId,
 		UserId:          userId,
 		OrderType:       types.BUY,
+		Kind:            execution.kind,
+		TimeInForce:     execution.timeInForce,
+		ExpiresAt:       execution.expiresAt,
 		Symbol:          stockSymbol,
 		SymbolStockType: stockType,
 		Price:           stockPrice,
-		Quantity:        requiredQuantity,
-		FilledQty:       0,
-		Status:          types.PENDING,
+		Quantity:        quantity,
+		FilledQty:       filledQty,
+		Status:          finalOrderStatus(execution, filledQty, quantity),
 		CreatedAt:       time.Now().Format(time.RFC3339),
 		UpdatedAt:       time.Now().Format(time.RFC3339),
 	}
+	// The unfilled part of a buy order rests on the opposite side
+	registerOrder(orderRecord, oppositeOf(stockType))
+	publishOrder(orderRecord)
 
-	// Send to database
-	orderDataBytes, _ := json.Marshal(orderRecord)
-	orderMsg := types.IncomingMessage{
-		Type: "ORDER",
-		Data: orderDataBytes,
-	}
-	orderMsgBytes, _ := json.Marshal(orderMsg)
-	engineToDatabaseQueueClient.Publish(context.Background(), "DB_ACTIONS", orderMsgBytes)
+	// Send balance update
+	sendUSDBalancesToDB()
 
-	// Try to match with existing sell orders
-	var priceMap types.PriceOrderBook
-	if stockType == "yes" {
-		priceMap = OrderBook[stockSymbol].Yes
-	} else {
-		priceMap = OrderBook[stockSymbol].No
-	}
-
-	if entry, exists := priceMap[stockPrice]; exists && entry.Total >= requiredQuantity {
-		// Match with existing sell orders
-		entry.Total -= requiredQuantity
-
-		for sellOrderId, sellerOrder := range entry.Orders {
-			if sellerOrder.Quantity > 0 {
-				availableQuantity := math.Min(sellerOrder.Quantity, requiredQuantity)
-
-				if sellerOrder.Type == "reverted" {
-					// Mint new stocks
-					mintStocks(userId, stockSymbol, sellerOrder.UserId, stockPrice, stockType, availableQuantity)
-				} else {
-					// Swap existing stocks
-					swapStocks(userId, stockSymbol, sellerOrder.UserId, stockPrice, stockType, availableQuantity)
-				}
-
-				// Update order records
-				updateOrderData := map[string]interface{}{
-					"orderId":   sellOrderId,
-					"filledQty": availableQuantity,
-					"status":    sellerOrder.Quantity == availableQuantity,
-				}
-				updateBytes, _ := json.Marshal(updateOrderData)
-				updateMsg := types.IncomingMessage{
-					Type: "UPDATE_ORDER",
-					Data: updateBytes,
-				}
-				updateMsgBytes, _ := json.Marshal(updateMsg)
-				engineToDatabaseQueueClient.Publish(context.Background(), "DB_ACTIONS", updateMsgBytes)
-
-				requiredQuantity -= availableQuantity
-
-				// Update buy order
-				buyUpdateData := map[string]interface{}{
-					"orderId":   orderId,
-					"filledQty": availableQuantity,
-					"status":    requiredQuantity == 0,
-				}
-				buyUpdateBytes, _ := json.Marshal(buyUpdateData)
-				buyUpdateMsg := types.IncomingMessage{
-					Type: "UPDATE_ORDER",
-					Data: buyUpdateBytes,
-				}
-				buyUpdateMsgBytes, _ := json.Marshal(buyUpdateMsg)
-				engineToDatabaseQueueClient.Publish(context.Background(), "DB_ACTIONS", buyUpdateMsgBytes)
-
-				sellerOrder.Quantity -= availableQuantity
-				entry.Orders[sellOrderId] = sellerOrder
-
-				if requiredQuantity == 0 {
-					break
-				}
-			}
-		}
+	// Send WebSocket updates
+	publishOrderBook(stockSymbol)
 
-		// Update order book
-		priceMap[stockPrice] = entry
+	return orderResult(orderRecord, fills, requiredQuantity, reservedFee), nil
+}
 
-		// Send WebSocket updates
-		orderBookData, _ := json.Marshal(OrderBook[stockSymbol])
-		wsMsg := types.IncomingMessage{
-			Type: "ORDER_BOOK_UPDATE",
-			Data: orderBookData,
+// fillBuyOrder matches a buy order against the sell orders of book at or
+// below limitPrice, best price first and first-in first-out within a level,
+// and against the market's market maker wherever it's cheaper. It returns
+// the quantity left unfilled and the fills made.
+func fillBuyOrder(userId, stockSymbol, stockType string, book *orderbook.Book, limitPrice types.Amount, quantity types.Shares) (types.Shares, []types.Fill) {
+	requiredQuantity := quantity
+	fills := []types.Fill{}
+
+	for level := range book.Crossing(limitPrice) {
+		levelPrice := level.Price
+
+		// The market maker's shares that beat this level go first
+		requiredQuantity, fills = buyFromAMM(userId, stockSymbol, stockType, levelPrice-types.Cent, requiredQuantity, fills)
+
+		for sellerOrder := level.Front(); sellerOrder != nil && requiredQuantity > 0; {
+			next := sellerOrder.Next()
+			availableQuantity := min(sellerOrder.Quantity, requiredQuantity)
+
+			fillType := "swap"
+			if sellerOrder.Type == "reverted" {
+				// Mint new stocks
+				fillType = "mint"
+				mintStocks(userId, stockSymbol, sellerOrder.UserId, levelPrice, stockType, availableQuantity)
+			} else {
+				// Swap existing stocks
+				swapStocks(userId, stockSymbol, sellerOrder.UserId, levelPrice, stockType, availableQuantity)
+			}
+
+			requiredQuantity -= availableQuantity
+			fills = append(fills, types.Fill{
+				OrderId:  sellerOrder.Id,
+				UserId:   sellerOrder.UserId,
+				Price:    levelPrice,
+				Quantity: availableQuantity,
+				Type:     fillType,
+				Reserved: takeReservedFee(sellerOrder, availableQuantity),
+			})
+
+			// Update the order book and the resting order's record
+			remaining := book.Fill(sellerOrder.Id, availableQuantity)
+			publishOrderUpdate(sellerOrder.Id, availableQuantity, restingOrderStatus(remaining))
+
+			sellerOrder = next
 		}
-		wsBytes, _ := json.Marshal(wsMsg)
-		engineToServerPubSubClient.Publish(context.Background(), stockSymbol, wsBytes)
 
-		return map[string]interface{}{
-			"status":    true,
-			"message":   "Successfully bought the required quantity",
-			"stocks":    StockBalances[userId][stockSymbol],
-			"orderbook": OrderBook[stockSymbol],
-		}, nil
+		if requiredQuantity == 0 {
+			break
+		}
 	}
+	requiredQuantity, fills = buyFromAMM(userId, stockSymbol, stockType, limitPrice, requiredQuantity, fills)
 
-	// No matching sell orders - create reverted order
-	correspondingPrice := 100.0 - stockPrice
-	symbolOrderBook := OrderBook[stockSymbol]
+	return requiredQuantity, fills
+}
 
-	var oppositePriceMap types.PriceOrderBook
-	if oppositeStockType == "yes" {
-		if symbolOrderBook.Yes == nil {
-			symbolOrderBook.Yes = make(types.PriceOrderBook)
-		}
-		oppositePriceMap = symbolOrderBook.Yes
-	} else {
-		if symbolOrderBook.No == nil {
-			symbolOrderBook.No = make(types.PriceOrderBook)
-		}
-		oppositePriceMap = symbolOrderBook.No
+// takeReservedFee takes the part of a resting order's reserved maker fee a
+// fill of quantity shares pays out of, before the book takes the fill
+func takeReservedFee(order *orderbook.Order, quantity types.Shares) types.Amount {
+	reserved := types.ReservedFeeShare(order.Fee, quantity, order.Quantity)
+	order.Fee -= reserved
+	return reserved
+}
+
+// averageFillPrice returns the quantity weighted price of fills, rounded to
+// the nearest cent, 0 if nothing filled
+func averageFillPrice(fills []types.Fill) types.Amount {
+	var quantity types.Shares
+	var notional types.Amount
+	for _, fill := range fills {
+		quantity += fill.Quantity
+		notional += fill.Price.Times(fill.Quantity)
+	}
+	if quantity == 0 {
+		return 0
 	}
+	return (notional + types.Amount(quantity)/2) / types.Amount(quantity)
+}
 
-	if _, exists := oppositePriceMap[correspondingPrice]; !exists {
-		oppositePriceMap[correspondingPrice] = types.PriceLevel{
-			Total:  0,
-			Orders: make(map[string]types.OrderBookEntry),
+// orderResult builds the response for a placed order with its fill breakdown
+// and the maker fee reserved with what rests
+func orderResult(order types.Order, fills []types.Fill, remainingQty types.Shares, reservedFee types.Amount) map[string]interface{} {
+	side := "buy"
+	if order.OrderType == types.SELL {
+		side = "sell"
+	}
+	var takerFees types.Amount
+	for _, fill := range fills {
+		takerFees += fill.TakerFee
+	}
+
+	result := map[string]interface{}{
+		"status":       true,
+		"orderId":      order.Id,
+		"orderStatus":  order.Status,
+		"kind":         order.Kind,
+		"timeInForce":  order.TimeInForce,
+		"filledQty":    order.FilledQty,
+		"remainingQty": remainingQty,
+		"averagePrice": averageFillPrice(fills),
+		"fees":         takerFees, // what the order paid, each fill's are in fills
+		"reservedFee":  reservedFee,
+		"fills":        fills,
+		"stockSymbol":  order.Symbol,
+		"stocks":       StockBalances[order.UserId][order.Symbol],
+	}
+
+	switch order.Status {
+	case types.COMPLETED:
+		if side == "buy" {
+			result["message"] = "Successfully bought the required quantity"
+		} else {
+			result["message"] = "Successfully sold the required quantity"
 		}
+		result["orderbook"] = OrderBook.Symbol(order.Symbol).Snapshot()
+	case types.PARTIALLY_FILLED:
+		result["message"] = fmt.Sprintf("Partially filled the %s order, the remaining quantity is placed in the order book", side)
+	case types.CANCELLED:
+		if order.FilledQty == 0 && order.TimeInForce == types.FillOrKill {
+			result["message"] = "Not enough liquidity to fill the whole order, nothing was filled"
+		} else {
+			result["message"] = fmt.Sprintf("Filled %v of %v, the remaining quantity is cancelled", order.FilledQty, order.Quantity)
+		}
+		remainingQty = 0
+		result["remainingQty"] = remainingQty
+	default:
+		result["message"] = fmt.Sprintf("Successfully placed the %s order", side)
 	}
+	return result
+}
 
-	oppositeEntry := oppositePriceMap[correspondingPrice]
-	oppositeEntry.Total += requiredQuantity
-	oppositeEntry.Orders[orderId] = types.OrderBookEntry{
-		UserId:   userId,
-		Quantity: requiredQuantity,
-		Price:    correspondingPrice,
-		Type:     "reverted",
+// registerOrder adds a placed order to the order registry. restingStockType
+// is the side of the book the order rests on if it isn't done.
+func registerOrder(order types.Order, restingStockType string) {
+	OrderRegistry.Register(orderbook.OrderRecord{
+		Id:        order.Id,
+		UserId:    order.UserId,
+		Symbol:    order.Symbol,
+		StockType: restingStockType,
+		Quantity:  order.Quantity,
+		FilledQty: order.FilledQty,
+		Status:    order.Status,
+	})
+}
+
+// oppositeOf returns "no" for "yes" and "yes" for "no"
+func oppositeOf(stockType string) string {
+	if stockType == "yes" {
+		return "no"
 	}
-	oppositePriceMap[correspondingPrice] = oppositeEntry
+	return "yes"
+}
 
-	// Update the symbol order book
-	if oppositeStockType == "yes" {
-		symbolOrderBook.Yes = oppositePriceMap
-	} else {
-		symbolOrderBook.No = oppositePriceMap
+// heldShares counts the stockType shares a user holds, free or locked, plus
+// those their resting buy orders would still get
+func heldShares(userId, stockSymbol, stockType string) types.Shares {
+	symbolStocks := StockBalances[userId][stockSymbol]
+	held := symbolStocks.Yes.Quantity + symbolStocks.Yes.Locked
+	if stockType == "no" {
+		held = symbolStocks.No.Quantity + symbolStocks.No.Locked
 	}
-	OrderBook[stockSymbol] = symbolOrderBook
 
-	// Lock buyer balance at the corresponding price (since order is placed at corresponding price)
-	userBalance := USDBalances[userId]
-	userBalance.Locked += requiredQuantity * correspondingPrice
-	// Balance is not decreased yet for reverted orders, only when matched
-	USDBalances[userId] = userBalance
+	// Buy orders rest as reverted orders on the opposite side
+	for _, order := range OrderBook.Symbol(stockSymbol).Side(oppositeOf(stockType)).Orders() {
+		if order.UserId == userId && order.Type == "reverted" {
+			held += order.Quantity
+		}
+	}
+	return held
+}
 
-	// Send balance update
-	sendUSDBalancesToDB()
+// publishOrder sends an order record to the database
+func publishOrder(order types.Order) {
+	orderDataBytes, _ := json.Marshal(order)
+	orderMsg := types.IncomingMessage{
+		Type: "ORDER",
+		Data: orderDataBytes,
+	}
+	orderMsgBytes, _ := json.Marshal(orderMsg)
+	engineToDatabaseQueueClient.LPush(context.Background(), types.DB_ACTIONS, orderMsgBytes)
+}
 
-	// Send WebSocket updates
-	orderBookData, _ := json.Marshal(OrderBook[stockSymbol])
+// publishOrderBook sends the order book of a symbol to its subscribers
+func publishOrderBook(stockSymbol string) {
+	orderBookData, _ := json.Marshal(OrderBook.Symbol(stockSymbol).Snapshot())
 	wsMsg := types.IncomingMessage{
 		Type: "ORDER_BOOK_UPDATE",
 		Data: orderBookData,
 	}
 	wsBytes, _ := json.Marshal(wsMsg)
 	engineToServerPubSubClient.Publish(context.Background(), stockSymbol, wsBytes)
+}
 
-	return map[string]interface{}{
-		"status":  true,
-		"message": "Successfully placed the buy order",
-		"stocks":  StockBalances[userId][stockSymbol],
-	}, nil
+// restRevertedOrder rests the unfilled part of a buy order as a reverted sell
+// order on the opposite side at the corresponding price, moving the buyer's
+// funds and maker fee from balance to locked. It returns the fee reserved.
+func restRevertedOrder(orderId, userId, stockSymbol, stockType string, price types.Amount, quantity types.Shares, expiresAt int64) types.Amount {
+	oppositeStockType := oppositeOf(stockType)
+	correspondingPrice := MarketsMap.Contract(stockSymbol).Payout - price
+	fee := fees.MakerFee(userId, stockSymbol, price.Times(quantity), quantity)
+
+	OrderBook.Symbol(stockSymbol).Side(oppositeStockType).Add(orderId, types.OrderBookEntry{
+		UserId:    userId,
+		Quantity:  quantity,
+		Price:     correspondingPrice,
+		Type:      "reverted",
+		ExpiresAt: expiresAt,
+		Fee:       fee,
+	})
+
+	// Lock the buyer's funds at their own limit price until the order fills
+	userBalance := USDBalances[userId]
+	userBalance.Balance -= price.Times(quantity) + fee
+	userBalance.Locked += price.Times(quantity) + fee
+	USDBalances[userId] = userBalance
+	return fee
 }
 
-// placeSellOrder handles sell order placement
+// placeSellOrder handles sell order placement and matching.
+//
+// A sell order first crosses the opposite side of the book, best price for
+// the seller first: resting buy orders for the same stock type, which sit
+// there as reverted orders, and sell orders for the other stock type, whose
+// shares are burned together with the seller's when the two asks add up to at
+// most the payout. The remainder rests as a regular sell order if its time
+// in force lets it rest.
 func PlaceSellOrder(orderData types.OrderProps) (map[string]interface{}, error) {
 	userId := orderData.UserId
 	stockSymbol := orderData.StockSymbol
 	quantity := orderData.Quantity
-	price := orderData.Price
-	stockType := orderData.StockType
 
-	if price > 100 || price < 0 {
-		return nil, fmt.Errorf("price should be between 0 and 100 USD")
+	if err := types.CheckShares(quantity); err != nil {
+		return nil, err
+	}
+
+	if err := MarketsMap.CheckOpen(stockSymbol); err != nil {
+		return nil, err
+	}
+	stockType, err := MarketsMap.StockType(stockSymbol, orderData.StockType)
+	if err != nil {
+		return nil, err
+	}
+	contract := MarketsMap.Contract(stockSymbol)
+	if err := contract.CheckQuantity(quantity); err != nil {
+		return nil, err
 	}
-	stockPrice := price
 
 	if _, exists := USDBalances[userId]; !exists {
 		return nil, fmt.Errorf("user with the given id doesn't exist")
@@ -469,9 +634,14 @@ func PlaceSellOrder(orderData types.OrderProps) (map[string]interface{}, error)
 		return nil, fmt.Errorf("user doesn't have stocks for this symbol")
 	}
 
+	// Expire first, releasing stocks of the user's own expired orders before
+	// their balance is read
+	symbolBook := OrderBook.Symbol(stockSymbol)
+	expireSymbolOrders(stockSymbol, time.Now())
+
 	// Check if user has sufficient stocks
 	userStocks := StockBalances[userId][stockSymbol]
-	var availableQuantity float64
+	var availableQuantity types.Shares
 	if stockType == "yes" {
 		availableQuantity = userStocks.Yes.Quantity
 	} else {
@@ -482,52 +652,41 @@ func PlaceSellOrder(orderData types.OrderProps) (map[string]interface{}, error)
 		return nil, fmt.Errorf("user doesn't have the required quantity")
 	}
 
-	// Initialize order book
-	if _, exists := OrderBook[stockSymbol]; !exists {
-		OrderBook[stockSymbol] = types.SymbolOrderBook{
-			Yes: make(types.PriceOrderBook),
-			No:  make(types.PriceOrderBook),
-		}
+	// Buy orders for this stock type rest as reverted orders on the opposite
+	// side, next to the sell orders for the other stock type
+	oppositeBook := symbolBook.No
+	if stockType == "no" {
+		oppositeBook = symbolBook.Yes
 	}
 
-	symbolOrderBook := OrderBook[stockSymbol]
-	var priceMap types.PriceOrderBook
-	if stockType == "yes" {
-		priceMap = symbolOrderBook.Yes
-	} else {
-		priceMap = symbolOrderBook.No
+	bestLevel, hasBid := oppositeBook.Best("")
+	bestBid := contract.Payout - bestLevel
+	if ammPrice, hasAMM := ammBestPrice(stockSymbol, stockType, false); hasAMM && (!hasBid || ammPrice > bestBid) {
+		bestBid, hasBid = ammPrice, true
 	}
-
-	if _, exists := priceMap[stockPrice]; !exists {
-		priceMap[stockPrice] = types.PriceLevel{
-			Total:  0,
-			Orders: make(map[string]types.OrderBookEntry),
-		}
+	execution, err := resolveExecution(orderData, false, bestBid, hasBid, contract)
+	if err != nil {
+		return nil, err
 	}
+	stockPrice := execution.limitPrice
 
-	// Generate order ID
-	orderId, _ := gonanoid.New()
-
-	// Add to order book
-	priceLevel := priceMap[stockPrice]
-	priceLevel.Total += quantity
-	priceLevel.Orders[orderId] = types.OrderBookEntry{
-		Quantity: quantity,
-		Price:    stockPrice,
-		Type:     "regular",
-		UserId:   userId,
-	}
-	priceMap[stockPrice] = priceLevel
+	// A bid at price p rests at payout - p, so bids at or above the limit
+	// price rest at or below payout - limit. So do opposite asks that leave
+	// at least the limit price out of the payout a burned pair frees.
+	maxLevel := contract.Payout - stockPrice
+	available := oppositeBook.AvailableQuantity(maxLevel, "") + ammSellable(stockSymbol, stockType, stockPrice, quantity)
 
-	// Update symbol order book
-	if stockType == "yes" {
-		symbolOrderBook.Yes = priceMap
-	} else {
-		symbolOrderBook.No = priceMap
+	// What won't fill rests and reserves its maker fee, so the balance has to cover it
+	if resting := quantity - min(available, quantity); execution.rests() && resting > 0 {
+		if !execution.crossable {
+			resting = quantity
+		}
+		if USDBalances[userId].Balance < fees.MakerFee(userId, stockSymbol, stockPrice.Times(resting), resting) {
+			return nil, fmt.Errorf("insufficient balance for the maker fee")
+		}
 	}
-	OrderBook[stockSymbol] = symbolOrderBook
 
-	// Lock user stocks
+	// Lock user stocks while the order is matched or resting
 	if stockType == "yes" {
 		userStocks.Yes.Quantity -= quantity
 		userStocks.Yes.Locked += quantity
@@ -537,122 +696,284 @@ func PlaceSellOrder(orderData types.OrderProps) (map[string]interface{}, error)
 	}
 	StockBalances[userId][stockSymbol] = userStocks
 
+	// Generate order ID
+	orderId, _ := gonanoid.New()
+	remainingQuantity := quantity
+	fills := []types.Fill{}
+	if execution.timeInForce == types.FillOrKill && available < quantity {
+		fmt.Printf("PlaceSellOrder: FOK order %s of %s can't be filled completely, killing it\n", orderId, userId)
+	} else if execution.crossable {
+		remainingQuantity, fills = fillSellOrder(userId, stockSymbol, stockType, oppositeBook, maxLevel, quantity)
+		recordTrades(orderId, userId, stockSymbol, stockType, fills)
+	}
+
+	var reservedFee types.Amount
+	if remainingQuantity > 0 {
+		if execution.rests() {
+			reservedFee = restSellOrder(orderId, userId, stockSymbol, stockType, stockPrice, remainingQuantity, execution.expiresAt)
+		} else {
+			releaseOrder(types.OrderBookEntry{UserId: userId, Quantity: remainingQuantity, Type: "regular"}, stockSymbol, stockType)
+		}
+	}
+
 	// Create order record
+	filledQty := quantity - remainingQuantity
 	orderRecord := types.Order{
 		Id:              orderId,
 		UserId:          userId,
 		OrderType:       types.SELL,
+		Kind:            execution.kind,
+		TimeInForce:     execution.timeInForce,
+		ExpiresAt:       execution.expiresAt,
 		Symbol:          stockSymbol,
 		SymbolStockType: stockType,
 		Price:           stockPrice,
 		Quantity:        quantity,
-		FilledQty:       0,
-		Status:          types.PENDING,
+		FilledQty:       filledQty,
+		Status:          finalOrderStatus(execution, filledQty, quantity),
 		CreatedAt:       time.Now().Format(time.RFC3339),
 		UpdatedAt:       time.Now().Format(time.RFC3339),
 	}
-
-	// Send to database
-	orderDataBytes, _ := json.Marshal(orderRecord)
-	orderMsg := types.IncomingMessage{
-		Type: "ORDER",
-		Data: orderDataBytes,
-	}
-	orderMsgBytes, _ := json.Marshal(orderMsg)
-	engineToDatabaseQueueClient.Publish(context.Background(), "DB_ACTIONS", orderMsgBytes)
+	registerOrder(orderRecord, stockType)
+	publishOrder(orderRecord)
 
 	// Send stock balance update
 	sendUSDBalancesToDB()
 
-	return map[string]interface{}{
-		"status":      true,
-		"message":     "Successfully placed the sell order",
-		"stockSymbol": stockSymbol,
-	}, nil
+	// Send WebSocket updates
+	publishOrderBook(stockSymbol)
+
+	return orderResult(orderRecord, fills, remainingQuantity, reservedFee), nil
 }
 
-// cancelOrder handles order cancellation
-func CancelOrder(cancelReq types.CancelOrderProps) error {
-	userId := cancelReq.UserId
-	stockSymbol := cancelReq.StockSymbol
-	price := cancelReq.Price
-	orderId := cancelReq.OrderId
-	stockType := cancelReq.StockType
+// fillSellOrder matches a sell order, whose stocks are already locked, against
+// the orders of oppositeBook resting at or below maxLevel, best price first
+// and first-in first-out within a level. Reverted buy orders take the shares;
+// sell orders for the other stock type are burned together with them. Either
+// way the seller gets the payout minus the level price per share. The
+// market's market maker buys wherever it pays more. It returns the quantity
+// left unfilled and the fills made.
+func fillSellOrder(userId, stockSymbol, stockType string, oppositeBook *orderbook.Book, maxLevel types.Amount, quantity types.Shares) (types.Shares, []types.Fill) {
+	remainingQuantity := quantity
+	fills := []types.Fill{}
+	payout := MarketsMap.Contract(stockSymbol).Payout
+
+	for level := range oppositeBook.Crossing(maxLevel) {
+		bidPrice := payout - level.Price
+
+		// The market maker's bids that beat this level go first
+		remainingQuantity, fills = sellToAMM(userId, stockSymbol, stockType, bidPrice+types.Cent, remainingQuantity, fills)
+
+		for restingOrder := level.Front(); restingOrder != nil && remainingQuantity > 0; {
+			next := restingOrder.Next()
+			fillQuantity := min(restingOrder.Quantity, remainingQuantity)
+
+			fillType := "swap"
+			if restingOrder.Type == "reverted" {
+				sellToRevertedOrder(userId, stockSymbol, restingOrder.UserId, bidPrice, stockType, fillQuantity)
+			} else {
+				// Burn both sides of the pair
+				fillType = "burn"
+				burnStocks(userId, stockSymbol, restingOrder.UserId, bidPrice, stockType, fillQuantity)
+			}
 
-	if _, exists := USDBalances[userId]; !exists {
-		return fmt.Errorf("user with the given id doesn't exist")
+			remainingQuantity -= fillQuantity
+			fills = append(fills, types.Fill{
+				OrderId:  restingOrder.Id,
+				UserId:   restingOrder.UserId,
+				Price:    bidPrice,
+				Quantity: fillQuantity,
+				Type:     fillType,
+				Reserved: takeReservedFee(restingOrder, fillQuantity),
+			})
+
+			// Update the order book and the resting order's record
+			remaining := oppositeBook.Fill(restingOrder.Id, fillQuantity)
+			publishOrderUpdate(restingOrder.Id, fillQuantity, restingOrderStatus(remaining))
+
+			restingOrder = next
+		}
+
+		if remainingQuantity == 0 {
+			break
+		}
 	}
+	remainingQuantity, fills = sellToAMM(userId, stockSymbol, stockType, payout-maxLevel, remainingQuantity, fills)
+
+	return remainingQuantity, fills
+}
 
-	if _, exists := OrderBook[stockSymbol]; !exists {
-		return fmt.Errorf("order book doesn't exist")
+// sellToRevertedOrder transfers a seller's locked stocks to the owner of a
+// resting buy order, paid at the buyer's price out of their locked funds
+func sellToRevertedOrder(sellerId, stockSymbol, buyerId string, price types.Amount, stockType string, quantity types.Shares) {
+	if _, exists := StockBalances[buyerId]; !exists {
+		StockBalances[buyerId] = make(types.UserStockBalance)
+	}
+	if _, exists := StockBalances[buyerId][stockSymbol]; !exists {
+		StockBalances[buyerId][stockSymbol] = types.SymbolStockBalance{
+			Yes: types.StockPosition{Quantity: 0, Locked: 0},
+			No:  types.StockPosition{Quantity: 0, Locked: 0},
+		}
 	}
 
-	var priceMap types.PriceOrderBook
+	// Seller's stocks were locked when the sell order came in
+	sellerStocks := StockBalances[sellerId][stockSymbol]
 	if stockType == "yes" {
-		priceMap = OrderBook[stockSymbol].Yes
+		sellerStocks.Yes.Locked -= quantity
 	} else {
-		priceMap = OrderBook[stockSymbol].No
+		sellerStocks.No.Locked -= quantity
 	}
+	StockBalances[sellerId][stockSymbol] = sellerStocks
 
-	if _, exists := priceMap[price]; !exists {
-		return fmt.Errorf("price level not found")
+	buyerStocks := StockBalances[buyerId][stockSymbol]
+	if stockType == "yes" {
+		buyerStocks.Yes.Quantity += quantity
+	} else {
+		buyerStocks.No.Quantity += quantity
 	}
+	StockBalances[buyerId][stockSymbol] = buyerStocks
 
-	priceLevel := priceMap[price]
-	order, exists := priceLevel.Orders[orderId]
-	if !exists {
-		return fmt.Errorf("order doesn't exist")
+	if buyerBalance, exists := USDBalances[buyerId]; exists {
+		fmt.Printf("sellToRevertedOrder: buyer %s locked %s -> %s (spend %s)\n", buyerId, buyerBalance.Locked, buyerBalance.Locked-price.Times(quantity), price.Times(quantity))
+		buyerBalance.Locked -= price.Times(quantity)
+		USDBalances[buyerId] = buyerBalance
 	}
 
-	if order.UserId != userId {
-		return fmt.Errorf("user doesn't have permission to cancel this order")
+	if sellerBalance, exists := USDBalances[sellerId]; exists {
+		sellerBalance.Balance += price.Times(quantity)
+		USDBalances[sellerId] = sellerBalance
 	}
 
-	// Unlock balances based on order type
-	if order.Type == "reverted" {
-		// Unlock USD balance (from buy order)
-		userBalance := USDBalances[userId]
-		userBalance.Locked -= order.Quantity * order.Price
-		userBalance.Balance += order.Quantity * order.Price
-		USDBalances[userId] = userBalance
+	StockBalances.AddCost(buyerId, stockSymbol, stockType, price.Times(quantity))
+	StockBalances.AddCost(sellerId, stockSymbol, stockType, -price.Times(quantity))
+}
+
+// burnStocks matches a seller's locked stocks with the locked opposite stocks
+// of a resting sell order and burns the pairs. Each pair frees the payout: the
+// seller gets price per share and the resting order's owner gets its own ask,
+// payout - price.
+func burnStocks(sellerId, stockSymbol, restingUserId string, price types.Amount, stockType string, quantity types.Shares) {
+	oppositeStockType := oppositeOf(stockType)
+	restingPrice := MarketsMap.Contract(stockSymbol).Payout - price
+
+	// Both sides' stocks were locked when their sell orders came in
+	sellerStocks := StockBalances[sellerId][stockSymbol]
+	if stockType == "yes" {
+		sellerStocks.Yes.Locked -= quantity
 	} else {
-		// Unlock stocks (from sell order)
-		if userStocks, exists := StockBalances[userId]; exists {
-			if symbolStocks, exists := userStocks[stockSymbol]; exists {
-				if stockType == "yes" {
-					symbolStocks.Yes.Locked -= order.Quantity
-					symbolStocks.Yes.Quantity += order.Quantity
-				} else {
-					symbolStocks.No.Locked -= order.Quantity
-					symbolStocks.No.Quantity += order.Quantity
-				}
-				userStocks[stockSymbol] = symbolStocks
-				StockBalances[userId] = userStocks
-			}
-		}
+		sellerStocks.No.Locked -= quantity
+	}
+	StockBalances[sellerId][stockSymbol] = sellerStocks
+
+	restingStocks := StockBalances[restingUserId][stockSymbol]
+	if oppositeStockType == "yes" {
+		restingStocks.Yes.Locked -= quantity
+	} else {
+		restingStocks.No.Locked -= quantity
+	}
+	StockBalances[restingUserId][stockSymbol] = restingStocks
+
+	if sellerBalance, exists := USDBalances[sellerId]; exists {
+		fmt.Printf("burnStocks: seller %s balance %s -> %s (add %s)\n", sellerId, sellerBalance.Balance, sellerBalance.Balance+price.Times(quantity), price.Times(quantity))
+		sellerBalance.Balance += price.Times(quantity)
+		USDBalances[sellerId] = sellerBalance
+	}
+
+	if restingBalance, exists := USDBalances[restingUserId]; exists {
+		fmt.Printf("burnStocks: resting seller %s balance %s -> %s (add %s)\n", restingUserId, restingBalance.Balance, restingBalance.Balance+restingPrice.Times(quantity), restingPrice.Times(quantity))
+		restingBalance.Balance += restingPrice.Times(quantity)
+		USDBalances[restingUserId] = restingBalance
+	}
+
+	StockBalances.AddCost(sellerId, stockSymbol, stockType, -price.Times(quantity))
+	StockBalances.AddCost(restingUserId, stockSymbol, oppositeStockType, -restingPrice.Times(quantity))
+}
+
+// restSellOrder rests the unfilled part of a sell order as a regular order;
+// its stocks are already locked, and its maker fee is locked with it. It
+// returns the fee reserved.
+func restSellOrder(orderId, userId, stockSymbol, stockType string, price types.Amount, quantity types.Shares, expiresAt int64) types.Amount {
+	fee := fees.MakerFee(userId, stockSymbol, price.Times(quantity), quantity)
+	OrderBook.Symbol(stockSymbol).Side(stockType).Add(orderId, types.OrderBookEntry{
+		Quantity:  quantity,
+		Price:     price,
+		Type:      "regular",
+		UserId:    userId,
+		ExpiresAt: expiresAt,
+		Fee:       fee,
+	})
+
+	userBalance := USDBalances[userId]
+	userBalance.Balance -= fee
+	userBalance.Locked += fee
+	USDBalances[userId] = userBalance
+	return fee
+}
+
+// CancelOrder cancels a resting order by its id. Only the order's owner may
+// cancel it, and only while some of it is still resting in the book.
+func CancelOrder(cancelReq types.CancelOrderProps) (map[string]interface{}, error) {
+	userId := cancelReq.UserId
+	orderId := cancelReq.OrderId
+
+	if orderId == "" {
+		return nil, fmt.Errorf("orderId is required")
+	}
+
+	record, exists := OrderRegistry[orderId]
+	if !exists {
+		return nil, fmt.Errorf("order %s doesn't exist", orderId)
 	}
 
+	if record.UserId != userId {
+		return nil, fmt.Errorf("user doesn't have permission to cancel this order")
+	}
+
+	switch record.Status {
+	case types.COMPLETED:
+		return nil, fmt.Errorf("order %s is already filled", orderId)
+	case types.CANCELLED:
+		return nil, fmt.Errorf("order %s is already cancelled", orderId)
+	case types.EXPIRED:
+		return nil, fmt.Errorf("order %s has already expired", orderId)
+	}
+
+	symbolBook, exists := OrderBook[record.Symbol]
+	if !exists {
+		return nil, fmt.Errorf("order book doesn't exist")
+	}
+
+	order, exists := symbolBook.Side(record.StockType).Get(orderId)
+	if !exists {
+		return nil, fmt.Errorf("order %s isn't resting in the order book", orderId)
+	}
+	cancelledQty := order.Quantity
+
+	// Unlock balances based on order type
+	releaseOrder(order.OrderBookEntry, record.Symbol, record.StockType)
+
 	// Remove from order book
-	err := orderbook.RemoveFromOrderBook(orderId, stockSymbol, stockType, price)
+	err := orderbook.RemoveFromOrderBook(orderId, record.Symbol, record.StockType)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Send database updates
 	sendUSDBalancesToDB()
 
 	// Update order status in database
-	orderUpdate := map[string]interface{}{
-		"orderId": orderId,
-		"status":  "CANCELLED",
-	}
-	orderBytes, _ := json.Marshal(orderUpdate)
-	orderMsg := types.IncomingMessage{
-		Type: "UPDATE_ORDER",
-		Data: orderBytes,
-	}
-	orderMsgBytes, _ := json.Marshal(orderMsg)
-	engineToDatabaseQueueClient.Publish(context.Background(), "DB_ACTIONS", orderMsgBytes)
+	publishOrderUpdate(orderId, 0, types.CANCELLED)
 
-	return nil
+	// Send WebSocket updates
+	publishOrderBook(record.Symbol)
+
+	return map[string]interface{}{
+		"status":       true,
+		"orderId":      orderId,
+		"orderStatus":  types.CANCELLED,
+		"stockSymbol":  record.Symbol,
+		"filledQty":    record.FilledQty,
+		"cancelledQty": cancelledQty,
+		"message":      "Order cancelled",
+	}, nil
 }
@@ -0,0 +1,264 @@
+package trading
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/adityadeshlahre/probo-v1/engine/fees"
+	"github.com/adityadeshlahre/probo-v1/engine/orderbook"
+	types "github.com/adityadeshlahre/probo-v1/shared/types"
+	"github.com/redis/go-redis/v9"
+)
+
+const testSymbol = "TEST"
+
+// setupEngine gives the package fresh balances, books and one open market,
+// with Redis never reachable so publishing fails straight away
+func setupEngine(t *testing.T) {
+	t.Helper()
+	offline := redis.NewClient(&redis.Options{
+		Dialer: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return nil, errors.New("offline")
+		},
+		MaxRetries: -1,
+	})
+	usdBalances := make(types.USDBalances)
+	stockBalances := make(types.StockBalances)
+	orderBook := make(orderbook.Books)
+	orderRegistry := make(orderbook.Registry)
+	markets := types.Markets{testSymbol: {StockSymbol: testSymbol, Status: types.MarketOpen}}
+	SetClients(offline, offline)
+	SetDataStructures(usdBalances, stockBalances, orderBook, orderRegistry, markets)
+	orderbook.SetDataStructures(orderBook, orderRegistry)
+	fees.SetClients(offline)
+	fees.SetDataStructures(usdBalances, markets)
+}
+
+// fund gives a user USD and yes/no shares of the test market
+func fund(userId string, usd types.Amount, yes, no types.Shares) {
+	USDBalances[userId] = types.USDBalance{Balance: usd}
+	StockBalances[userId] = types.UserStockBalance{testSymbol: {
+		Yes: types.StockPosition{Quantity: yes},
+		No:  types.StockPosition{Quantity: no},
+	}}
+}
+
+func limit(userId, stockType string, price types.Amount, quantity types.Shares) types.OrderProps {
+	return types.OrderProps{UserId: userId, StockSymbol: testSymbol, StockType: stockType, Price: price, Quantity: quantity}
+}
+
+func buy(t *testing.T, order types.OrderProps) map[string]interface{} {
+	t.Helper()
+	result, err := PlaceBuyOrder(order)
+	if err != nil {
+		t.Fatalf("buy %+v: %v", order, err)
+	}
+	return result
+}
+
+func sell(t *testing.T, order types.OrderProps) map[string]interface{} {
+	t.Helper()
+	result, err := PlaceSellOrder(order)
+	if err != nil {
+		t.Fatalf("sell %+v: %v", order, err)
+	}
+	return result
+}
+
+// wantFills checks an order's fills, in order
+func wantFills(t *testing.T, result map[string]interface{}, want []types.Fill) {
+	t.Helper()
+	fills := result["fills"].([]types.Fill)
+	if len(fills) != len(want) {
+		t.Fatalf("got %d fills %+v, want %d", len(fills), fills, len(want))
+	}
+	for i, fill := range fills {
+		if fill.UserId != want[i].UserId || fill.Price != want[i].Price || fill.Quantity != want[i].Quantity || fill.Type != want[i].Type {
+			t.Errorf("fill %d is %s %d at %s (%s), want %s %d at %s (%s)", i,
+				fill.UserId, fill.Quantity, fill.Price, fill.Type,
+				want[i].UserId, want[i].Quantity, want[i].Price, want[i].Type)
+		}
+	}
+}
+
+func TestBuyFillsAtMakerPrice(t *testing.T) {
+	setupEngine(t)
+	fund("s1", 0, 5, 0)
+	fund("s2", 0, 5, 0)
+	fund("buyer", 1000*types.USD, 0, 0)
+	sell(t, limit("s2", "yes", 45*types.USD, 5))
+	sell(t, limit("s1", "yes", 40*types.USD, 5))
+
+	result := buy(t, limit("buyer", "yes", 60*types.USD, 8))
+	wantFills(t, result, []types.Fill{
+		{UserId: "s1", Price: 40 * types.USD, Quantity: 5, Type: "swap"},
+		{UserId: "s2", Price: 45 * types.USD, Quantity: 3, Type: "swap"},
+	})
+	if result["orderStatus"] != types.COMPLETED {
+		t.Errorf("order is %v, want COMPLETED", result["orderStatus"])
+	}
+	if want := 1000*types.USD - 335*types.USD; USDBalances["buyer"] != (types.USDBalance{Balance: want}) {
+		t.Errorf("buyer has %+v, want %s free and nothing locked", USDBalances["buyer"], want)
+	}
+	if got := StockBalances["buyer"][testSymbol].Yes.Quantity; got != 8 {
+		t.Errorf("buyer has %d yes, want 8", got)
+	}
+	if got := USDBalances["s1"].Balance; got != 200*types.USD {
+		t.Errorf("s1 got %s, want 200.00", got)
+	}
+	if got := OrderBook.Symbol(testSymbol).Yes.AvailableQuantity(types.MaxPrice, ""); got != 2 {
+		t.Errorf("%d yes left in the book, want 2", got)
+	}
+}
+
+func TestFIFOWithinLevel(t *testing.T) {
+	setupEngine(t)
+	for _, seller := range []string{"a", "b", "c"} {
+		fund(seller, 0, 2, 0)
+		sell(t, limit(seller, "yes", 50*types.USD, 2))
+	}
+	fund("buyer", 1000*types.USD, 0, 0)
+
+	wantFills(t, buy(t, limit("buyer", "yes", 50*types.USD, 3)), []types.Fill{
+		{UserId: "a", Price: 50 * types.USD, Quantity: 2, Type: "swap"},
+		{UserId: "b", Price: 50 * types.USD, Quantity: 1, Type: "swap"},
+	})
+	wantFills(t, buy(t, limit("buyer", "yes", 50*types.USD, 2)), []types.Fill{
+		{UserId: "b", Price: 50 * types.USD, Quantity: 1, Type: "swap"},
+		{UserId: "c", Price: 50 * types.USD, Quantity: 1, Type: "swap"},
+	})
+}
+
+func TestFillTypes(t *testing.T) {
+	t.Run("mint", func(t *testing.T) {
+		setupEngine(t)
+		fund("noBuyer", 1000*types.USD, 0, 0)
+		fund("yesBuyer", 1000*types.USD, 0, 0)
+		// A NO bid at 30 rests as a reverted YES ask at 70
+		buy(t, limit("noBuyer", "no", 30*types.USD, 4))
+
+		wantFills(t, buy(t, limit("yesBuyer", "yes", 70*types.USD, 4)), []types.Fill{
+			{UserId: "noBuyer", Price: 70 * types.USD, Quantity: 4, Type: "mint"},
+		})
+		if got := USDBalances["noBuyer"]; got != (types.USDBalance{Balance: 880 * types.USD}) {
+			t.Errorf("noBuyer has %+v, want 120.00 spent and nothing locked", got)
+		}
+		if got := StockBalances["noBuyer"][testSymbol].No.Quantity; got != 4 {
+			t.Errorf("noBuyer has %d no, want 4", got)
+		}
+		if got := StockBalances["yesBuyer"][testSymbol].Yes.Quantity; got != 4 {
+			t.Errorf("yesBuyer has %d yes, want 4", got)
+		}
+	})
+
+	t.Run("swap with a resting bid", func(t *testing.T) {
+		setupEngine(t)
+		fund("bidder", 1000*types.USD, 0, 0)
+		fund("seller", 0, 3, 0)
+		buy(t, limit("bidder", "yes", 60*types.USD, 3))
+
+		// The seller asks less than the bid and gets the bid
+		wantFills(t, sell(t, limit("seller", "yes", 55*types.USD, 3)), []types.Fill{
+			{UserId: "bidder", Price: 60 * types.USD, Quantity: 3, Type: "swap"},
+		})
+		if got := USDBalances["seller"].Balance; got != 180*types.USD {
+			t.Errorf("seller got %s, want 180.00", got)
+		}
+		if got := USDBalances["bidder"].Locked; got != 0 {
+			t.Errorf("bidder still has %s locked", got)
+		}
+	})
+
+	t.Run("burn", func(t *testing.T) {
+		setupEngine(t)
+		fund("yesSeller", 0, 3, 0)
+		fund("noSeller", 0, 0, 3)
+		sell(t, limit("noSeller", "no", 35*types.USD, 3))
+
+		// Asks of 65 and 35 add up to the payout, so the pairs are burned
+		wantFills(t, sell(t, limit("yesSeller", "yes", 65*types.USD, 3)), []types.Fill{
+			{UserId: "noSeller", Price: 65 * types.USD, Quantity: 3, Type: "burn"},
+		})
+		if got := USDBalances["yesSeller"].Balance; got != 195*types.USD {
+			t.Errorf("yesSeller got %s, want 195.00", got)
+		}
+		if got := USDBalances["noSeller"].Balance; got != 105*types.USD {
+			t.Errorf("noSeller got %s, want 105.00", got)
+		}
+		if got := StockBalances["yesSeller"][testSymbol].Yes; got.Quantity != 0 || got.Locked != 0 {
+			t.Errorf("yesSeller still has %+v yes", got)
+		}
+		if got := StockBalances["noSeller"][testSymbol].No; got.Quantity != 0 || got.Locked != 0 {
+			t.Errorf("noSeller still has %+v no", got)
+		}
+	})
+}
+
+// An order whose cost doesn't fit in an Amount is refused without touching
+// the buyer's balance, however the quantity gets past the request
+func TestOverflowingBuy(t *testing.T) {
+	setupEngine(t)
+	fund("buyer", 1*types.USD, 0, 0)
+
+	for _, quantity := range []types.Shares{2767011611056433, types.MaxShares + 1, -1} {
+		if _, err := PlaceBuyOrder(limit("buyer", "yes", 50*types.USD, quantity)); err == nil {
+			t.Errorf("buy of %d at 50.00 with 1.00 was accepted", quantity)
+		}
+	}
+	if _, err := PlaceBuyOrder(limit("buyer", "yes", 50*types.USD, types.MaxShares)); err == nil || err.Error() != "insufficient balance" {
+		t.Errorf("buy of %d at 50.00 with 1.00: got %v, want insufficient balance", types.MaxShares, err)
+	}
+	if balance := USDBalances["buyer"]; balance != (types.USDBalance{Balance: 1 * types.USD}) {
+		t.Errorf("buyer is left with %+v, want the 1.00 it had", balance)
+	}
+	if book := OrderBook.Symbol(testSymbol); len(book.No.Orders()) != 0 {
+		t.Errorf("refused buys rest in the book: %+v", book.No.Orders())
+	}
+}
+
+// A resting order reserves its maker fee, so a maker who spends the rest of
+// their balance still pays it, and gets back what wasn't filled
+func TestMakerFeeReserved(t *testing.T) {
+	setupEngine(t)
+	fees.SetConfig(types.FeeConfig{Default: types.FeeSchedule{MakerBps: 100, TakerBps: 100}})
+	t.Cleanup(func() { fees.SetConfig(types.FeeConfig{}) })
+	fund("maker", 505*types.USD, 0, 0)
+	fund("seller", 0, 10, 0)
+
+	rested := buy(t, limit("maker", "yes", 50*types.USD, 10))
+	if got := rested["reservedFee"]; got != 5*types.USD {
+		t.Errorf("resting 10 at 50.00 reserved %v, want 5.00", got)
+	}
+	if balance := USDBalances["maker"]; balance != (types.USDBalance{Locked: 505 * types.USD}) {
+		t.Fatalf("maker has %+v, want the cost and fee locked", balance)
+	}
+
+	result := sell(t, limit("seller", "yes", 50*types.USD, 4))
+	if fill := result["fills"].([]types.Fill)[0]; fill.MakerFee != 2*types.USD || fill.TakerFee != 2*types.USD {
+		t.Errorf("maker paid %s and taker %s, want 2.00 each", fill.MakerFee, fill.TakerFee)
+	}
+	if balance := USDBalances["maker"]; balance != (types.USDBalance{Locked: 303 * types.USD}) {
+		t.Errorf("maker has %+v, want 303.00 locked for the rest", balance)
+	}
+
+	if _, err := CancelOrder(types.CancelOrderProps{UserId: "maker", OrderId: rested["orderId"].(string)}); err != nil {
+		t.Fatal(err)
+	}
+	if balance := USDBalances["maker"]; balance != (types.USDBalance{Balance: 303 * types.USD}) {
+		t.Errorf("maker has %+v after cancelling, want 303.00 free", balance)
+	}
+	if got := USDBalances[types.FeeAccount].Balance; got != 4*types.USD {
+		t.Errorf("fee account has %s, want 4.00", got)
+	}
+
+	// A sell that can rest needs its maker fee up front
+	fund("broke", 0, 10, 0)
+	if _, err := PlaceSellOrder(limit("broke", "yes", 90*types.USD, 10)); err == nil {
+		t.Error("sell resting without the balance for its maker fee was accepted")
+	}
+	if stocks := StockBalances["broke"][testSymbol].Yes; stocks.Quantity != 10 || stocks.Locked != 0 {
+		t.Errorf("refused sell left %+v, want the 10 yes free", stocks)
+	}
+}
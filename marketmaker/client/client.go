@@ -0,0 +1,182 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	types "github.com/adityadeshlahre/probo-v1/shared/types"
+	"github.com/gorilla/websocket"
+)
+
+// Client trades for one user through the server's public API
+type Client struct {
+	ServerURL string
+	UserId    string
+	http      *http.Client
+}
+
+func New(serverURL, userId string) *Client {
+	return &Client{
+		ServerURL: serverURL,
+		UserId:    userId,
+		http:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// PlacedOrder is what the engine says about an order it took
+type PlacedOrder struct {
+	OrderId     string            `json:"orderId"`
+	OrderStatus types.OrderStatus `json:"orderStatus"`
+	FilledQty   types.Shares      `json:"filledQty"`
+	Fills       []types.Fill      `json:"fills"`
+	ReservedFee types.Amount      `json:"reservedFee"` // maker fee locked with what rests
+}
+
+// EnsureUser creates the user, with the starting USD, unless it already has
+// USD or stocks. The engine refuses to create a user that exists.
+func (c *Client) EnsureUser() error {
+	balance, err := c.Balance()
+	if err != nil {
+		return err
+	}
+	stocks, err := c.Stocks()
+	if err != nil {
+		return err
+	}
+	if balance.Balance > 0 || balance.Locked > 0 || len(stocks) > 0 {
+		return nil
+	}
+	return c.post("/user/"+c.UserId, nil, nil)
+}
+
+// Balance returns the user's USD
+func (c *Client) Balance() (types.USDBalance, error) {
+	var resp struct {
+		Data struct {
+			Balance types.USDBalance `json:"balance"`
+		} `json:"data"`
+	}
+	err := c.get("/balance/get/"+c.UserId, &resp)
+	return resp.Data.Balance, err
+}
+
+// Stocks returns the user's stocks of every symbol
+func (c *Client) Stocks() (types.UserStockBalance, error) {
+	var resp struct {
+		Data struct {
+			Stocks types.UserStockBalance `json:"stocks"`
+		} `json:"data"`
+	}
+	err := c.get("/balance/stocks/"+c.UserId, &resp)
+	return resp.Data.Stocks, err
+}
+
+// Buy places a GTC limit buy of quantity stockType shares at price
+func (c *Client) Buy(stockSymbol, stockType string, price types.Amount, quantity types.Shares) (PlacedOrder, error) {
+	var order PlacedOrder
+	err := c.post("/order/buy", types.OrderProps{
+		UserId:      c.UserId,
+		StockSymbol: stockSymbol,
+		StockType:   stockType,
+		Price:       price,
+		Quantity:    quantity,
+		Kind:        types.LimitOrder,
+		TimeInForce: types.GoodTillCancelled,
+	}, &order)
+	return order, err
+}
+
+// Cancel cancels a resting order
+func (c *Client) Cancel(orderId string) error {
+	return c.post("/order/cancel", types.CancelOrderProps{UserId: c.UserId, OrderId: orderId}, nil)
+}
+
+// Merge turns quantity YES+NO pairs of a symbol back into USD
+func (c *Client) Merge(stockSymbol string, quantity types.Shares) error {
+	return c.post("/order/merge", types.CompleteSetProps{UserId: c.UserId, StockSymbol: stockSymbol, Quantity: quantity}, nil)
+}
+
+func (c *Client) get(path string, out interface{}) error {
+	resp, err := c.http.Get(c.ServerURL + path)
+	if err != nil {
+		return err
+	}
+	return decode(path, resp, out)
+}
+
+func (c *Client) post(path string, body interface{}, out interface{}) error {
+	data, _ := json.Marshal(body)
+	resp, err := c.http.Post(c.ServerURL+path, "application/json", bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	return decode(path, resp, out)
+}
+
+// decode reads a response into out, failing on an error status or an
+// "error" the engine sent back with a 200
+func decode(path string, resp *http.Response, out interface{}) error {
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var failed struct {
+		Error string `json:"error"`
+	}
+	json.Unmarshal(body, &failed)
+	if failed.Error != "" {
+		return fmt.Errorf("%s: %s", path, failed.Error)
+	}
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("%s failed with status %d: %s", path, resp.StatusCode, body)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("%s: invalid response: %v", path, err)
+	}
+	return nil
+}
+
+// SubscribeTrades subscribes to the trades of symbols on the WebSocket server
+// and sends each one to trades until the connection drops
+func SubscribeTrades(socketURL string, symbols []string, trades chan<- types.Transection) error {
+	conn, _, err := websocket.DefaultDialer.Dial(socketURL, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	for _, symbol := range symbols {
+		sub, _ := json.Marshal(map[string]string{"type": "subscribe", "symbol": types.TradesChannel(symbol)})
+		if err := conn.WriteMessage(websocket.TextMessage, sub); err != nil {
+			return err
+		}
+	}
+
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		// Anything that isn't a trade event, like the subscription acks, is skipped
+		var event struct {
+			Event   string                `json:"event"`
+			Message types.IncomingMessage `json:"message"`
+		}
+		if json.Unmarshal(msg, &event) != nil || event.Event != "event_trade" {
+			continue
+		}
+		var trade types.Transection
+		if err := json.Unmarshal(event.Message.Data, &trade); err != nil {
+			continue
+		}
+		trades <- trade
+	}
+}
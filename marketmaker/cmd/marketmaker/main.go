@@ -0,0 +1,279 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/adityadeshlahre/probo-v1/marketmaker/client"
+	"github.com/adityadeshlahre/probo-v1/marketmaker/config"
+	"github.com/adityadeshlahre/probo-v1/marketmaker/strategy"
+	types "github.com/adityadeshlahre/probo-v1/shared/types"
+)
+
+// market is what the bot keeps about a market it quotes
+type market struct {
+	strategy config.Strategy
+	fair     types.Amount
+	resting  []restingQuote // its quotes in the book
+}
+
+// restingQuote is a quote in the book, with the quantity it has left to fill
+// and the maker fee it has reserved for that
+type restingQuote struct {
+	orderId string
+	quote   strategy.Quote
+	fee     types.Amount
+}
+
+var (
+	cfg     config.Config
+	api     *client.Client
+	markets = map[string]*market{}
+	killed  bool
+)
+
+func main() {
+	configPath := flag.String("config", "config.json", "path to the market maker's config file")
+	flag.Parse()
+
+	var err error
+	cfg, err = config.Load(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	symbols := make([]string, 0, len(cfg.Markets))
+	for symbol := range cfg.Markets {
+		s, _ := cfg.Strategy(symbol)
+		markets[symbol] = &market{strategy: s, fair: s.FairValue}
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	api = client.New(cfg.ServerURL, cfg.UserId)
+	if err := api.EnsureUser(); err != nil {
+		log.Fatalf("Failed to set up user %s: %v", cfg.UserId, err)
+	}
+	fmt.Printf("Market maker %s quoting %v\n", cfg.UserId, symbols)
+
+	// Trades come in from the socket server, reconnecting whenever it drops
+	trades := make(chan types.Transection, 256)
+	go func() {
+		for {
+			err := client.SubscribeTrades(cfg.SocketURL, symbols, trades)
+			fmt.Printf("Trade subscription dropped: %v, reconnecting\n", err)
+			time.Sleep(2 * time.Second)
+		}
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	ticker := time.NewTicker(time.Duration(cfg.RefreshMs) * time.Millisecond)
+	defer ticker.Stop()
+
+	// Everything happens on this loop, so quotes are never placed or
+	// cancelled by two things at once
+	for _, symbol := range symbols {
+		requote(symbol)
+	}
+	for {
+		select {
+		case trade := <-trades:
+			// Trades that come in while requoting are handled together
+			changed := map[string]bool{}
+			onTrade(trade, changed)
+			for drained := false; !drained; {
+				select {
+				case trade := <-trades:
+					onTrade(trade, changed)
+				default:
+					drained = true
+				}
+			}
+			for _, symbol := range symbols {
+				if changed[symbol] {
+					requote(symbol)
+				}
+			}
+
+		case <-ticker.C:
+			for _, symbol := range symbols {
+				requote(symbol)
+			}
+
+		case <-stop:
+			fmt.Println("Shutting down, cancelling quotes")
+			for _, symbol := range symbols {
+				cancelQuotes(symbol)
+			}
+			return
+		}
+	}
+}
+
+// onTrade moves a market's fair value toward a trade's price and marks the
+// market to be requoted. Trades the bot took itself came from its own quotes
+// being placed, so they're left out.
+func onTrade(trade types.Transection, changed map[string]bool) {
+	m, exists := markets[trade.Symbol]
+	if !exists {
+		return
+	}
+	if trade.MakerId == cfg.UserId {
+		filled(m, trade.MakerOrderId, trade.Quantity)
+	}
+	if trade.TakerId == cfg.UserId {
+		return
+	}
+	m.fair = strategy.Follow(m.strategy, m.fair, strategy.YesPrice(m.strategy, trade.SymbolStockType, trade.Price))
+	changed[trade.Symbol] = true
+}
+
+// killSwitchOn reports whether the kill switch file exists, cancelling every
+// quote the moment it appears
+func killSwitchOn() bool {
+	if cfg.KillSwitch == "" {
+		return false
+	}
+	_, err := os.Stat(cfg.KillSwitch)
+	on := err == nil
+	if on && !killed {
+		fmt.Printf("Kill switch %s is on, cancelling every quote\n", cfg.KillSwitch)
+		for symbol := range markets {
+			cancelQuotes(symbol)
+		}
+	} else if !on && killed {
+		fmt.Printf("Kill switch %s is off, quoting again\n", cfg.KillSwitch)
+	}
+	killed = on
+	return on
+}
+
+// filled takes a fill of one of a market's quotes off what it has left,
+// dropping it once it's filled
+func filled(m *market, orderId string, quantity types.Shares) {
+	for i, resting := range m.resting {
+		if resting.orderId != orderId {
+			continue
+		}
+		if resting.quote.Quantity <= quantity {
+			m.resting = slices.Delete(m.resting, i, i+1)
+		} else {
+			m.resting[i].fee -= types.ReservedFeeShare(resting.fee, quantity, resting.quote.Quantity)
+			m.resting[i].quote.Quantity -= quantity
+		}
+		return
+	}
+}
+
+// requote moves a market's quotes to the ones for its fair value and the
+// bot's position. Quotes whose price and size haven't moved stay in the book
+// and keep their place in the queue.
+func requote(symbol string) {
+	m := markets[symbol]
+	if killSwitchOn() || m.strategy.Disabled {
+		cancelQuotes(symbol)
+		return
+	}
+
+	stocks, err := api.Stocks()
+	if err != nil {
+		fmt.Printf("requote %s: %v\n", symbol, err)
+		return
+	}
+	position := stocks[symbol]
+
+	// Pairs from fills on both sides are worth the payout, so they go back to USD
+	if pairs := strategy.Pairs(position); pairs > 0 {
+		if err := api.Merge(symbol, pairs); err != nil {
+			fmt.Printf("requote %s: merging %d pairs: %v\n", symbol, pairs, err)
+		} else {
+			position.Yes.Quantity -= pairs
+			position.No.Quantity -= pairs
+		}
+	}
+
+	balance, err := api.Balance()
+	if err != nil {
+		fmt.Printf("requote %s: %v\n", symbol, err)
+		return
+	}
+	// The bot only locks USD in its quotes, so if that isn't what it thinks
+	// is resting it missed fills, and every quote starts over
+	var locked types.Amount
+	for _, other := range markets {
+		locked += lockedIn(other)
+	}
+	if locked != balance.Locked {
+		fmt.Printf("requote %s: %s locked but quotes hold %s, replacing every quote\n", symbol, balance.Locked, locked)
+		for other := range markets {
+			cancelQuotes(other)
+		}
+		if balance, err = api.Balance(); err != nil {
+			fmt.Printf("requote %s: %v\n", symbol, err)
+			return
+		}
+	}
+
+	// What this market's quotes hold can go back into them
+	wanted := strategy.Quotes(m.strategy, m.fair, position, balance.Balance+lockedIn(m))
+	resting := make([]strategy.Quote, len(m.resting))
+	for i, r := range m.resting {
+		resting[i] = r.quote
+	}
+	cancel, place := strategy.Changes(resting, wanted)
+
+	for _, i := range cancel {
+		if err := api.Cancel(m.resting[i].orderId); err != nil {
+			fmt.Printf("cancel %s: %v\n", m.resting[i].orderId, err)
+		}
+	}
+	kept := m.resting[:0]
+	for i, r := range m.resting {
+		if !slices.Contains(cancel, i) {
+			kept = append(kept, r)
+		}
+	}
+	m.resting = kept
+
+	for _, quote := range place {
+		order, err := api.Buy(symbol, quote.StockType, quote.Price, quote.Quantity)
+		if err != nil {
+			fmt.Printf("requote %s: placing %d %s at %s: %v\n", symbol, quote.Quantity, quote.StockType, quote.Price, err)
+			continue
+		}
+		if order.OrderStatus == types.PENDING || order.OrderStatus == types.PARTIALLY_FILLED {
+			quote.Quantity -= order.FilledQty
+			m.resting = append(m.resting, restingQuote{orderId: order.OrderId, quote: quote, fee: order.ReservedFee})
+		}
+	}
+	fmt.Printf("Quoted %s around %s with %d yes net, %d orders resting, %d moved\n", symbol, m.fair, strategy.Net(position), len(m.resting), len(place))
+}
+
+// lockedIn is the USD a market's quotes hold in the book, with the maker fees
+// they reserved
+func lockedIn(m *market) types.Amount {
+	var locked types.Amount
+	for _, r := range m.resting {
+		locked += r.quote.Price.Times(r.quote.Quantity) + r.fee
+	}
+	return locked
+}
+
+// cancelQuotes cancels a market's resting quotes. Ones that filled in the
+// meantime fail to cancel, which is fine.
+func cancelQuotes(symbol string) {
+	m := markets[symbol]
+	for _, r := range m.resting {
+		if err := api.Cancel(r.orderId); err != nil {
+			fmt.Printf("cancel %s: %v\n", r.orderId, err)
+		}
+	}
+	m.resting = nil
+}
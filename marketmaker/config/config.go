@@ -0,0 +1,147 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	types "github.com/adityadeshlahre/probo-v1/shared/types"
+)
+
+// Config is the market maker's setup, read from a JSON file
+type Config struct {
+	ServerURL string `json:"serverUrl"` // the public API, http://localhost:8080 by default
+	SocketURL string `json:"socketUrl"` // the WebSocket server, ws://localhost:8081/ws by default
+	UserId    string `json:"userId"`    // the account it quotes from, marketmaker-bot by default
+
+	// KillSwitch is a file that stops all quoting while it exists: every
+	// quote is cancelled and nothing is placed until it's removed
+	KillSwitch string `json:"killSwitch"`
+	// RefreshMs requotes every market this often even without trades, 5s by default
+	RefreshMs int64 `json:"refreshMs"`
+
+	Defaults Strategy            `json:"defaults"` // used for whatever a market doesn't set
+	Markets  map[string]Strategy `json:"markets"`  // by symbol
+}
+
+// Strategy is how one market is quoted. Prices are of YES shares.
+type Strategy struct {
+	FairValue    types.Amount `json:"fairValue"`    // where quoting starts, payout / 2 by default
+	FollowTrades float64      `json:"followTrades"` // how far each trade moves the fair value toward its price, 0 to 1
+	Spread       types.Amount `json:"spread"`       // between the best bid and ask
+	Levels       int          `json:"levels"`       // quotes on each side
+	LevelStep    types.Amount `json:"levelStep"`    // between a side's levels
+	Size         types.Shares `json:"size"`         // shares per quote
+	Skew         types.Amount `json:"skew"`         // how far quotes move down per YES share held net of NO shares
+	Payout       types.Amount `json:"payout"`       // the market's payout, 100.00 by default
+	TickSize     types.Amount `json:"tickSize"`     // the market's tick, 0.01 by default
+
+	// Risk limits
+	MaxPosition types.Shares `json:"maxPosition"` // most YES (or NO) shares held net of the other side
+	MaxCapital  types.Amount `json:"maxCapital"`  // most USD in positions and quotes together
+	Disabled    bool         `json:"disabled"`    // stop quoting this market
+}
+
+// Load reads a config file and fills in the defaults
+func Load(path string) (Config, error) {
+	var config Config
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("invalid config %s: %v", path, err)
+	}
+	if config.ServerURL == "" {
+		config.ServerURL = "http://localhost:8080"
+	}
+	if config.SocketURL == "" {
+		config.SocketURL = "ws://localhost:8081/ws"
+	}
+	if config.UserId == "" {
+		config.UserId = "marketmaker-bot"
+	}
+	if config.RefreshMs == 0 {
+		config.RefreshMs = 5000
+	}
+	if len(config.Markets) == 0 {
+		return config, fmt.Errorf("invalid config %s: no markets to quote", path)
+	}
+	for symbol := range config.Markets {
+		if _, err := config.Strategy(symbol); err != nil {
+			return config, fmt.Errorf("invalid config %s: %v", path, err)
+		}
+	}
+	return config, nil
+}
+
+// Strategy returns a market's strategy with the defaults filled in
+func (c Config) Strategy(symbol string) (Strategy, error) {
+	s := c.Markets[symbol]
+	d := c.Defaults
+	if s.Payout == 0 {
+		s.Payout = d.Payout
+	}
+	if s.Payout == 0 {
+		s.Payout = types.MaxPrice
+	}
+	if s.TickSize == 0 {
+		s.TickSize = d.TickSize
+	}
+	if s.TickSize == 0 {
+		s.TickSize = types.Cent
+	}
+	if s.FairValue == 0 {
+		s.FairValue = d.FairValue
+	}
+	if s.FairValue == 0 {
+		s.FairValue = s.Payout / 2
+	}
+	if s.FollowTrades == 0 {
+		s.FollowTrades = d.FollowTrades
+	}
+	if s.Spread == 0 {
+		s.Spread = d.Spread
+	}
+	if s.Levels == 0 {
+		s.Levels = d.Levels
+	}
+	if s.Levels == 0 {
+		s.Levels = 1
+	}
+	if s.LevelStep == 0 {
+		s.LevelStep = d.LevelStep
+	}
+	if s.LevelStep == 0 {
+		s.LevelStep = s.TickSize
+	}
+	if s.Size == 0 {
+		s.Size = d.Size
+	}
+	if s.Skew == 0 {
+		s.Skew = d.Skew
+	}
+	if s.MaxPosition == 0 {
+		s.MaxPosition = d.MaxPosition
+	}
+	if s.MaxCapital == 0 {
+		s.MaxCapital = d.MaxCapital
+	}
+	s.Disabled = s.Disabled || d.Disabled
+
+	switch {
+	case s.FairValue <= 0 || s.FairValue >= s.Payout:
+		return s, fmt.Errorf("%s: fairValue has to be between 0 and the payout", symbol)
+	case s.FollowTrades < 0 || s.FollowTrades > 1:
+		return s, fmt.Errorf("%s: followTrades has to be between 0 and 1", symbol)
+	case s.Spread <= 0:
+		return s, fmt.Errorf("%s: spread has to be greater than 0", symbol)
+	case s.Size <= 0:
+		return s, fmt.Errorf("%s: size has to be greater than 0", symbol)
+	case s.Levels < 0 || s.LevelStep < 0 || s.Skew < 0:
+		return s, fmt.Errorf("%s: levels, levelStep and skew can't be negative", symbol)
+	case s.MaxPosition <= 0 || s.MaxCapital <= 0:
+		return s, fmt.Errorf("%s: maxPosition and maxCapital are required", symbol)
+	}
+	return s, nil
+}
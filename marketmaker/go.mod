@@ -0,0 +1,9 @@
+module github.com/adityadeshlahre/probo-v1/marketmaker
+
+go 1.25.1
+
+require github.com/adityadeshlahre/probo-v1/shared v0.0.0
+
+require github.com/gorilla/websocket v1.5.3
+
+replace github.com/adityadeshlahre/probo-v1/shared => ../shared
@@ -0,0 +1,138 @@
+package strategy
+
+import (
+	"math"
+
+	"github.com/adityadeshlahre/probo-v1/marketmaker/config"
+	types "github.com/adityadeshlahre/probo-v1/shared/types"
+)
+
+// The market maker quotes YES around a fair value. A bid is a buy of YES at
+// the bid, an ask a buy of NO at payout - ask, which rests in the book as a
+// YES sell at the ask, so quoting never needs shares to be held first.
+// Quotes move down by the skew for every YES share held net of NO shares (up
+// for NO), so fills on one side make the other side's quotes more attractive
+// and the position works its way back to flat.
+
+// Quote is one order to place
+type Quote struct {
+	StockType string       // "yes" for a bid, "no" for an ask
+	Price     types.Amount // of the stock bought
+	Quantity  types.Shares
+}
+
+// Net is how many YES shares a position holds net of NO shares
+func Net(position types.SymbolStockBalance) types.Shares {
+	return position.Yes.Quantity + position.Yes.Locked - position.No.Quantity - position.No.Locked
+}
+
+// Pairs is how many YES+NO pairs a position holds that can be merged into USD
+func Pairs(position types.SymbolStockBalance) types.Shares {
+	return min(position.Yes.Quantity, position.No.Quantity)
+}
+
+// Quotes works out the orders to rest in a market: up to s.Levels bids and
+// asks around fair, skewed by the position, with nothing on a side that
+// would take the position past s.MaxPosition and no more in positions and
+// quotes together than s.MaxCapital or the USD available
+func Quotes(s config.Strategy, fair types.Amount, position types.SymbolStockBalance, available types.Amount) []Quote {
+	net := Net(position)
+	center := clamp(fair-s.Skew.Times(net), s)
+
+	// Whatever isn't in the position yet can go in quotes
+	budget := min(s.MaxCapital-max(position.Yes.Cost+position.No.Cost, 0), available)
+
+	var quotes []Quote
+	bid := floorTick(center-s.Spread/2, s.TickSize)
+	ask := ceilTick(center+s.Spread-s.Spread/2, s.TickSize)
+	if ask <= bid {
+		ask = bid + s.TickSize
+	}
+	bidRoom, askRoom := s.MaxPosition-net, s.MaxPosition+net
+	for level := 0; level < s.Levels; level++ {
+		step := s.LevelStep.Times(types.Shares(level))
+
+		// Bids and asks alternate, so running out of budget leaves both sides quoted
+		if price := bid - step; price >= s.TickSize && bidRoom > 0 {
+			quantity := min(s.Size, bidRoom, types.Shares(budget/price))
+			if quantity > 0 {
+				quotes = append(quotes, Quote{StockType: "yes", Price: price, Quantity: quantity})
+				bidRoom -= quantity
+				budget -= price.Times(quantity)
+			}
+		}
+		if price := ask + step; price <= s.Payout-s.TickSize && askRoom > 0 {
+			noPrice := s.Payout - price
+			quantity := min(s.Size, askRoom, types.Shares(budget/noPrice))
+			if quantity > 0 {
+				quotes = append(quotes, Quote{StockType: "no", Price: noPrice, Quantity: quantity})
+				askRoom -= quantity
+				budget -= noPrice.Times(quantity)
+			}
+		}
+	}
+	return quotes
+}
+
+// Changes compares the quotes resting in a market with the ones it should
+// have. It returns the indexes of the resting quotes to cancel and the quotes
+// to place, leaving the ones whose price and size haven't moved in the book.
+func Changes(resting, wanted []Quote) ([]int, []Quote) {
+	kept := make([]bool, len(wanted))
+	var cancel []int
+	for i, quote := range resting {
+		match := -1
+		for j, w := range wanted {
+			if !kept[j] && w == quote {
+				match = j
+				break
+			}
+		}
+		if match < 0 {
+			cancel = append(cancel, i)
+			continue
+		}
+		kept[match] = true
+	}
+	var place []Quote
+	for j, quote := range wanted {
+		if !kept[j] {
+			place = append(place, quote)
+		}
+	}
+	return cancel, place
+}
+
+// Follow moves the fair value toward the YES price of a trade by
+// s.FollowTrades of the distance
+func Follow(s config.Strategy, fair types.Amount, price types.Amount) types.Amount {
+	if s.FollowTrades == 0 {
+		return fair
+	}
+	moved := fair + types.Amount(math.Round(s.FollowTrades*float64(price-fair)))
+	return clamp(moved, s)
+}
+
+// YesPrice is the YES price of a trade in stockType shares
+func YesPrice(s config.Strategy, stockType string, price types.Amount) types.Amount {
+	if stockType == "no" {
+		return s.Payout - price
+	}
+	return price
+}
+
+// clamp keeps a price inside the range a market trades in
+func clamp(price types.Amount, s config.Strategy) types.Amount {
+	return max(s.TickSize, min(price, s.Payout-s.TickSize))
+}
+
+func floorTick(price, tick types.Amount) types.Amount {
+	if price < 0 {
+		return 0
+	}
+	return price / tick * tick
+}
+
+func ceilTick(price, tick types.Amount) types.Amount {
+	return (price + tick - 1) / tick * tick
+}
@@ -0,0 +1,235 @@
+package strategy
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/adityadeshlahre/probo-v1/marketmaker/config"
+	types "github.com/adityadeshlahre/probo-v1/shared/types"
+)
+
+// base quotes 3 levels of 10 shares a side, 2.00 wide around 50.00
+var base = config.Strategy{
+	FairValue:   50 * types.USD,
+	Spread:      2 * types.USD,
+	Levels:      3,
+	LevelStep:   1 * types.USD,
+	Size:        10,
+	Skew:        10 * types.Cent,
+	Payout:      types.MaxPrice,
+	TickSize:    types.Cent,
+	MaxPosition: 100,
+	MaxCapital:  10000 * types.USD,
+}
+
+func bid(price types.Amount, quantity types.Shares) Quote {
+	return Quote{StockType: "yes", Price: price, Quantity: quantity}
+}
+
+// ask is a YES sell at price, quoted as a buy of NO at the payout less price
+func ask(price types.Amount, quantity types.Shares) Quote {
+	return Quote{StockType: "no", Price: types.MaxPrice - price, Quantity: quantity}
+}
+
+func holding(yes, no types.Shares) types.SymbolStockBalance {
+	return types.SymbolStockBalance{Yes: types.StockPosition{Quantity: yes}, No: types.StockPosition{Quantity: no}}
+}
+
+func TestQuotes(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		strategy  func(s *config.Strategy)
+		fair      types.Amount
+		position  types.SymbolStockBalance
+		available types.Amount
+		want      []Quote
+	}{
+		{
+			name:      "levels either side of the spread",
+			fair:      50 * types.USD,
+			available: 10000 * types.USD,
+			want: []Quote{
+				bid(49*types.USD, 10), ask(51*types.USD, 10),
+				bid(48*types.USD, 10), ask(52*types.USD, 10),
+				bid(47*types.USD, 10), ask(53*types.USD, 10),
+			},
+		},
+		{
+			name:      "long YES skews quotes down",
+			fair:      50 * types.USD,
+			position:  holding(20, 0),
+			available: 10000 * types.USD,
+			want: []Quote{
+				bid(47*types.USD, 10), ask(49*types.USD, 10),
+				bid(46*types.USD, 10), ask(50*types.USD, 10),
+				bid(45*types.USD, 10), ask(51*types.USD, 10),
+			},
+		},
+		{
+			name:      "long NO skews quotes up",
+			fair:      50 * types.USD,
+			position:  holding(0, 20),
+			available: 10000 * types.USD,
+			want: []Quote{
+				bid(51*types.USD, 10), ask(53*types.USD, 10),
+				bid(50*types.USD, 10), ask(54*types.USD, 10),
+				bid(49*types.USD, 10), ask(55*types.USD, 10),
+			},
+		},
+		{
+			name:      "bids stop at MaxPosition",
+			strategy:  func(s *config.Strategy) { s.Skew = 0 },
+			fair:      50 * types.USD,
+			position:  holding(95, 0),
+			available: 10000 * types.USD,
+			want: []Quote{
+				bid(49*types.USD, 5), ask(51*types.USD, 10),
+				ask(52*types.USD, 10),
+				ask(53*types.USD, 10),
+			},
+		},
+		{
+			name:      "no asks at MaxPosition short",
+			strategy:  func(s *config.Strategy) { s.Skew = 0 },
+			fair:      50 * types.USD,
+			position:  holding(0, 100),
+			available: 10000 * types.USD,
+			want: []Quote{
+				bid(49*types.USD, 10),
+				bid(48*types.USD, 10),
+				bid(47*types.USD, 10),
+			},
+		},
+		{
+			name:      "MaxCapital leaves both sides quoted",
+			strategy:  func(s *config.Strategy) { s.MaxCapital = 1000 * types.USD },
+			fair:      50 * types.USD,
+			available: 10000 * types.USD,
+			want:      []Quote{bid(49*types.USD, 10), ask(51*types.USD, 10)},
+		},
+		{
+			name:     "MaxCapital counts what's in the position",
+			strategy: func(s *config.Strategy) { s.MaxCapital = 1000 * types.USD },
+			fair:     50 * types.USD,
+			position: types.SymbolStockBalance{
+				Yes: types.StockPosition{Quantity: 10, Cost: 495 * types.USD},
+				No:  types.StockPosition{Quantity: 10, Cost: 495 * types.USD},
+			},
+			available: 10000 * types.USD,
+			want:      nil,
+		},
+		{
+			name:      "no more than the USD available",
+			fair:      50 * types.USD,
+			available: 100 * types.USD,
+			want:      []Quote{bid(49*types.USD, 2)},
+		},
+		{
+			name: "prices on the tick",
+			strategy: func(s *config.Strategy) {
+				s.TickSize = 5 * types.Cent
+				s.Spread = 1 * types.USD
+				s.Levels = 1
+			},
+			fair:      5002 * types.Cent,
+			available: 10000 * types.USD,
+			want:      []Quote{bid(4950*types.Cent, 10), ask(5055*types.Cent, 10)},
+		},
+		{
+			name:      "a tick apart without a spread",
+			strategy:  func(s *config.Strategy) { s.Spread = 0; s.Levels = 1 },
+			fair:      50 * types.USD,
+			available: 10000 * types.USD,
+			want:      []Quote{bid(50*types.USD, 10), ask(5001*types.Cent, 10)},
+		},
+		{
+			name:      "no bids below the lowest tick",
+			fair:      50 * types.Cent,
+			available: 10000 * types.USD,
+			want: []Quote{
+				ask(150*types.Cent, 10),
+				ask(250*types.Cent, 10),
+				ask(350*types.Cent, 10),
+			},
+		},
+		{
+			name:      "no asks above the highest tick",
+			fair:      9990 * types.Cent,
+			available: 10000 * types.USD,
+			want: []Quote{
+				bid(9890*types.Cent, 10),
+				bid(9790*types.Cent, 10),
+				bid(9690*types.Cent, 10),
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			s := base
+			if test.strategy != nil {
+				test.strategy(&s)
+			}
+			got := Quotes(s, test.fair, test.position, test.available)
+			if !slices.Equal(got, test.want) {
+				t.Errorf("quotes are\n%v\nwant\n%v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestChanges(t *testing.T) {
+	resting := []Quote{
+		bid(49*types.USD, 10), ask(51*types.USD, 10),
+		bid(48*types.USD, 4), ask(52*types.USD, 10),
+	}
+	for _, test := range []struct {
+		name       string
+		wanted     []Quote
+		wantCancel []int
+		wantPlace  []Quote
+	}{
+		{
+			name:   "nothing moved",
+			wanted: resting,
+		},
+		{
+			name: "a partly filled level is topped back up",
+			wanted: []Quote{
+				bid(49*types.USD, 10), ask(51*types.USD, 10),
+				bid(48*types.USD, 10), ask(52*types.USD, 10),
+			},
+			wantCancel: []int{2},
+			wantPlace:  []Quote{bid(48*types.USD, 10)},
+		},
+		{
+			name: "the bids moved down a level",
+			wanted: []Quote{
+				bid(48*types.USD, 4), ask(51*types.USD, 10),
+				bid(47*types.USD, 10), ask(52*types.USD, 10),
+			},
+			wantCancel: []int{0},
+			wantPlace:  []Quote{bid(47*types.USD, 10)},
+		},
+		{
+			name:       "quoting stopped",
+			wanted:     nil,
+			wantCancel: []int{0, 1, 2, 3},
+		},
+		{
+			name:   "the same quote twice is placed twice",
+			wanted: append(slices.Clone(resting), ask(51*types.USD, 10)),
+			wantPlace: []Quote{
+				ask(51*types.USD, 10),
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			cancel, place := Changes(resting, test.wanted)
+			if !slices.Equal(cancel, test.wantCancel) {
+				t.Errorf("cancels %v, want %v", cancel, test.wantCancel)
+			}
+			if !slices.Equal(place, test.wantPlace) {
+				t.Errorf("places %v, want %v", place, test.wantPlace)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// The resolution admins prove who they are with a key of their own, sent as
+// "Authorization: Bearer <key>". The server only passes on the id a valid key
+// belongs to, so nobody can vote, or propose an outcome while a market is
+// trading, by naming an admin in the request body.
+
+// keys maps each admin's key to their user id
+var keys = map[string]string{}
+
+// Load reads the admins' keys from RESOLUTION_ADMIN_KEYS, comma separated
+// "<adminId>:<key>" pairs, using the same ids as the engine's
+// RESOLUTION_ADMINS. Without it no request is an admin's.
+func Load() error {
+	keys = map[string]string{}
+	for _, pair := range strings.Split(os.Getenv("RESOLUTION_ADMIN_KEYS"), ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		adminId, key, found := strings.Cut(pair, ":")
+		adminId, key = strings.TrimSpace(adminId), strings.TrimSpace(key)
+		if !found || adminId == "" || key == "" {
+			return fmt.Errorf("invalid RESOLUTION_ADMIN_KEYS entry %q, expected <adminId>:<key>", pair)
+		}
+		if _, exists := keys[key]; exists {
+			return fmt.Errorf("invalid RESOLUTION_ADMIN_KEYS, two admins share a key")
+		}
+		keys[key] = adminId
+	}
+	if len(keys) == 0 {
+		fmt.Println("RESOLUTION_ADMIN_KEYS isn't set, nobody can vote on disputed markets")
+	}
+	return nil
+}
+
+// Authenticate returns the id of the admin whose key an Authorization header
+// carries
+func Authenticate(authorization string) (string, bool) {
+	key, found := strings.CutPrefix(authorization, "Bearer ")
+	if !found || key == "" {
+		return "", false
+	}
+	for adminKey, adminId := range keys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(adminKey)) == 1 {
+			return adminId, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,33 @@
+package admin
+
+import "testing"
+
+func TestAuthenticate(t *testing.T) {
+	t.Setenv("RESOLUTION_ADMIN_KEYS", "alice:k1, bob:k2")
+	if err := Load(); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		header string
+		want   string
+		ok     bool
+	}{
+		{"Bearer k1", "alice", true},
+		{"Bearer k2", "bob", true},
+		{"Bearer k3", "", false},
+		{"Bearer ", "", false},
+		{"k1", "", false},
+		{"", "", false},
+	} {
+		if got, ok := Authenticate(test.header); got != test.want || ok != test.ok {
+			t.Errorf("%q authenticates %q %v, want %q %v", test.header, got, ok, test.want, test.ok)
+		}
+	}
+
+	for _, invalid := range []string{"alice", "alice:", ":k1", "alice:k1,bob:k1"} {
+		t.Setenv("RESOLUTION_ADMIN_KEYS", invalid)
+		if err := Load(); err == nil {
+			t.Errorf("RESOLUTION_ADMIN_KEYS %q loaded", invalid)
+		}
+	}
+}
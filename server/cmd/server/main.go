@@ -5,11 +5,20 @@ import (
 	"encoding/json"
 	"log"
 
+	"github.com/adityadeshlahre/probo-v1/server/admin"
+	"github.com/adityadeshlahre/probo-v1/server/oracle"
+	"github.com/adityadeshlahre/probo-v1/server/resolution"
+	"github.com/adityadeshlahre/probo-v1/server/routes/handler/amm"
 	"github.com/adityadeshlahre/probo-v1/server/routes/handler/balance"
 	"github.com/adityadeshlahre/probo-v1/server/routes/handler/book"
+	"github.com/adityadeshlahre/probo-v1/server/routes/handler/fees"
+	"github.com/adityadeshlahre/probo-v1/server/routes/handler/lp"
+	oracleRoutes "github.com/adityadeshlahre/probo-v1/server/routes/handler/oracle"
 	"github.com/adityadeshlahre/probo-v1/server/routes/handler/order"
+	"github.com/adityadeshlahre/probo-v1/server/routes/handler/schedule"
 	"github.com/adityadeshlahre/probo-v1/server/routes/handler/symbol"
 	"github.com/adityadeshlahre/probo-v1/server/routes/handler/user"
+	"github.com/adityadeshlahre/probo-v1/server/scheduler"
 	"github.com/adityadeshlahre/probo-v1/server/server"
 	sharedRedis "github.com/adityadeshlahre/probo-v1/shared/redis"
 	types "github.com/adityadeshlahre/probo-v1/shared/types"
@@ -30,6 +39,13 @@ func main() {
 	sharedRedis.InitRedis()
 	serverToEngineQueueClient = sharedRedis.GetRedisClient()
 	serverResponseQueueClient = sharedRedis.GetRedisClient()
+	if err := oracle.Init(serverToEngineQueueClient); err != nil {
+		log.Fatal("Error loading oracles: ", err)
+	}
+	resolution.SetClient(serverToEngineQueueClient)
+	if err := admin.Load(); err != nil {
+		log.Fatal("Error loading admin keys: ", err)
+	}
 
 	go func() {
 		for {
@@ -64,7 +80,7 @@ func main() {
 							}
 						}
 					}
-				case types.BUY_ORDER, types.SELL_ORDER:
+				case types.BUY_ORDER, types.SELL_ORDER, types.SPLIT_SET, types.MERGE_SET:
 					var data map[string]interface{}
 					if err := json.Unmarshal(resp.Data, &data); err == nil {
 						if userId, ok := data["userId"].(string); ok {
@@ -74,6 +90,80 @@ func main() {
 							}
 						}
 					}
+				case types.CANCEL_ORDER:
+					var data map[string]interface{}
+					if err := json.Unmarshal(resp.Data, &data); err == nil {
+						if orderId, ok := data["orderId"].(string); ok {
+							if ch, ok := sharedRedis.ServerAwaitsForResponseMap[orderId]; ok {
+								ch <- message
+								delete(sharedRedis.ServerAwaitsForResponseMap, orderId)
+							}
+						}
+					}
+				case types.PROPOSE_RESOLUTION, types.DISPUTE_RESOLUTION, types.VOTE_RESOLUTION:
+					var data map[string]interface{}
+					if err := json.Unmarshal(resp.Data, &data); err == nil {
+						symbol, _ := data["stockSymbol"].(string)
+						userId, _ := data["userId"].(string)
+						chKey := "resolution_" + symbol + "_" + userId
+						if ch, ok := sharedRedis.ServerAwaitsForResponseMap[chKey]; ok {
+							ch <- message
+							delete(sharedRedis.ServerAwaitsForResponseMap, chKey)
+						}
+					}
+				case types.END_MARKET:
+					var data map[string]interface{}
+					if err := json.Unmarshal(resp.Data, &data); err == nil {
+						if symbol, ok := data["stockSymbol"].(string); ok {
+							chKey := "end_market_" + symbol
+							if ch, ok := sharedRedis.ServerAwaitsForResponseMap[chKey]; ok {
+								ch <- message
+								delete(sharedRedis.ServerAwaitsForResponseMap, chKey)
+							}
+						}
+					}
+				case types.AMM_QUOTE:
+					var data map[string]interface{}
+					if err := json.Unmarshal(resp.Data, &data); err == nil {
+						if symbol, ok := data["stockSymbol"].(string); ok {
+							chKey := "amm_quote_" + symbol
+							if ch, ok := sharedRedis.ServerAwaitsForResponseMap[chKey]; ok {
+								ch <- message
+								delete(sharedRedis.ServerAwaitsForResponseMap, chKey)
+							}
+						}
+					}
+				case types.FEE_REPORT:
+					var data map[string]interface{}
+					if err := json.Unmarshal(resp.Data, &data); err == nil {
+						date, _ := data["requestedDate"].(string)
+						chKey := "fee_report_" + date
+						if ch, ok := sharedRedis.ServerAwaitsForResponseMap[chKey]; ok {
+							ch <- message
+							delete(sharedRedis.ServerAwaitsForResponseMap, chKey)
+						}
+					}
+				case types.LP_REPORT:
+					var data map[string]interface{}
+					if err := json.Unmarshal(resp.Data, &data); err == nil {
+						symbol, _ := data["stockSymbol"].(string)
+						chKey := "lp_report_" + symbol
+						if ch, ok := sharedRedis.ServerAwaitsForResponseMap[chKey]; ok {
+							ch <- message
+							delete(sharedRedis.ServerAwaitsForResponseMap, chKey)
+						}
+					}
+				case types.MARKET_STATUS:
+					var data map[string]interface{}
+					if err := json.Unmarshal(resp.Data, &data); err == nil {
+						if symbol, ok := data["stockSymbol"].(string); ok {
+							chKey := "market_status_" + symbol
+							if ch, ok := sharedRedis.ServerAwaitsForResponseMap[chKey]; ok {
+								ch <- message
+								delete(sharedRedis.ServerAwaitsForResponseMap, chKey)
+							}
+						}
+					}
 				case types.GET_ORDER_BOOK:
 					var data map[string]interface{}
 					if err := json.Unmarshal(resp.Data, &data); err == nil {
@@ -117,11 +207,21 @@ func main() {
 		}
 	}()
 
+	// Resume the recurring markets saved before the last restart
+	if err := scheduler.Start(serverToEngineQueueClient); err != nil {
+		log.Fatal("Error loading the schedule: ", err)
+	}
+
 	e := server.NewServer()
 	user.InitUserRoute(e, serverToEngineQueueClient)
 	balance.InitBalanceRoutes(e, serverToEngineQueueClient)
 	order.InitOrderRoutes(e, serverToEngineQueueClient)
 	symbol.InitSymbolRoutes(e, serverToEngineQueueClient)
 	book.InitBookRoutes(e, serverToEngineQueueClient)
+	amm.InitAMMRoutes(e, serverToEngineQueueClient)
+	lp.InitLPRoutes(e, serverToEngineQueueClient)
+	fees.InitFeeRoutes(e, serverToEngineQueueClient)
+	oracleRoutes.InitOracleRoutes(e)
+	schedule.InitScheduleRoutes(e)
 	e.Logger.Fatal(e.Start(":8080"))
 }
@@ -0,0 +1,138 @@
+package oracle
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Aggregate reports the median of several oracles, so one failed or spiking
+// source can't move the value a market resolves on. Observations older than
+// MaxAgeSeconds are dropped, then those more than MaxDeviation (a fraction,
+// 0.01 for 1%) away from the median of the rest, and the median of what's
+// left is the value. Fewer than MinSources observations left is an error.
+type Aggregate struct {
+	Source        string   `json:"name"`
+	Sources       []string `json:"sources"`
+	MinSources    int      `json:"minSources"`    // a majority of Sources if 0
+	MaxAgeSeconds int64    `json:"maxAgeSeconds"` // 300 if 0
+	MaxDeviation  float64  `json:"maxDeviation"`  // 0.01 if 0
+}
+
+// WithDefaults fills in the limits left at zero
+func (a *Aggregate) WithDefaults() *Aggregate {
+	if a.MinSources == 0 {
+		a.MinSources = len(a.Sources)/2 + 1
+	}
+	if a.MaxAgeSeconds == 0 {
+		a.MaxAgeSeconds = 300
+	}
+	if a.MaxDeviation == 0 {
+		a.MaxDeviation = 0.01
+	}
+	return a
+}
+
+// Validate checks the aggregate can ever agree on a value. Its sources have
+// to be registered already, and can't be aggregates themselves.
+func (a *Aggregate) Validate() error {
+	if a.Source == "" {
+		return fmt.Errorf("aggregate oracles need a name")
+	}
+	if a.MinSources < 1 || a.MinSources > len(a.Sources) {
+		return fmt.Errorf("aggregate %s needs between 1 and %d sources to agree, not %d", a.Source, len(a.Sources), a.MinSources)
+	}
+	if a.MaxAgeSeconds < 0 || a.MaxDeviation < 0 {
+		return fmt.Errorf("aggregate %s can't have a negative max age or deviation", a.Source)
+	}
+	for _, source := range a.Sources {
+		o, err := Lookup(source)
+		if err != nil {
+			return fmt.Errorf("aggregate %s: %v", a.Source, err)
+		}
+		if _, nested := o.(*Aggregate); nested {
+			return fmt.Errorf("aggregate %s can't have the aggregate %s as a source", a.Source, source)
+		}
+	}
+	return nil
+}
+
+func (a *Aggregate) Name() string {
+	return a.Source
+}
+
+// Supports feed if enough of the sources do
+func (a *Aggregate) Supports(feed string) bool {
+	supported := 0
+	for _, name := range a.Sources {
+		if o, err := Lookup(name); err == nil && o.Supports(feed) {
+			supported++
+		}
+	}
+	return supported >= a.MinSources
+}
+
+// Fetch observes feed on every source and returns the median of those that
+// agree, as of the oldest of them
+func (a *Aggregate) Fetch(feed string) (float64, time.Time, error) {
+	type reading struct {
+		value float64
+		at    time.Time
+	}
+	oldest := time.Now().Add(-time.Duration(a.MaxAgeSeconds) * time.Second)
+	var fresh []reading
+	for _, name := range a.Sources {
+		o, err := Lookup(name)
+		if err != nil {
+			fmt.Printf("Aggregate %s: %v\n", a.Source, err)
+			continue
+		}
+		value, reportedAt, err := observe(o, feed)
+		if err != nil {
+			continue
+		}
+		if reportedAt.Before(oldest) {
+			fmt.Printf("Aggregate %s: dropping %s, stale since %s\n", a.Source, name, reportedAt.Format(time.RFC3339))
+			continue
+		}
+		fresh = append(fresh, reading{value, reportedAt})
+	}
+	if len(fresh) < a.MinSources {
+		return 0, time.Time{}, fmt.Errorf("only %d of %d sources of %s answered for %s, %d needed", len(fresh), len(a.Sources), a.Source, feed, a.MinSources)
+	}
+
+	values := make([]float64, len(fresh))
+	for i, r := range fresh {
+		values[i] = r.value
+	}
+	center := median(values)
+
+	var agreed []float64
+	reportedAt := time.Now()
+	for _, r := range fresh {
+		if math.Abs(r.value-center) > a.MaxDeviation*math.Abs(center) {
+			fmt.Printf("Aggregate %s: dropping %v, too far from the median %v\n", a.Source, r.value, center)
+			continue
+		}
+		agreed = append(agreed, r.value)
+		if r.at.Before(reportedAt) {
+			reportedAt = r.at
+		}
+	}
+	if len(agreed) < a.MinSources {
+		return 0, time.Time{}, fmt.Errorf("only %d of %d sources of %s agree on %s, %d needed", len(agreed), len(a.Sources), a.Source, feed, a.MinSources)
+	}
+	return median(agreed), reportedAt, nil
+}
+
+// median returns the middle value, or the mean of the two middle ones
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
@@ -0,0 +1,123 @@
+package oracle
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fixed reports the same value, as of the same time, for every feed
+type fixed struct {
+	name  string
+	value float64
+	at    time.Time
+	err   error
+}
+
+func (f *fixed) Name() string              { return f.name }
+func (f *fixed) Supports(feed string) bool { return true }
+func (f *fixed) Fetch(feed string) (float64, time.Time, error) {
+	return f.value, f.at, f.err
+}
+
+// aggregateOf registers the sources under names unique to the test and
+// returns an aggregate over them
+func aggregateOf(t *testing.T, minSources int, sources ...*fixed) *Aggregate {
+	t.Helper()
+	aggregate := &Aggregate{Source: t.Name() + "/aggregate", MinSources: minSources}
+	for i, source := range sources {
+		source.name = fmt.Sprintf("%s/%d", t.Name(), i)
+		Register(source)
+		aggregate.Sources = append(aggregate.Sources, source.name)
+	}
+	if err := aggregate.WithDefaults().Validate(); err != nil {
+		t.Fatal(err)
+	}
+	return aggregate
+}
+
+func TestAggregateMedian(t *testing.T) {
+	now := time.Now()
+	for _, test := range []struct {
+		name   string
+		values []float64
+		want   float64
+	}{
+		{"odd", []float64{100.5, 100, 100.2}, 100.2},
+		{"even", []float64{100, 100.5, 100.25, 100.75}, 100.375},
+		{"one", []float64{42}, 42},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var sources []*fixed
+			for _, value := range test.values {
+				sources = append(sources, &fixed{value: value, at: now})
+			}
+			value, _, err := aggregateOf(t, 0, sources...).Fetch("bitcoin")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if value != test.want {
+				t.Errorf("median of %v is %v, want %v", test.values, value, test.want)
+			}
+		})
+	}
+}
+
+func TestAggregateStaleness(t *testing.T) {
+	now := time.Now()
+	stale := now.Add(-10 * time.Minute)
+
+	aggregate := aggregateOf(t, 2,
+		&fixed{value: 100, at: now},
+		&fixed{value: 101, at: now.Add(-time.Minute)},
+		&fixed{value: 200, at: stale},
+	)
+	value, reportedAt, err := aggregate.Fetch("bitcoin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != 100.5 {
+		t.Errorf("value is %v, want 100.5 without the stale source", value)
+	}
+	if !reportedAt.Equal(now.Add(-time.Minute)) {
+		t.Errorf("reported at %v, want the oldest fresh source's %v", reportedAt, now.Add(-time.Minute))
+	}
+
+	aggregate = aggregateOf(t, 2,
+		&fixed{value: 100, at: now},
+		&fixed{value: 101, at: stale},
+		&fixed{err: fmt.Errorf("down")},
+	)
+	if _, _, err := aggregate.Fetch("bitcoin"); err == nil || !strings.Contains(err.Error(), "only 1 of 3 sources") {
+		t.Errorf("got %v with one fresh source, want too few answered", err)
+	}
+}
+
+func TestAggregateDeviation(t *testing.T) {
+	now := time.Now()
+
+	// The spike is more than 1% off the median and is dropped
+	aggregate := aggregateOf(t, 2,
+		&fixed{value: 100, at: now},
+		&fixed{value: 100.4, at: now},
+		&fixed{value: 150, at: now},
+	)
+	value, _, err := aggregate.Fetch("bitcoin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != 100.2 {
+		t.Errorf("value is %v, want 100.2 without the spike", value)
+	}
+
+	// Without a majority close to the median there's no value
+	aggregate = aggregateOf(t, 2,
+		&fixed{value: 100, at: now},
+		&fixed{value: 110, at: now},
+		&fixed{value: 120, at: now},
+	)
+	if _, _, err := aggregate.Fetch("bitcoin"); err == nil || !strings.Contains(err.Error(), "agree") {
+		t.Errorf("got %v, want too few sources agreeing", err)
+	}
+}
@@ -0,0 +1,61 @@
+package oracle
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Config lists the oracles to register on top of the built in coingecko and
+// manual ones
+type Config struct {
+	HTTP   []*HTTPJSON `json:"http"`
+	Replay []struct {
+		Name string `json:"name"`
+		Path string `json:"path"`
+	} `json:"replay"`
+	Aggregate []*Aggregate `json:"aggregate"`
+}
+
+// Init registers the built in oracles and the ones in the ORACLE_CONFIG file,
+// if there is one, and sends observations to the database through client
+func Init(client *redis.Client) error {
+	SetClient(client)
+	Register(CoinGecko())
+	Register(NewManual())
+
+	path := os.Getenv("ORACLE_CONFIG")
+	if path == "" {
+		return nil
+	}
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var config Config
+	if err := json.Unmarshal(file, &config); err != nil {
+		return fmt.Errorf("invalid oracle config %s: %v", path, err)
+	}
+	for _, source := range config.HTTP {
+		if source.Source == "" || source.URL == "" {
+			return fmt.Errorf("http oracles need a name and a url")
+		}
+		Register(source)
+	}
+	for _, source := range config.Replay {
+		replay, err := NewReplay(source.Name, source.Path)
+		if err != nil {
+			return err
+		}
+		Register(replay)
+	}
+	for _, aggregate := range config.Aggregate {
+		if err := aggregate.WithDefaults().Validate(); err != nil {
+			return err
+		}
+		Register(aggregate)
+	}
+	return nil
+}
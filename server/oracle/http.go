@@ -0,0 +1,105 @@
+package oracle
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HTTPJSON reads a feed's value out of a JSON HTTP API. %s in URL and Path
+// is replaced by the feed, and Path is the dot separated path to the value in
+// the response, e.g. "%s.usd" for {"bitcoin":{"usd":105000}}. TimePath is
+// the same for when the source last updated the value, as unix seconds or
+// RFC 3339; without one the value is taken to be current.
+type HTTPJSON struct {
+	Source   string   `json:"name"`
+	URL      string   `json:"url"`
+	Path     string   `json:"path"`
+	TimePath string   `json:"timePath"`
+	Feeds    []string `json:"feeds"` // empty for any feed
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// CoinGecko is the USD spot price of a coin on api.coingecko.com
+func CoinGecko() *HTTPJSON {
+	return &HTTPJSON{
+		Source:   "coingecko",
+		URL:      "https://api.coingecko.com/api/v3/simple/price?ids=%s&vs_currencies=usd&include_last_updated_at=true",
+		Path:     "%s.usd",
+		TimePath: "%s.last_updated_at",
+		Feeds:    []string{"bitcoin", "ethereum"},
+	}
+}
+
+func (h *HTTPJSON) Name() string {
+	return h.Source
+}
+
+func (h *HTTPJSON) Supports(feed string) bool {
+	return len(h.Feeds) == 0 || contains(h.Feeds, feed)
+}
+
+func (h *HTTPJSON) Fetch(feed string) (float64, time.Time, error) {
+	resp, err := httpClient.Get(strings.ReplaceAll(h.URL, "%s", feed))
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, time.Time{}, fmt.Errorf("%s answered %s", h.Source, resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	var data interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return 0, time.Time{}, err
+	}
+
+	price, ok := lookupPath(data, h.Path, feed).(float64)
+	if !ok {
+		return 0, time.Time{}, fmt.Errorf("price not found for symbol: %s", feed)
+	}
+	if h.TimePath == "" {
+		return price, time.Now(), nil
+	}
+	switch updatedAt := lookupPath(data, h.TimePath, feed).(type) {
+	case float64:
+		return price, time.Unix(int64(updatedAt), 0), nil
+	case string:
+		reportedAt, err := time.Parse(time.RFC3339, updatedAt)
+		if err != nil {
+			return 0, time.Time{}, fmt.Errorf("invalid update time %q for %s", updatedAt, feed)
+		}
+		return price, reportedAt, nil
+	}
+	return 0, time.Time{}, fmt.Errorf("update time not found for symbol: %s", feed)
+}
+
+// lookupPath returns what's at the dot separated path in decoded JSON, nil if
+// nothing is
+func lookupPath(data interface{}, path, feed string) interface{} {
+	for _, key := range strings.Split(strings.ReplaceAll(path, "%s", feed), ".") {
+		object, ok := data.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		data = object[key]
+	}
+	return data
+}
+
+func contains(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,218 @@
+package oracle
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	types "github.com/adityadeshlahre/probo-v1/shared/types"
+	"github.com/redis/go-redis/v9"
+)
+
+// An Oracle reports the current value of a feed, e.g. the USD price of
+// "bitcoin". Automatic markets are created and resolved on the values of the
+// oracle they picked at creation.
+type Oracle interface {
+	Name() string
+	// Supports reports whether the oracle can be asked for feed
+	Supports(feed string) bool
+	// Fetch returns the value of feed and when the source last updated it
+	Fetch(feed string) (float64, time.Time, error)
+}
+
+// Default is the oracle of automatic markets that don't pick one
+const Default = "coingecko"
+
+// Observations are kept in memory for as long as the longest twap window
+// over their feed needs them, and for keepObservations at least. The
+// database has every one.
+const keepObservations = 10 * time.Minute
+
+// observation is an observation with the time it was made
+type observation struct {
+	types.Observation
+	at time.Time
+}
+
+var (
+	mu           sync.Mutex
+	oracles      = make(map[string]Oracle)
+	observations = make(map[string][]observation) // by feed, oldest first
+	retention    = make(map[string]time.Duration) // by feed, the longest twap window over it
+)
+
+var serverToDatabaseClient *redis.Client
+
+func SetClient(client *redis.Client) {
+	serverToDatabaseClient = client
+}
+
+// Register makes an oracle available to markets under its name, replacing
+// any oracle registered under the same name
+func Register(o Oracle) {
+	mu.Lock()
+	defer mu.Unlock()
+	oracles[o.Name()] = o
+}
+
+// Lookup returns the oracle registered as name, or the default one for ""
+func Lookup(name string) (Oracle, error) {
+	if name == "" {
+		name = Default
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	o, ok := oracles[name]
+	if !ok {
+		return nil, fmt.Errorf("oracle %s isn't registered", name)
+	}
+	return o, nil
+}
+
+// Names returns the names of every registered oracle
+func Names() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	names := make([]string, 0, len(oracles))
+	for name := range oracles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Observe fetches the value of feed from the oracle registered as name and
+// records the observation, failed or not
+func Observe(name, feed string) (float64, error) {
+	o, err := Lookup(name)
+	if err != nil {
+		return 0, err
+	}
+	value, _, err := observe(o, feed)
+	return value, err
+}
+
+func observe(o Oracle, feed string) (float64, time.Time, error) {
+	if !o.Supports(feed) {
+		return 0, time.Time{}, fmt.Errorf("oracle %s doesn't support %s", o.Name(), feed)
+	}
+
+	value, reportedAt, err := o.Fetch(feed)
+	observed := types.Observation{
+		Source:     o.Name(),
+		Feed:       feed,
+		Value:      value,
+		ReportedAt: reportedAt.Format(time.RFC3339),
+		ObservedAt: time.Now().Format(time.RFC3339),
+	}
+	if err != nil {
+		observed.Value = 0
+		observed.ReportedAt = ""
+		observed.Error = err.Error()
+	}
+	record(observed, time.Now())
+	return value, reportedAt, err
+}
+
+// record adds an observation made at now to its feed's log, dropping the
+// ones no twap needs any more, and sends it to the database
+func record(observed types.Observation, now time.Time) {
+	mu.Lock()
+	log := append(observations[observed.Feed], observation{observed, now})
+	keep := max(retention[observed.Feed], keepObservations)
+	dropped := 0
+	for dropped < len(log) && log[dropped].at.Before(now.Add(-keep)) {
+		dropped++
+	}
+	observations[observed.Feed] = log[dropped:]
+	mu.Unlock()
+	fmt.Printf("Oracle %s observed %s = %v %s\n", observed.Source, observed.Feed, observed.Value, observed.Error)
+
+	if serverToDatabaseClient == nil {
+		return
+	}
+	observationData, _ := json.Marshal(observed)
+	observationMsg := types.IncomingMessage{
+		Type: types.OBSERVATION,
+		Data: observationData,
+	}
+	observationMsgBytes, _ := json.Marshal(observationMsg)
+	serverToDatabaseClient.LPush(context.Background(), types.DB_ACTIONS, observationMsgBytes)
+}
+
+// Retain keeps the observations of feed for at least window, so a twap over
+// it has them all
+func Retain(feed string, window time.Duration) {
+	mu.Lock()
+	defer mu.Unlock()
+	retention[feed] = max(retention[feed], window)
+}
+
+// Observations returns the observations of feed still kept, oldest first, or
+// of every feed for ""
+func Observations(feed string) []types.Observation {
+	mu.Lock()
+	var kept []observation
+	if feed == "" {
+		for _, log := range observations {
+			kept = append(kept, log...)
+		}
+	} else {
+		kept = observations[feed]
+	}
+	mu.Unlock()
+
+	found := make([]types.Observation, len(kept))
+	if feed == "" {
+		sort.SliceStable(kept, func(i, j int) bool { return kept[i].at.Before(kept[j].at) })
+	}
+	for i, o := range kept {
+		found[i] = o.Observation
+	}
+	return found
+}
+
+// TWAP returns the time weighted average of the values source reported for
+// feed since since: each value counts for as long as it stood, until the
+// next one. The log only has what was observed, so the scheduler samples
+// feeds with a twap rule through their window.
+func TWAP(source, feed string, since time.Time) (float64, error) {
+	type sample struct {
+		value float64
+		at    time.Time
+	}
+	var samples []sample
+	mu.Lock()
+	for _, o := range observations[feed] {
+		if o.Source != source || o.Error != "" {
+			continue
+		}
+		at := o.at.Truncate(time.Second)
+		if at.Before(since.Truncate(time.Second)) {
+			continue
+		}
+		samples = append(samples, sample{o.Value, at})
+	}
+	mu.Unlock()
+	if len(samples) == 0 {
+		return 0, fmt.Errorf("%s has no observations of %s since %s to average", source, feed, since.Format(time.RFC3339))
+	}
+
+	var weighted, total float64
+	for i := 0; i+1 < len(samples); i++ {
+		held := samples[i+1].at.Sub(samples[i].at).Seconds()
+		weighted += samples[i].value * held
+		total += held
+	}
+	if total == 0 {
+		// Every sample in the same second, they all stood as long
+		for _, s := range samples {
+			weighted += s.value
+		}
+		return weighted / float64(len(samples)), nil
+	}
+	return weighted / total, nil
+}
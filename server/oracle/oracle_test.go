@@ -0,0 +1,71 @@
+package oracle
+
+import (
+	"testing"
+	"time"
+
+	types "github.com/adityadeshlahre/probo-v1/shared/types"
+)
+
+func observeAt(source, feed string, value float64, at time.Time) {
+	record(types.Observation{Source: source, Feed: feed, Value: value, ObservedAt: at.Format(time.RFC3339)}, at)
+}
+
+func TestObservationsTrimmed(t *testing.T) {
+	start := time.Now()
+	feed, twapFeed := t.Name()+"/spot", t.Name()+"/twap"
+	Retain(twapFeed, time.Hour)
+
+	for i := range 120 {
+		at := start.Add(time.Duration(i) * time.Minute)
+		observeAt("test", feed, float64(i), at)
+		observeAt("test", twapFeed, float64(i), at)
+	}
+
+	// The last keepObservations of the plain feed, the last hour of the other
+	spot := Observations(feed)
+	if want := int(keepObservations/time.Minute) + 1; len(spot) != want {
+		t.Errorf("%d observations of %s kept, want %d", len(spot), feed, want)
+	}
+	if twap := Observations(twapFeed); len(twap) != 61 || twap[0].Value != 59 {
+		t.Errorf("%d observations of %s kept from %v, want 61 from 59", len(twap), twapFeed, twap[0].Value)
+	}
+	for _, o := range spot {
+		if o.Feed != feed {
+			t.Fatalf("observations of %s include %+v", feed, o)
+		}
+	}
+}
+
+func TestTWAP(t *testing.T) {
+	feed := t.Name()
+	start := time.Now().Truncate(time.Second)
+	Retain(feed, time.Hour)
+
+	// 100 for 10s, 200 for 30s, then 400 which hasn't stood yet
+	observeAt("test", feed, 100, start)
+	observeAt("other", feed, 1000, start.Add(5*time.Second))
+	observeAt("test", feed, 200, start.Add(10*time.Second))
+	observeAt("test", feed, 400, start.Add(40*time.Second))
+
+	twap, err := TWAP("test", feed, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (100*10 + 200*30) / 40.0; twap != want {
+		t.Errorf("twap is %v, want %v", twap, want)
+	}
+
+	// Only what was observed since counts
+	twap, err = TWAP("test", feed, start.Add(10*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if twap != 200 {
+		t.Errorf("twap since the second observation is %v, want 200", twap)
+	}
+
+	if _, err := TWAP("test", feed, start.Add(time.Minute)); err == nil {
+		t.Error("twap with no observations in its window didn't fail")
+	}
+}
@@ -0,0 +1,55 @@
+package oracle
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Replay plays back the values of a JSON file such as
+// {"bitcoin": [105000, 105200.5]}, one per fetch, then keeps returning the
+// last. It stands in for a live source in tests and offline runs.
+type Replay struct {
+	Source string `json:"name"`
+	Path   string `json:"path"`
+
+	mu     sync.Mutex
+	values map[string][]float64
+	next   map[string]int
+}
+
+// NewReplay loads the values to play back from the file at path
+func NewReplay(name, path string) (*Replay, error) {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string][]float64
+	if err := json.Unmarshal(file, &values); err != nil {
+		return nil, fmt.Errorf("invalid replay file %s: %v", path, err)
+	}
+	return &Replay{Source: name, Path: path, values: values, next: make(map[string]int)}, nil
+}
+
+func (r *Replay) Name() string {
+	return r.Source
+}
+
+func (r *Replay) Supports(feed string) bool {
+	return len(r.values[feed]) > 0
+}
+
+// Fetch returns the next value of feed, as current
+func (r *Replay) Fetch(feed string) (float64, time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	values := r.values[feed]
+	if len(values) == 0 {
+		return 0, time.Time{}, fmt.Errorf("no values to replay for %s", feed)
+	}
+	i := min(r.next[feed], len(values)-1)
+	r.next[feed] = i + 1
+	return values[i], time.Now(), nil
+}
@@ -0,0 +1,230 @@
+package resolution
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/adityadeshlahre/probo-v1/server/oracle"
+	sharedRedis "github.com/adityadeshlahre/probo-v1/shared/redis"
+	"github.com/adityadeshlahre/probo-v1/shared/rule"
+	types "github.com/adityadeshlahre/probo-v1/shared/types"
+	"github.com/redis/go-redis/v9"
+)
+
+// Market is an automatic market to resolve on its oracle's value of a feed
+type Market struct {
+	StockSymbol string             `json:"stockSymbol"`
+	MarketId    string             `json:"marketId"`
+	Oracle      string             `json:"oracle"`
+	Feed        string             `json:"feed"`
+	Rule        string             `json:"rule"`             // bound rule the oracle's value is judged by
+	Scalar      *types.ScalarRange `json:"scalar,omitempty"` // set on scalar markets, which settle on the value itself
+	EndsAt      int64              `json:"endsAt,omitempty"` // unix millis to resolve at
+	Reason      string             `json:"reason,omitempty"` // why it's waiting for a manual resolution
+	QueuedAt    string             `json:"queuedAt,omitempty"`
+}
+
+// Record is how a market was resolved, so anyone can check the rule against
+// the oracle's observations and get the same outcome
+type Record struct {
+	StockSymbol string  `json:"stockSymbol"`
+	Rule        string  `json:"rule"`
+	Oracle      string  `json:"oracle"`
+	Feed        string  `json:"feed"`
+	Value       float64 `json:"value"`
+	Outcome     string  `json:"outcome,omitempty"` // yes or no, empty for scalar markets which settle on Value
+	By          string  `json:"by"`                // "oracle", or "manual" for a value an admin submitted
+	ResolvedAt  string  `json:"resolvedAt"`
+}
+
+// redis hashes of the resolution queue and records, by symbol, so they
+// survive restarts
+const (
+	PENDING_RESOLUTIONS = "PENDING_RESOLUTIONS"
+	RESOLVED_MARKETS    = "RESOLVED_MARKETS"
+)
+
+// How long to wait for the engine to settle a market
+const endTimeout = 30 * time.Second
+
+var serverToEngineClient *redis.Client
+
+// mu keeps two admins from resolving the same market
+var mu sync.Mutex
+
+func SetClient(client *redis.Client) {
+	serverToEngineClient = client
+}
+
+// Resolve ends a market on its oracle's value. If the oracle can't give one,
+// e.g. because too few of its sources agree, the market is queued for an
+// admin to resolve by hand instead of settling on a bad value.
+func Resolve(market Market) error {
+	value, err := evaluate(market)
+	if err == nil {
+		err = end(market, value, "oracle")
+	}
+	if err != nil {
+		if queueErr := Queue(market, err.Error()); queueErr != nil {
+			fmt.Printf("Error queueing %s: %v\n", market.StockSymbol, queueErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// evaluate returns the value of a market's rule: its feed's price, or its
+// time weighted average over the window before the end
+func evaluate(market Market) (float64, error) {
+	resolution, err := rule.Parse(market.Rule)
+	if err != nil {
+		return 0, err
+	}
+	value, err := oracle.Observe(market.Oracle, market.Feed)
+	if err != nil || resolution.Window == 0 {
+		return value, err
+	}
+	since := time.UnixMilli(market.EndsAt).Add(-resolution.Window)
+	return oracle.TWAP(market.Oracle, market.Feed, since)
+}
+
+// Resolved returns how a market was resolved
+func Resolved(stockSymbol string) (Record, error) {
+	recordData, err := serverToEngineClient.HGet(context.Background(), RESOLVED_MARKETS, stockSymbol).Result()
+	if err == redis.Nil {
+		return Record{}, fmt.Errorf("market %s hasn't been resolved", stockSymbol)
+	}
+	if err != nil {
+		return Record{}, err
+	}
+	var record Record
+	err = json.Unmarshal([]byte(recordData), &record)
+	return record, err
+}
+
+// Queue puts a market in the manual resolution queue
+func Queue(market Market, reason string) error {
+	fmt.Printf("Queued %s for manual resolution: %s\n", market.StockSymbol, reason)
+	market.Reason = reason
+	market.QueuedAt = time.Now().Format(time.RFC3339)
+	marketData, _ := json.Marshal(market)
+	return serverToEngineClient.HSet(context.Background(), PENDING_RESOLUTIONS, market.StockSymbol, marketData).Err()
+}
+
+// Pending returns the markets waiting for a manual resolution, oldest first
+func Pending() ([]Market, error) {
+	queued, err := serverToEngineClient.HGetAll(context.Background(), PENDING_RESOLUTIONS).Result()
+	if err != nil {
+		return nil, err
+	}
+	markets := make([]Market, 0, len(queued))
+	for _, marketData := range queued {
+		var market Market
+		if err := json.Unmarshal([]byte(marketData), &market); err == nil {
+			markets = append(markets, market)
+		}
+	}
+	sort.Slice(markets, func(i, j int) bool {
+		return markets[i].QueuedAt < markets[j].QueuedAt
+	})
+	return markets, nil
+}
+
+// ResolveManually ends a queued market on a value an admin looked up, with
+// the same rule its oracle's value would have been judged by
+func ResolveManually(stockSymbol string, value float64) (Market, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	marketData, err := serverToEngineClient.HGet(context.Background(), PENDING_RESOLUTIONS, stockSymbol).Result()
+	if err == redis.Nil {
+		return Market{}, fmt.Errorf("market %s isn't waiting for a manual resolution", stockSymbol)
+	}
+	if err != nil {
+		return Market{}, err
+	}
+	var market Market
+	if err := json.Unmarshal([]byte(marketData), &market); err != nil {
+		return Market{}, err
+	}
+
+	if err := end(market, value, "manual"); err != nil {
+		return Market{}, err
+	}
+	serverToEngineClient.HDel(context.Background(), PENDING_RESOLUTIONS, stockSymbol)
+	return market, nil
+}
+
+// end sends the engine the END_MARKET of a market whose rule came to value
+// and, once the engine has settled it, records how it was resolved
+func end(market Market, value float64, by string) error {
+	record := Record{
+		StockSymbol: market.StockSymbol,
+		Rule:        market.Rule,
+		Oracle:      market.Oracle,
+		Feed:        market.Feed,
+		Value:       value,
+		By:          by,
+		ResolvedAt:  time.Now().Format(time.RFC3339),
+	}
+	endData := map[string]interface{}{
+		"stockSymbol": market.StockSymbol,
+		"marketId":    market.MarketId,
+	}
+	if market.Scalar != nil {
+		endData["value"] = value
+	} else {
+		resolution, err := rule.Parse(market.Rule)
+		if err != nil {
+			return err
+		}
+		yes, err := resolution.Outcome(value)
+		if err != nil {
+			return err
+		}
+		record.Outcome = "no"
+		if yes {
+			record.Outcome = "yes"
+		}
+		endData["winningStock"] = record.Outcome
+	}
+
+	data, _ := json.Marshal(endData)
+	msg := types.IncomingMessage{
+		Type: string(types.END_MARKET),
+		Data: data,
+	}
+	msgBytes, _ := json.Marshal(msg)
+
+	// The market is only resolved once the engine has settled it
+	chKey := "end_market_" + market.StockSymbol
+	ch := make(chan string, 1)
+	sharedRedis.ServerAwaitsForResponseMap[chKey] = ch
+	if err := serverToEngineClient.LPush(context.Background(), types.HTTP_TO_ENGINE, msgBytes).Err(); err != nil {
+		delete(sharedRedis.ServerAwaitsForResponseMap, chKey)
+		return err
+	}
+	var response string
+	select {
+	case response = <-ch:
+	case <-time.After(endTimeout):
+		delete(sharedRedis.ServerAwaitsForResponseMap, chKey)
+		return fmt.Errorf("engine didn't answer ending %s", market.StockSymbol)
+	}
+	var resp types.IncomingMessage
+	json.Unmarshal([]byte(response), &resp)
+	var respData struct {
+		Error string `json:"error"`
+	}
+	json.Unmarshal(resp.Data, &respData)
+	if respData.Error != "" {
+		return fmt.Errorf("engine refused to end %s: %s", market.StockSymbol, respData.Error)
+	}
+
+	fmt.Printf("Resolved %s on %s = %v: %s\n", market.StockSymbol, market.Rule, value, record.Outcome)
+	recordData, _ := json.Marshal(record)
+	return serverToEngineClient.HSet(context.Background(), RESOLVED_MARKETS, market.StockSymbol, recordData).Err()
+}
@@ -0,0 +1,70 @@
+package amm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	sharedRedis "github.com/adityadeshlahre/probo-v1/shared/redis"
+	types "github.com/adityadeshlahre/probo-v1/shared/types"
+	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+)
+
+var router *echo.Echo
+var serverToEngineClient *redis.Client
+
+func InitAMMRoutes(e *echo.Echo, client *redis.Client) {
+	router = e
+	serverToEngineClient = client
+	ammRoutes()
+}
+
+func ammRoutes() {
+	ammGroup := router.Group("/amm")
+	{
+		ammGroup.GET("/:symbol", getQuote)
+	}
+}
+
+// getQuote returns a market maker's prices and what it can lose, and with
+// stockType, side and quantity what that trade with it would cost
+func getQuote(c echo.Context) error {
+	req := types.AMMQuoteProps{
+		StockSymbol: c.Param("symbol"),
+		StockType:   c.QueryParam("stockType"),
+		Side:        c.QueryParam("side"),
+	}
+	if quantity := c.QueryParam("quantity"); quantity != "" {
+		n, err := strconv.ParseInt(quantity, 10, 64)
+		if err != nil || types.CheckShares(types.Shares(n)) != nil {
+			return c.JSON(400, map[string]string{"error": fmt.Sprintf("quantity should be a whole number between 1 and %d", types.MaxShares)})
+		}
+		req.Quantity = types.Shares(n)
+	}
+
+	data, _ := json.Marshal(req)
+	msg := types.IncomingMessage{
+		Type: types.AMM_QUOTE,
+		Data: data,
+	}
+	msgBytes, _ := json.Marshal(msg)
+	err := serverToEngineClient.LPush(c.Request().Context(), types.HTTP_TO_ENGINE, msgBytes).Err()
+	if err != nil {
+		return c.JSON(500, map[string]string{"error": "Failed to send message"})
+	}
+	// Await response
+	ch := make(chan string, 1)
+	sharedRedis.ServerAwaitsForResponseMap["amm_quote_"+req.StockSymbol] = ch
+	response := <-ch
+
+	var resp types.IncomingMessage
+	json.Unmarshal([]byte(response), &resp)
+
+	var respData map[string]interface{}
+	json.Unmarshal(resp.Data, &respData)
+	if _, failed := respData["error"]; failed {
+		return c.JSON(400, respData)
+	}
+	return c.JSON(200, respData)
+}
@@ -0,0 +1,57 @@
+package fees
+
+import (
+	"encoding/json"
+
+	sharedRedis "github.com/adityadeshlahre/probo-v1/shared/redis"
+	types "github.com/adityadeshlahre/probo-v1/shared/types"
+	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+)
+
+var router *echo.Echo
+var serverToEngineClient *redis.Client
+
+func InitFeeRoutes(e *echo.Echo, client *redis.Client) {
+	router = e
+	serverToEngineClient = client
+	feeRoutes()
+}
+
+func feeRoutes() {
+	feeGroup := router.Group("/fees")
+	{
+		feeGroup.GET("/revenue", getRevenue)
+	}
+}
+
+// getRevenue returns the fees collected on a UTC day, ?date=YYYY-MM-DD and
+// today by default, in total and by market
+func getRevenue(c echo.Context) error {
+	req := map[string]string{"date": c.QueryParam("date")}
+	data, _ := json.Marshal(req)
+	msg := types.IncomingMessage{
+		Type: types.FEE_REPORT,
+		Data: data,
+	}
+	msgBytes, _ := json.Marshal(msg)
+	err := serverToEngineClient.LPush(c.Request().Context(), types.HTTP_TO_ENGINE, msgBytes).Err()
+	if err != nil {
+		return c.JSON(500, map[string]string{"error": "Failed to send message"})
+	}
+	// Await response
+	ch := make(chan string, 1)
+	sharedRedis.ServerAwaitsForResponseMap["fee_report_"+req["date"]] = ch
+	response := <-ch
+
+	var resp types.IncomingMessage
+	json.Unmarshal([]byte(response), &resp)
+
+	var respData map[string]interface{}
+	json.Unmarshal(resp.Data, &respData)
+	delete(respData, "requestedDate")
+	if _, failed := respData["error"]; failed {
+		return c.JSON(400, respData)
+	}
+	return c.JSON(200, respData)
+}
@@ -0,0 +1,57 @@
+package lp
+
+import (
+	"encoding/json"
+
+	sharedRedis "github.com/adityadeshlahre/probo-v1/shared/redis"
+	types "github.com/adityadeshlahre/probo-v1/shared/types"
+	"github.com/labstack/echo/v4"
+	"github.com/redis/go-redis/v9"
+)
+
+var router *echo.Echo
+var serverToEngineClient *redis.Client
+
+func InitLPRoutes(e *echo.Echo, client *redis.Client) {
+	router = e
+	serverToEngineClient = client
+	lpRoutes()
+}
+
+func lpRoutes() {
+	lpGroup := router.Group("/lp")
+	{
+		lpGroup.GET("/report", getReport)
+	}
+}
+
+// getReport returns what every market maker was funded with from the
+// treasury and, once its market is over, what it paid back and its P&L.
+// ?symbol= keeps to one market's market makers.
+func getReport(c echo.Context) error {
+	req := map[string]string{"stockSymbol": c.QueryParam("symbol")}
+	data, _ := json.Marshal(req)
+	msg := types.IncomingMessage{
+		Type: types.LP_REPORT,
+		Data: data,
+	}
+	msgBytes, _ := json.Marshal(msg)
+	err := serverToEngineClient.LPush(c.Request().Context(), types.HTTP_TO_ENGINE, msgBytes).Err()
+	if err != nil {
+		return c.JSON(500, map[string]string{"error": "Failed to send message"})
+	}
+	// Await response
+	ch := make(chan string, 1)
+	sharedRedis.ServerAwaitsForResponseMap["lp_report_"+req["stockSymbol"]] = ch
+	response := <-ch
+
+	var resp types.IncomingMessage
+	json.Unmarshal([]byte(response), &resp)
+
+	var respData map[string]interface{}
+	json.Unmarshal(resp.Data, &respData)
+	if _, failed := respData["error"]; failed {
+		return c.JSON(400, respData)
+	}
+	return c.JSON(200, respData)
+}
@@ -0,0 +1,61 @@
+package oracle
+
+import (
+	oracles "github.com/adityadeshlahre/probo-v1/server/oracle"
+	"github.com/labstack/echo/v4"
+)
+
+var router *echo.Echo
+
+func InitOracleRoutes(e *echo.Echo) {
+	router = e
+	oracleRoutes()
+}
+
+func oracleRoutes() {
+	oracleGroup := router.Group("/oracle")
+	{
+		oracleGroup.GET("/list", listOracles)
+		oracleGroup.GET("/observations", getObservations)
+		oracleGroup.POST("/submit", submitValue)
+	}
+}
+
+func listOracles(c echo.Context) error {
+	return c.JSON(200, map[string]interface{}{
+		"default": oracles.Default,
+		"oracles": oracles.Names(),
+	})
+}
+
+// getObservations returns every value the oracles reported, optionally only
+// those of ?feed=
+func getObservations(c echo.Context) error {
+	return c.JSON(200, map[string]interface{}{
+		"observations": oracles.Observations(c.QueryParam("feed")),
+	})
+}
+
+// submitValue sets the value the manual oracle reports for a feed
+func submitValue(c echo.Context) error {
+	var req struct {
+		Feed  string   `json:"feed"`
+		Value *float64 `json:"value"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(400, map[string]string{"error": "Invalid request body"})
+	}
+	if req.Feed == "" || req.Value == nil {
+		return c.JSON(400, map[string]string{"error": "feed and value are required"})
+	}
+
+	o, err := oracles.Lookup("manual")
+	if err != nil {
+		return c.JSON(500, map[string]string{"error": err.Error()})
+	}
+	o.(*oracles.Manual).Submit(req.Feed, *req.Value)
+	return c.JSON(200, map[string]interface{}{
+		"feed":  req.Feed,
+		"value": *req.Value,
+	})
+}
@@ -3,6 +3,7 @@ package order
 import (
 	"encoding/json"
 
+	"github.com/adityadeshlahre/probo-v1/server/admin"
 	sharedRedis "github.com/adityadeshlahre/probo-v1/shared/redis"
 	types "github.com/adityadeshlahre/probo-v1/shared/types"
 	"github.com/labstack/echo/v4"
@@ -24,7 +25,12 @@ func orderRoutes() {
 		orderGroup.POST("/buy", placeBuyOrder)
 		orderGroup.POST("/sell", placeSellOrder)
 		orderGroup.POST("/cancel", cancelOrder)
+		orderGroup.POST("/split", splitSet)
+		orderGroup.POST("/merge", mergeSet)
 		orderGroup.POST("/endmarket", endMarket)
+		orderGroup.POST("/propose", proposeResolution)
+		orderGroup.POST("/dispute", disputeResolution)
+		orderGroup.POST("/vote", voteResolution)
 	}
 }
 
@@ -33,6 +39,9 @@ func placeBuyOrder(c echo.Context) error {
 	if err := c.Bind(&orderProps); err != nil {
 		return c.JSON(400, map[string]string{"error": "Invalid order data"})
 	}
+	if err := types.CheckShares(orderProps.Quantity); err != nil {
+		return c.JSON(400, map[string]string{"error": err.Error()})
+	}
 
 	data, _ := json.Marshal(orderProps)
 	msg := types.IncomingMessage{
@@ -65,6 +74,9 @@ func placeSellOrder(c echo.Context) error {
 	if err := c.Bind(&orderProps); err != nil {
 		return c.JSON(400, map[string]string{"error": "Invalid order data"})
 	}
+	if err := types.CheckShares(orderProps.Quantity); err != nil {
+		return c.JSON(400, map[string]string{"error": err.Error()})
+	}
 
 	data, _ := json.Marshal(orderProps)
 	msg := types.IncomingMessage{
@@ -93,11 +105,12 @@ func placeSellOrder(c echo.Context) error {
 }
 
 func cancelOrder(c echo.Context) error {
-	var req struct {
-		OrderId string `json:"orderId"`
-	}
+	var req types.CancelOrderProps
 	if err := c.Bind(&req); err != nil {
-		return c.String(400, "Invalid request")
+		return c.JSON(400, map[string]string{"error": "Invalid request"})
+	}
+	if req.OrderId == "" || req.UserId == "" {
+		return c.JSON(400, map[string]string{"error": "orderId and userId are required"})
 	}
 	data, _ := json.Marshal(req)
 	msg := types.IncomingMessage{
@@ -107,34 +120,154 @@ func cancelOrder(c echo.Context) error {
 	msgBytes, _ := json.Marshal(msg)
 	err := serverToEngineQueueClient.LPush(c.Request().Context(), types.HTTP_TO_ENGINE, msgBytes).Err()
 	if err != nil {
-		return c.String(500, "Failed to send message")
+		return c.JSON(500, map[string]string{"error": "Failed to send message"})
 	}
 	// Await response
 	ch := make(chan string, 1)
 	sharedRedis.ServerAwaitsForResponseMap[req.OrderId] = ch
-	<-ch
-	return c.String(200, "Order cancelled")
+	response := <-ch
+
+	var resp types.IncomingMessage
+	if err := json.Unmarshal([]byte(response), &resp); err == nil && resp.Type == types.CANCEL_ORDER {
+		var data map[string]interface{}
+		json.Unmarshal(resp.Data, &data)
+		if _, failed := data["error"]; failed {
+			return c.JSON(400, data)
+		}
+		return c.JSON(200, data)
+	}
+
+	return c.JSON(500, map[string]string{"error": "Failed to cancel order"})
 }
 
+func splitSet(c echo.Context) error {
+	return completeSet(c, types.SPLIT_SET)
+}
+
+func mergeSet(c echo.Context) error {
+	return completeSet(c, types.MERGE_SET)
+}
+
+// completeSet sends a split or merge of YES+NO pairs to the engine
+func completeSet(c echo.Context, action string) error {
+	var req types.CompleteSetProps
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(400, map[string]string{"error": "Invalid request"})
+	}
+	if req.UserId == "" || req.StockSymbol == "" {
+		return c.JSON(400, map[string]string{"error": "userId and stockSymbol are required"})
+	}
+	if err := types.CheckShares(req.Quantity); err != nil {
+		return c.JSON(400, map[string]string{"error": err.Error()})
+	}
+	data, _ := json.Marshal(req)
+	msg := types.IncomingMessage{
+		Type: action,
+		Data: data,
+	}
+	msgBytes, _ := json.Marshal(msg)
+	err := serverToEngineQueueClient.LPush(c.Request().Context(), types.HTTP_TO_ENGINE, msgBytes).Err()
+	if err != nil {
+		return c.JSON(500, map[string]string{"error": "Failed to send message"})
+	}
+	// Await response
+	ch := make(chan string, 1)
+	sharedRedis.ServerAwaitsForResponseMap[req.UserId] = ch
+	response := <-ch
+
+	var resp types.IncomingMessage
+	if err := json.Unmarshal([]byte(response), &resp); err == nil && resp.Type == action {
+		var data map[string]interface{}
+		json.Unmarshal(resp.Data, &data)
+		if _, failed := data["error"]; failed {
+			return c.JSON(400, data)
+		}
+		return c.JSON(200, data)
+	}
+
+	return c.JSON(500, map[string]string{"error": "Failed to split or merge sets"})
+}
+
+// endMarket ends a manual market the way it always could, which now proposes
+// its outcome: the market settles once the dispute window passes undisputed
 func endMarket(c echo.Context) error {
-	var req struct {
-		StockSymbol  string `json:"stockSymbol"`
-		MarketId     string `json:"marketId"`
-		WinningStock string `json:"winningStock"`
+	return proposeResolution(c)
+}
+
+// proposeResolution proposes the outcome of a manual market with evidence.
+// Only an admin, signing with their key, can propose while it's trading.
+func proposeResolution(c echo.Context) error {
+	var req types.ProposeResolutionProps
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(400, map[string]string{"error": "Invalid request"})
+	}
+	req.ByAdmin = false
+	if adminId, ok := admin.Authenticate(c.Request().Header.Get("Authorization")); ok {
+		req.UserId, req.ByAdmin = adminId, true
+	}
+	if req.UserId == "" || req.StockSymbol == "" {
+		return c.JSON(400, map[string]string{"error": "userId and stockSymbol are required"})
+	}
+	return sendResolution(c, types.PROPOSE_RESOLUTION, req, req.StockSymbol, req.UserId)
+}
+
+// disputeResolution challenges a proposed outcome, posting the dispute bond
+func disputeResolution(c echo.Context) error {
+	var req types.DisputeResolutionProps
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(400, map[string]string{"error": "Invalid request"})
+	}
+	if req.UserId == "" || req.StockSymbol == "" {
+		return c.JSON(400, map[string]string{"error": "userId and stockSymbol are required"})
 	}
+	return sendResolution(c, types.DISPUTE_RESOLUTION, req, req.StockSymbol, req.UserId)
+}
+
+// voteResolution records an admin's ruling on a disputed market. The admin
+// is whoever the request's key belongs to.
+func voteResolution(c echo.Context) error {
+	var req types.VoteResolutionProps
 	if err := c.Bind(&req); err != nil {
-		return c.String(400, "Invalid request")
+		return c.JSON(400, map[string]string{"error": "Invalid request"})
+	}
+	adminId, ok := admin.Authenticate(c.Request().Header.Get("Authorization"))
+	if !ok {
+		return c.JSON(401, map[string]string{"error": "Voting needs a resolution admin's key"})
 	}
+	req.AdminId = adminId
+	if req.StockSymbol == "" {
+		return c.JSON(400, map[string]string{"error": "stockSymbol is required"})
+	}
+	return sendResolution(c, types.VOTE_RESOLUTION, req, req.StockSymbol, req.AdminId)
+}
+
+// sendResolution sends a step of a market's resolution to the engine and
+// returns the market's resolution as it stands after it
+func sendResolution(c echo.Context, action string, req interface{}, stockSymbol, userId string) error {
 	data, _ := json.Marshal(req)
 	msg := types.IncomingMessage{
-		Type: string(types.END_MARKET),
+		Type: action,
 		Data: data,
 	}
 	msgBytes, _ := json.Marshal(msg)
 	err := serverToEngineQueueClient.LPush(c.Request().Context(), types.HTTP_TO_ENGINE, msgBytes).Err()
 	if err != nil {
-		return c.String(500, "Failed to send message")
+		return c.JSON(500, map[string]string{"error": "Failed to send message"})
 	}
-	// For end market, perhaps no response needed, or await
-	return c.String(200, "Market end initiated")
+	// Await response
+	ch := make(chan string, 1)
+	sharedRedis.ServerAwaitsForResponseMap["resolution_"+stockSymbol+"_"+userId] = ch
+	response := <-ch
+
+	var resp types.IncomingMessage
+	if err := json.Unmarshal([]byte(response), &resp); err == nil && resp.Type == action {
+		var data map[string]interface{}
+		json.Unmarshal(resp.Data, &data)
+		if _, failed := data["error"]; failed {
+			return c.JSON(400, data)
+		}
+		return c.JSON(200, data)
+	}
+
+	return c.JSON(500, map[string]string{"error": "Failed to update the market's resolution"})
 }
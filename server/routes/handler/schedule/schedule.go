@@ -0,0 +1,55 @@
+package schedule
+
+import (
+	"github.com/adityadeshlahre/probo-v1/server/scheduler"
+	"github.com/labstack/echo/v4"
+)
+
+var router *echo.Echo
+
+func InitScheduleRoutes(e *echo.Echo) {
+	router = e
+	scheduleRoutes()
+}
+
+func scheduleRoutes() {
+	scheduleGroup := router.Group("/schedule")
+	{
+		scheduleGroup.GET("/list", listSeries)
+		scheduleGroup.POST("/pause/:id", pauseSeries)
+		scheduleGroup.POST("/resume/:id", resumeSeries)
+		scheduleGroup.DELETE("/delete/:id", deleteSeries)
+	}
+}
+
+// listSeries returns every recurring automatic market series
+func listSeries(c echo.Context) error {
+	return c.JSON(200, map[string]interface{}{
+		"series": scheduler.List(),
+	})
+}
+
+func pauseSeries(c echo.Context) error {
+	series, err := scheduler.Pause(c.Param("id"))
+	if err != nil {
+		return c.JSON(400, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(200, series)
+}
+
+func resumeSeries(c echo.Context) error {
+	series, err := scheduler.Resume(c.Param("id"))
+	if err != nil {
+		return c.JSON(400, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(200, series)
+}
+
+// deleteSeries stops a series for good, the markets it created still resolve
+func deleteSeries(c echo.Context) error {
+	id := c.Param("id")
+	if err := scheduler.Delete(id); err != nil {
+		return c.JSON(400, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(200, map[string]string{"id": id, "status": "deleted"})
+}
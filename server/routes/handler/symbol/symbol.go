@@ -3,11 +3,11 @@ package symbol
 import (
 	"encoding/json"
 	"fmt"
-	"io"
-	"math/rand"
-	"net/http"
+	"strings"
 	"time"
 
+	"github.com/adityadeshlahre/probo-v1/server/resolution"
+	"github.com/adityadeshlahre/probo-v1/server/scheduler"
 	sharedRedis "github.com/adityadeshlahre/probo-v1/shared/redis"
 	types "github.com/adityadeshlahre/probo-v1/shared/types"
 	"github.com/labstack/echo/v4"
@@ -18,8 +18,6 @@ var router *echo.Echo
 
 var serverToEngineClient *redis.Client
 
-var automaticMarketsAllowed = []string{"bitcoin", "ethereum"}
-
 func InitSymbolRoutes(e *echo.Echo, client *redis.Client) {
 	router = e
 	serverToEngineClient = client
@@ -30,169 +28,49 @@ func symbolRoutes() {
 	symbolGroup := router.Group("/symbol")
 	{
 		symbolGroup.POST("/createmarket", createMarket)
+		symbolGroup.POST("/status", setMarketStatus)
+		symbolGroup.GET("/pending", getPendingResolutions)
+		symbolGroup.POST("/resolve", resolveMarket)
+		symbolGroup.GET("/resolution/:symbol", getResolution)
 	}
 }
 
-func getCurrentMarketPrice(stockSymbol string) (float64, error) {
-	url := fmt.Sprintf("https://api.coingecko.com/api/v3/simple/price?ids=%s&vs_currencies=usd", stockSymbol)
-	resp, err := http.Get(url)
-	if err != nil {
-		return 0, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return 0, err
-	}
-
-	var data map[string]map[string]float64
-	if err := json.Unmarshal(body, &data); err != nil {
-		return 0, err
-	}
-
-	if symbolData, ok := data[stockSymbol]; ok {
-		if price, ok := symbolData["usd"]; ok {
-			return price, nil
-		}
-	}
-
-	return 0, fmt.Errorf("price not found for symbol: %s", stockSymbol)
-}
-
-func createMarketCondition(stockSymbol string) (float64, error) {
-	price, err := getCurrentMarketPrice(stockSymbol)
-	if err != nil {
-		return 0, err
-	}
-
-	// Random multiplier: +1 or -1
-	multiplier := float64(1)
-	if rand.Float64() > 0.5 {
-		multiplier = -1
-	}
-
-	couldBePrice := multiplier*price*0.02 + price
-	return couldBePrice, nil
-}
-
 func createMarket(c echo.Context) error {
 	var req types.CreateMarket
 	if err := c.Bind(&req); err != nil {
 		return c.String(400, "Invalid request body")
 	}
 
-	endTime := time.Now().UnixMilli() + req.EndAfterTime
-
 	if req.MarketType == "automatic" && req.SourceOfTruth == "automatic" {
-		if contains(automaticMarketsAllowed, req.Symbol) {
-			repeatInterval := req.RepeatEventTime
-			if repeatInterval == 0 {
-				repeatInterval = 60000 // Default 1 minute
-			}
-
-			go func() {
-				ticker := time.NewTicker(time.Duration(repeatInterval) * time.Millisecond)
-				defer ticker.Stop()
-
-				for range ticker.C {
-					currentTime := time.Now().UnixMilli()
-					if currentTime > endTime {
-						break
-					}
-
-					couldBePrice, err := createMarketCondition(req.Symbol)
-					if err != nil {
-						fmt.Printf("Error creating market condition: %v\n", err)
-						continue
-					}
-
-					stockUniqueSymbol := fmt.Sprintf("%s-%d", req.Symbol, time.Now().UnixMilli())
-
-					marketData := map[string]interface{}{
-						"symbol":    stockUniqueSymbol,
-						"price":     couldBePrice,
-						"heading":   req.Heading,
-						"eventType": req.EventType,
-						"type":      req.MarketType,
-					}
-
-					data, _ := json.Marshal(marketData)
-					msg := types.IncomingMessage{
-						Type: string(types.CREATE_MARKET),
-						Data: data,
-					}
-					msgBytes, _ := json.Marshal(msg)
-
-					err = serverToEngineClient.LPush(c.Request().Context(), types.HTTP_TO_ENGINE, msgBytes).Err()
-					if err != nil {
-						fmt.Printf("Error sending create market message: %v\n", err)
-						continue
-					}
-
-					// Wait for response
-					ch := make(chan string, 1)
-					sharedRedis.ServerAwaitsForResponseMap["create_market_"+stockUniqueSymbol] = ch
-					response := <-ch
-
-					var respData map[string]interface{}
-					json.Unmarshal([]byte(response), &respData)
-
-					marketId := ""
-					for key := range respData {
-						if key != "status" {
-							marketId = key
-							break
-						}
-					}
-
-					// Schedule market end
-					go func(marketId, stockUniqueSymbol string, couldBePrice float64) {
-						time.Sleep(time.Duration(req.EndsIn) * time.Millisecond)
-
-						currentPrice, err := getCurrentMarketPrice(req.Symbol)
-						if err != nil {
-							fmt.Printf("Error getting current price: %v\n", err)
-							return
-						}
-
-						winningStock := "no"
-						if currentPrice > couldBePrice {
-							winningStock = "yes"
-						}
-
-						endData := map[string]interface{}{
-							"symbol":       stockUniqueSymbol,
-							"marketId":     marketId,
-							"winningStock": winningStock,
-						}
-
-						data, _ := json.Marshal(endData)
-						msg := types.IncomingMessage{
-							Type: string(types.END_MARKET),
-							Data: data,
-						}
-						msgBytes, _ := json.Marshal(msg)
-
-						serverToEngineClient.LPush(c.Request().Context(), types.HTTP_TO_ENGINE, msgBytes).Err()
-					}(marketId, stockUniqueSymbol, couldBePrice)
-				}
-			}()
-
-			return c.String(200, "Market creation scheduled")
-		} else {
-			return c.String(400, "Stock symbol not allowed for automatic markets")
+		// The scheduler creates a market every repeatEventTime until endAfterTime
+		series, err := scheduler.Add(req)
+		if err != nil {
+			return c.String(400, err.Error())
 		}
+		return c.JSON(200, map[string]interface{}{
+			"status": "Market creation scheduled",
+			"series": series,
+		})
 	} else if req.MarketType == "manual" && req.SourceOfTruth == "manual" {
 		// For manual markets, create a single market
 		stockUniqueSymbol := fmt.Sprintf("%s-%d", req.Symbol, time.Now().UnixMilli())
 
 		marketData := map[string]interface{}{
-			"symbol":    stockUniqueSymbol,
-			"price":     0, // Manual markets might not need price
-			"heading":   req.Heading,
-			"eventType": req.EventType,
-			"type":      req.MarketType,
+			"symbol":        stockUniqueSymbol,
+			"price":         0, // Manual markets might not need price
+			"heading":       req.Heading,
+			"eventType":     req.EventType,
+			"marketType":    req.MarketType,
+			"sourceOfTruth": req.SourceOfTruth,
+			"draft":         req.Draft,
+			"endsIn":        req.EndsIn,
+			"contract":      req.Contract,
+			"outcomes":      req.Outcomes,
+			"scalar":        req.Scalar,
+			// how long a proposed outcome can be disputed, 0 for the engine's default
+			"disputeWindow": req.DisputeWindow,
+			"amm":           req.AMM,
+			"lpSubsidy":     req.LPSubsidy,
 		}
 
 		data, _ := json.Marshal(marketData)
@@ -218,17 +96,95 @@ func createMarket(c echo.Context) error {
 		var respData map[string]interface{}
 		json.Unmarshal(resp.Data, &respData)
 
+		if created, ok := respData[stockUniqueSymbol].(map[string]interface{}); ok && created["error"] != nil {
+			return c.JSON(400, respData)
+		}
 		return c.JSON(200, respData)
 	} else {
 		return c.String(400, "Invalid type or sourceOfTruth")
 	}
 }
 
-func contains(slice []string, item string) bool {
-	for _, s := range slice {
-		if s == item {
-			return true
-		}
+// setMarketStatus opens, halts or closes a market
+func setMarketStatus(c echo.Context) error {
+	var req types.MarketStatusProps
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(400, map[string]string{"error": "Invalid request body"})
+	}
+	if req.StockSymbol == "" || req.Status == "" {
+		return c.JSON(400, map[string]string{"error": "stockSymbol and status are required"})
+	}
+	req.Status = types.MarketStatus(strings.ToUpper(string(req.Status)))
+
+	data, _ := json.Marshal(req)
+	msg := types.IncomingMessage{
+		Type: types.MARKET_STATUS,
+		Data: data,
+	}
+	msgBytes, _ := json.Marshal(msg)
+	err := serverToEngineClient.LPush(c.Request().Context(), types.HTTP_TO_ENGINE, msgBytes).Err()
+	if err != nil {
+		return c.JSON(500, map[string]string{"error": "Failed to send message"})
+	}
+
+	// Wait for response
+	ch := make(chan string, 1)
+	sharedRedis.ServerAwaitsForResponseMap["market_status_"+req.StockSymbol] = ch
+	response := <-ch
+
+	var resp types.IncomingMessage
+	json.Unmarshal([]byte(response), &resp)
+
+	var respData map[string]interface{}
+	json.Unmarshal(resp.Data, &respData)
+	if _, failed := respData["error"]; failed {
+		return c.JSON(400, respData)
+	}
+	return c.JSON(200, respData)
+}
+
+// getPendingResolutions lists the automatic markets whose oracle couldn't
+// give a value to resolve on
+func getPendingResolutions(c echo.Context) error {
+	pending, err := resolution.Pending()
+	if err != nil {
+		return c.JSON(500, map[string]string{"error": "Failed to read the resolution queue"})
+	}
+	return c.JSON(200, map[string]interface{}{
+		"pending": pending,
+	})
+}
+
+// resolveMarket resolves a pending automatic market on a value looked up by hand
+func resolveMarket(c echo.Context) error {
+	var req struct {
+		StockSymbol string   `json:"stockSymbol"`
+		Value       *float64 `json:"value"`
+	}
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(400, map[string]string{"error": "Invalid request body"})
+	}
+	if req.StockSymbol == "" || req.Value == nil {
+		return c.JSON(400, map[string]string{"error": "stockSymbol and value are required"})
+	}
+
+	market, err := resolution.ResolveManually(req.StockSymbol, *req.Value)
+	if err != nil {
+		return c.JSON(400, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(200, map[string]interface{}{
+		"stockSymbol": market.StockSymbol,
+		"value":       *req.Value,
+		"status":      "Market end initiated",
+	})
+}
+
+// getResolution returns how an automatic market was resolved: its rule, the
+// value the rule came to and who supplied it
+func getResolution(c echo.Context) error {
+	record, err := resolution.Resolved(c.Param("symbol"))
+	if err != nil {
+		return c.JSON(404, map[string]string{"error": err.Error()})
 	}
-	return false
+	return c.JSON(200, record)
 }
@@ -41,6 +41,15 @@ func createUser(c echo.Context) error {
 	// Await response
 	ch := make(chan string, 1)
 	sharedRedis.ServerAwaitsForResponseMap[id] = ch
-	<-ch
+	response := <-ch
+
+	var resp types.IncomingMessage
+	json.Unmarshal([]byte(response), &resp)
+
+	var respData map[string]interface{}
+	json.Unmarshal(resp.Data, &respData)
+	if _, failed := respData["error"]; failed {
+		return c.JSON(400, respData)
+	}
 	return c.String(200, "User "+id+" created")
 }
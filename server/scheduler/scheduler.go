@@ -0,0 +1,430 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/adityadeshlahre/probo-v1/server/oracle"
+	"github.com/adityadeshlahre/probo-v1/server/resolution"
+	sharedRedis "github.com/adityadeshlahre/probo-v1/shared/redis"
+	"github.com/adityadeshlahre/probo-v1/shared/rule"
+	types "github.com/adityadeshlahre/probo-v1/shared/types"
+	"github.com/redis/go-redis/v9"
+)
+
+// redis hashes the schedule is kept in, so it survives restarts
+const (
+	SCHEDULER_SERIES      = "SCHEDULER_SERIES"      // series by id
+	SCHEDULER_RESOLUTIONS = "SCHEDULER_RESOLUTIONS" // markets waiting for their end, by symbol
+)
+
+// A market whose end went by this long ago, e.g. while the server was down,
+// is queued for manual resolution rather than resolved on today's value
+const maxLateResolution = time.Minute
+
+// How long to wait for the engine to create a market
+const createTimeout = 30 * time.Second
+
+type SeriesStatus string
+
+const (
+	SeriesActive   SeriesStatus = "ACTIVE"
+	SeriesPaused   SeriesStatus = "PAUSED"
+	SeriesFinished SeriesStatus = "FINISHED"
+)
+
+// Series is a recurring automatic market: every Interval until EndsAt it
+// creates a market from Definition, and resolves each one EndsIn later
+type Series struct {
+	Id         string             `json:"id"`
+	Definition types.CreateMarket `json:"definition"`
+	Interval   int64              `json:"interval"` // millis between markets
+	EndsAt     int64              `json:"endsAt"`   // unix millis after which no market is created
+	NextAt     int64              `json:"nextAt"`   // unix millis the next market is created at
+	Status     SeriesStatus       `json:"status"`
+	Created    int                `json:"created"` // markets created so far
+	LastSymbol string             `json:"lastSymbol,omitempty"`
+	CreatedAt  string             `json:"createdAt"`
+}
+
+var serverToEngineClient *redis.Client
+
+var (
+	mu          sync.Mutex
+	series      = make(map[string]*Series)
+	resolutions = make(map[string]resolution.Market)
+	lastSampled = make(map[string]time.Time) // by symbol, for markets with a twap rule
+)
+
+// Start loads the schedule saved in redis and runs it
+func Start(client *redis.Client) error {
+	serverToEngineClient = client
+	ctx := context.Background()
+
+	savedSeries, err := client.HGetAll(ctx, SCHEDULER_SERIES).Result()
+	if err != nil {
+		return err
+	}
+	savedResolutions, err := client.HGetAll(ctx, SCHEDULER_RESOLUTIONS).Result()
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	for id, seriesData := range savedSeries {
+		var s Series
+		if err := json.Unmarshal([]byte(seriesData), &s); err != nil {
+			fmt.Printf("Scheduler: skipping series %s: %v\n", id, err)
+			continue
+		}
+		series[id] = &s
+		retainTWAP(seriesRuleText(s.Definition))
+	}
+	for symbol, marketData := range savedResolutions {
+		var market resolution.Market
+		if err := json.Unmarshal([]byte(marketData), &market); err != nil {
+			fmt.Printf("Scheduler: skipping resolution of %s: %v\n", symbol, err)
+			continue
+		}
+		resolutions[symbol] = market
+		retainTWAP(market.Rule)
+	}
+	fmt.Printf("Scheduler: resumed %d series and %d resolutions\n", len(series), len(resolutions))
+	mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			tick(now)
+		}
+	}()
+	return nil
+}
+
+// Add schedules a series of automatic markets from a createmarket request
+func Add(definition types.CreateMarket) (Series, error) {
+	source, err := oracle.Lookup(definition.Oracle)
+	if err != nil {
+		return Series{}, err
+	}
+	definition.Oracle = source.Name()
+	if len(definition.Outcomes) > 0 {
+		return Series{}, fmt.Errorf("automatic markets can't be categorical")
+	}
+	// The symbol is the feed of a series without a rule
+	feed := definition.Symbol
+	if definition.Rule != "" {
+		seriesRule, err := rule.Parse(definition.Rule)
+		if err != nil {
+			return Series{}, err
+		}
+		if seriesRule.IsValue() != (definition.Scalar != nil) {
+			return Series{}, fmt.Errorf("rule %s doesn't fit the market, scalar markets take a value such as price(%s) and YES/NO ones a condition", seriesRule, seriesRule.Feed)
+		}
+		definition.Rule = seriesRule.String()
+		feed = seriesRule.Feed
+	}
+	if !source.Supports(feed) {
+		return Series{}, fmt.Errorf("stock symbol not supported by oracle %s", source.Name())
+	}
+
+	if definition.EndsIn <= 0 {
+		return Series{}, fmt.Errorf("endsIn has to be a positive number of milliseconds")
+	}
+	if definition.RepeatEventTime < 0 {
+		return Series{}, fmt.Errorf("repeatEventTime has to be a positive number of milliseconds")
+	}
+	interval := definition.RepeatEventTime
+	if interval == 0 {
+		interval = 60000 // Default 1 minute
+	}
+	now := time.Now()
+	s := &Series{
+		Id:         fmt.Sprintf("%s-%d", definition.Symbol, now.UnixMilli()),
+		Definition: definition,
+		Interval:   interval,
+		EndsAt:     now.UnixMilli() + definition.EndAfterTime,
+		NextAt:     now.UnixMilli() + interval,
+		Status:     SeriesActive,
+		CreatedAt:  now.Format(time.RFC3339),
+	}
+
+	retainTWAP(seriesRuleText(definition))
+
+	mu.Lock()
+	defer mu.Unlock()
+	series[s.Id] = s
+	return *s, saveSeries(s)
+}
+
+// retainTWAP keeps the observations a twap rule averages until its market
+// resolves, as late as it may
+func retainTWAP(ruleText string) {
+	if r, err := rule.Parse(ruleText); err == nil && r.Window > 0 {
+		oracle.Retain(r.Feed, r.Window+maxLateResolution)
+	}
+}
+
+// List returns every series, oldest first
+func List() []Series {
+	mu.Lock()
+	defer mu.Unlock()
+	list := make([]Series, 0, len(series))
+	for _, s := range series {
+		list = append(list, *s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt < list[j].CreatedAt || (list[i].CreatedAt == list[j].CreatedAt && list[i].Id < list[j].Id)
+	})
+	return list
+}
+
+// Pause stops a series creating markets until it's resumed. Markets it
+// already created still resolve.
+func Pause(id string) (Series, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	s, exists := series[id]
+	if !exists {
+		return Series{}, fmt.Errorf("series %s doesn't exist", id)
+	}
+	if s.Status != SeriesActive {
+		return Series{}, fmt.Errorf("series %s is %s, only ACTIVE series can be paused", id, s.Status)
+	}
+	s.Status = SeriesPaused
+	return *s, saveSeries(s)
+}
+
+// Resume restarts a paused series at its next interval from now
+func Resume(id string) (Series, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	s, exists := series[id]
+	if !exists {
+		return Series{}, fmt.Errorf("series %s doesn't exist", id)
+	}
+	if s.Status != SeriesPaused {
+		return Series{}, fmt.Errorf("series %s is %s, only PAUSED series can be resumed", id, s.Status)
+	}
+	s.Status = SeriesActive
+	s.NextAt = nextAfter(s.NextAt, s.Interval, time.Now().UnixMilli())
+	return *s, saveSeries(s)
+}
+
+// Delete removes a series. Markets it already created still resolve.
+func Delete(id string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, exists := series[id]; !exists {
+		return fmt.Errorf("series %s doesn't exist", id)
+	}
+	delete(series, id)
+	return serverToEngineClient.HDel(context.Background(), SCHEDULER_SERIES, id).Err()
+}
+
+// tick creates the markets and resolves the ones that are due at now
+func tick(now time.Time) {
+	nowMillis := now.UnixMilli()
+
+	mu.Lock()
+	var due []Series
+	for _, s := range series {
+		if s.Status != SeriesActive || s.NextAt > nowMillis {
+			continue
+		}
+		if s.NextAt > s.EndsAt {
+			s.Status = SeriesFinished
+			fmt.Printf("Scheduler: series %s finished after %d markets\n", s.Id, s.Created)
+		} else {
+			due = append(due, *s)
+			// Markets missed while the server was down aren't made up
+			s.NextAt = nextAfter(s.NextAt, s.Interval, nowMillis)
+		}
+		saveSeries(s)
+	}
+	var ending, sampling []resolution.Market
+	for symbol, market := range resolutions {
+		if market.EndsAt <= nowMillis {
+			ending = append(ending, market)
+			delete(resolutions, symbol)
+			delete(lastSampled, symbol)
+			serverToEngineClient.HDel(context.Background(), SCHEDULER_RESOLUTIONS, symbol)
+		} else if dueSample(market, now) {
+			sampling = append(sampling, market)
+			lastSampled[symbol] = now
+		}
+	}
+	mu.Unlock()
+
+	for _, market := range sampling {
+		go oracle.Observe(market.Oracle, market.Feed)
+	}
+	for _, market := range ending {
+		go func(market resolution.Market) {
+			if late := now.Sub(time.UnixMilli(market.EndsAt)); late > maxLateResolution {
+				resolution.Queue(market, fmt.Sprintf("resolution was due %s ago, the oracle's value now isn't its value then", late.Round(time.Second)))
+				return
+			}
+			resolution.Resolve(market)
+		}(market)
+	}
+	// Creating a market waits for the engine, which mustn't hold up the
+	// other series or the resolutions
+	for _, s := range due {
+		go createNext(s)
+	}
+}
+
+// createNext creates the next market of a series and schedules its resolution
+func createNext(s Series) {
+	market, err := createMarket(s)
+	if err != nil {
+		fmt.Printf("Scheduler: series %s: %v\n", s.Id, err)
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if current, exists := series[s.Id]; exists {
+		current.Created++
+		current.LastSymbol = market.StockSymbol
+		saveSeries(current)
+	}
+	resolutions[market.StockSymbol] = market
+	marketData, _ := json.Marshal(market)
+	serverToEngineClient.HSet(context.Background(), SCHEDULER_RESOLUTIONS, market.StockSymbol, marketData)
+}
+
+// dueSample reports whether a market with a twap rule is in its averaging
+// window and due another observation, about twenty per window
+func dueSample(market resolution.Market, now time.Time) bool {
+	marketRule, err := rule.Parse(market.Rule)
+	if err != nil || marketRule.Window == 0 {
+		return false
+	}
+	if now.Before(time.UnixMilli(market.EndsAt).Add(-marketRule.Window)) {
+		return false
+	}
+	every := max(marketRule.Window/20, time.Second)
+	return now.Sub(lastSampled[market.StockSymbol]) >= every
+}
+
+// nextAfter returns the first time from next on, in steps of interval,
+// that's after now
+func nextAfter(next, interval, now int64) int64 {
+	if next > now {
+		return next
+	}
+	return next + ((now-next)/interval+1)*interval
+}
+
+func saveSeries(s *Series) error {
+	seriesData, _ := json.Marshal(s)
+	return serverToEngineClient.HSet(context.Background(), SCHEDULER_SERIES, s.Id, seriesData).Err()
+}
+
+// createMarket creates the next market of a series through the engine and
+// returns how to resolve it
+func createMarket(s Series) (resolution.Market, error) {
+	definition := s.Definition
+
+	seriesRule, err := rule.Parse(seriesRuleText(definition))
+	if err != nil {
+		return resolution.Market{}, err
+	}
+	// Thresholds relative to open, and the range of a scalar market given none,
+	// are fixed at the feed's value now
+	scalarRange := definition.Scalar
+	if seriesRule.NeedsOpen() || (scalarRange != nil && scalarRange.Lower == 0 && scalarRange.Upper == 0) {
+		open, err := oracle.Observe(definition.Oracle, seriesRule.Feed)
+		if err != nil {
+			return resolution.Market{}, fmt.Errorf("error creating market condition: %v", err)
+		}
+		seriesRule = seriesRule.Bind(open)
+		if scalarRange != nil && scalarRange.Lower == 0 && scalarRange.Upper == 0 {
+			scalarRange = &types.ScalarRange{Lower: open * 0.98, Upper: open * 1.02}
+		}
+	}
+
+	stockUniqueSymbol := fmt.Sprintf("%s-%d", definition.Symbol, time.Now().UnixMilli())
+
+	marketData := map[string]interface{}{
+		"symbol":        stockUniqueSymbol,
+		"heading":       definition.Heading, // the engine asks the rule if empty
+		"eventType":     definition.EventType,
+		"marketType":    definition.MarketType,
+		"sourceOfTruth": definition.SourceOfTruth,
+		"draft":         definition.Draft,
+		"endsIn":        definition.EndsIn,
+		"contract":      definition.Contract,
+		"scalar":        scalarRange,
+		"amm":           definition.AMM,
+		"lpSubsidy":     definition.LPSubsidy,
+		"oracle":        definition.Oracle,
+		"feed":          seriesRule.Feed,
+		"rule":          seriesRule.String(),
+	}
+	data, _ := json.Marshal(marketData)
+	msg := types.IncomingMessage{
+		Type: string(types.CREATE_MARKET),
+		Data: data,
+	}
+	msgBytes, _ := json.Marshal(msg)
+
+	// Wait for response
+	ch := make(chan string, 1)
+	sharedRedis.ServerAwaitsForResponseMap["create_market_"+stockUniqueSymbol] = ch
+	err = serverToEngineClient.LPush(context.Background(), types.HTTP_TO_ENGINE, msgBytes).Err()
+	if err != nil {
+		delete(sharedRedis.ServerAwaitsForResponseMap, "create_market_"+stockUniqueSymbol)
+		return resolution.Market{}, fmt.Errorf("error sending create market message: %v", err)
+	}
+	var response string
+	select {
+	case response = <-ch:
+	case <-time.After(createTimeout):
+		delete(sharedRedis.ServerAwaitsForResponseMap, "create_market_"+stockUniqueSymbol)
+		return resolution.Market{}, fmt.Errorf("engine didn't answer creating %s", stockUniqueSymbol)
+	}
+
+	var resp types.IncomingMessage
+	json.Unmarshal([]byte(response), &resp)
+	var respData map[string]map[string]string
+	json.Unmarshal(resp.Data, &respData)
+	if created := respData[stockUniqueSymbol]; created["error"] != "" {
+		return resolution.Market{}, fmt.Errorf("engine refused %s: %s", stockUniqueSymbol, created["error"])
+	}
+
+	return resolution.Market{
+		StockSymbol: stockUniqueSymbol,
+		MarketId:    stockUniqueSymbol,
+		Oracle:      definition.Oracle,
+		Feed:        seriesRule.Feed,
+		Rule:        seriesRule.String(),
+		Scalar:      scalarRange,
+		EndsAt:      time.Now().UnixMilli() + definition.EndsIn,
+	}, nil
+}
+
+// seriesRuleText returns the rule of a series, or for one without the old
+// default: a scalar market settles on the price, and a YES/NO market asks
+// whether the price ends above a threshold 2% above or below where it opened
+func seriesRuleText(definition types.CreateMarket) string {
+	if definition.Rule != "" {
+		return definition.Rule
+	}
+	if definition.Scalar != nil {
+		return fmt.Sprintf("price(%s)", definition.Symbol)
+	}
+	// Random direction: +2% or -2%
+	if rand.Float64() > 0.5 {
+		return fmt.Sprintf("price(%s) > open-2%%", definition.Symbol)
+	}
+	return fmt.Sprintf("price(%s) > open+2%%", definition.Symbol)
+}
@@ -2,10 +2,12 @@ module github.com/adityadeshlahre/probo-v1/shared
 
 go 1.25.1
 
-require github.com/redis/go-redis/v9 v9.14.0
+require (
+	github.com/joho/godotenv v1.5.1
+	github.com/redis/go-redis/v9 v9.14.0
+)
 
 require (
 	github.com/cespare/xxhash/v2 v2.3.0 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
-	github.com/joho/godotenv v1.5.1 // indirect
 )
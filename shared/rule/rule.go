@@ -0,0 +1,342 @@
+package rule
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// A Rule is how an automatic market resolves, written as e.g.
+//
+//	price(bitcoin) > 105000 at close
+//	twap(ethereum, 5m) between(3900, 4100)
+//	price(bitcoin) >= open+2%
+//
+// The value is the feed's price at close, or its time weighted average over
+// the window before close. A YES/NO market resolves YES if the value passes
+// the comparison. A scalar market's rule has no comparison and settles on the
+// value itself. Thresholds relative to open are fixed when the market is
+// created, see Bind.
+type Rule struct {
+	Feed   string
+	Window time.Duration // averaging window of a twap, 0 for the price at close
+	Op     string        // ">", ">=", "<", "<=", "between" or "" for a scalar value
+	Bounds []Threshold   // one, or two for between
+}
+
+// Threshold is a number, or a change from the feed's value when the market opened
+type Threshold struct {
+	Value   float64
+	Open    bool // Value is added to the open price
+	Percent bool // Value is a percentage of the open price
+}
+
+// Parse reads a rule
+func Parse(text string) (Rule, error) {
+	p := &parser{tokens: tokenize(text)}
+	r, err := p.rule()
+	if err != nil {
+		return Rule{}, fmt.Errorf("invalid rule %q: %v", text, err)
+	}
+	return r, nil
+}
+
+// NeedsOpen reports whether a threshold is relative to the open price
+func (r Rule) NeedsOpen() bool {
+	for _, bound := range r.Bounds {
+		if bound.Open {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValue reports whether the rule is the value of a scalar market rather
+// than a YES/NO condition
+func (r Rule) IsValue() bool {
+	return r.Op == ""
+}
+
+// Bind fixes the thresholds relative to open at the feed's open price
+func (r Rule) Bind(open float64) Rule {
+	bounds := make([]Threshold, len(r.Bounds))
+	for i, bound := range r.Bounds {
+		switch {
+		case bound.Open && bound.Percent:
+			bounds[i] = Threshold{Value: round(open * (1 + bound.Value/100))}
+		case bound.Open:
+			bounds[i] = Threshold{Value: round(open + bound.Value)}
+		default:
+			bounds[i] = bound
+		}
+	}
+	r.Bounds = bounds
+	return r
+}
+
+// Outcome returns whether a YES/NO market resolves YES on value
+func (r Rule) Outcome(value float64) (bool, error) {
+	if r.NeedsOpen() {
+		return false, fmt.Errorf("rule %s isn't bound to an open price", r)
+	}
+	switch r.Op {
+	case ">":
+		return value > r.Bounds[0].Value, nil
+	case ">=":
+		return value >= r.Bounds[0].Value, nil
+	case "<":
+		return value < r.Bounds[0].Value, nil
+	case "<=":
+		return value <= r.Bounds[0].Value, nil
+	case "between":
+		return value >= r.Bounds[0].Value && value <= r.Bounds[1].Value, nil
+	}
+	return false, fmt.Errorf("rule %s has no condition, it's a scalar value", r)
+}
+
+// String writes the rule back in its canonical form
+func (r Rule) String() string {
+	text := r.value()
+	switch r.Op {
+	case "":
+	case "between":
+		text += fmt.Sprintf(" between(%s, %s)", r.Bounds[0], r.Bounds[1])
+	default:
+		text += fmt.Sprintf(" %s %s", r.Op, r.Bounds[0])
+	}
+	return text + " at close"
+}
+
+// Heading is the question the rule asks, for markets created without one
+func (r Rule) Heading() string {
+	subject := r.Feed
+	if r.Window > 0 {
+		subject = fmt.Sprintf("%s's %s average", r.Feed, formatDuration(r.Window))
+	}
+	switch r.Op {
+	case "":
+		return fmt.Sprintf("Where will %s be at close?", subject)
+	case "between":
+		return fmt.Sprintf("Will %s be between %s and %s at close?", subject, r.Bounds[0], r.Bounds[1])
+	}
+	comparison := map[string]string{">": "above", ">=": "at or above", "<": "below", "<=": "at or below"}[r.Op]
+	return fmt.Sprintf("Will %s be %s %s at close?", subject, comparison, r.Bounds[0])
+}
+
+func (r Rule) value() string {
+	if r.Window > 0 {
+		return fmt.Sprintf("twap(%s, %s)", r.Feed, formatDuration(r.Window))
+	}
+	return fmt.Sprintf("price(%s)", r.Feed)
+}
+
+func (t Threshold) String() string {
+	if !t.Open {
+		return formatNumber(t.Value)
+	}
+	text := "open"
+	if t.Value != 0 {
+		text += fmt.Sprintf("%+g", t.Value)
+	}
+	if t.Percent {
+		text += "%"
+	}
+	return text
+}
+
+// round keeps 10 significant digits, so bound thresholds read the same
+// wherever they're printed
+func round(value float64) float64 {
+	rounded, _ := strconv.ParseFloat(strconv.FormatFloat(value, 'g', 10, 64), 64)
+	return rounded
+}
+
+func formatNumber(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func formatDuration(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+	return fmt.Sprintf("%ds", d/time.Second)
+}
+
+// tokenize splits a rule into names, numbers (with a unit such as "5m" or
+// not) and operators
+func tokenize(text string) []string {
+	var tokens []string
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsLetter(c) || unicode.IsDigit(c) || c == '.' || c == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || strings.ContainsRune("._-", runes[i])) {
+				// a minus is part of a name like usd-coin, not of 2-1 or open-2
+				if runes[i] == '-' && (unicode.IsDigit(runes[start]) || i+1 == len(runes) || !unicode.IsLetter(runes[i+1])) {
+					break
+				}
+				i++
+			}
+			tokens = append(tokens, string(runes[start:i]))
+		case (c == '>' || c == '<') && i+1 < len(runes) && runes[i+1] == '=':
+			tokens = append(tokens, string(runes[i:i+2]))
+			i += 2
+		default:
+			tokens = append(tokens, string(c))
+			i++
+		}
+	}
+	return tokens
+}
+
+type parser struct {
+	tokens []string
+	next   int
+}
+
+func (p *parser) peek() string {
+	if p.next < len(p.tokens) {
+		return p.tokens[p.next]
+	}
+	return ""
+}
+
+func (p *parser) take() string {
+	token := p.peek()
+	p.next++
+	return token
+}
+
+func (p *parser) expect(token string) error {
+	if got := p.take(); got != token {
+		if got == "" {
+			return fmt.Errorf("expected %q at the end", token)
+		}
+		return fmt.Errorf("expected %q, got %q", token, got)
+	}
+	return nil
+}
+
+func (p *parser) rule() (Rule, error) {
+	var r Rule
+	switch kind := strings.ToLower(p.take()); kind {
+	case "price", "twap":
+		if err := p.expect("("); err != nil {
+			return r, err
+		}
+		r.Feed = p.take()
+		if r.Feed == "" || !unicode.IsLetter([]rune(r.Feed)[0]) {
+			return r, fmt.Errorf("expected a feed, got %q", r.Feed)
+		}
+		if kind == "twap" {
+			if err := p.expect(","); err != nil {
+				return r, err
+			}
+			window, err := time.ParseDuration(p.take())
+			if err != nil || window < time.Second || window%time.Second != 0 {
+				return r, fmt.Errorf("a twap window has to be a whole number of seconds such as 90s or 5m")
+			}
+			r.Window = window
+		}
+		if err := p.expect(")"); err != nil {
+			return r, err
+		}
+	default:
+		return r, fmt.Errorf("expected price(feed) or twap(feed, window), got %q", kind)
+	}
+
+	switch op := strings.ToLower(p.peek()); op {
+	case ">", ">=", "<", "<=":
+		p.take()
+		bound, err := p.threshold()
+		if err != nil {
+			return r, err
+		}
+		r.Op, r.Bounds = op, []Threshold{bound}
+	case "between":
+		p.take()
+		if err := p.expect("("); err != nil {
+			return r, err
+		}
+		lower, err := p.threshold()
+		if err != nil {
+			return r, err
+		}
+		if err := p.expect(","); err != nil {
+			return r, err
+		}
+		upper, err := p.threshold()
+		if err != nil {
+			return r, err
+		}
+		if err := p.expect(")"); err != nil {
+			return r, err
+		}
+		if !lower.Open && !upper.Open && lower.Value > upper.Value {
+			return r, fmt.Errorf("between(%s, %s) is empty", lower, upper)
+		}
+		r.Op, r.Bounds = op, []Threshold{lower, upper}
+	}
+
+	if strings.ToLower(p.peek()) == "at" {
+		p.take()
+		if close := p.take(); strings.ToLower(close) != "close" {
+			return r, fmt.Errorf("rules are evaluated at close, got at %q", close)
+		}
+	}
+	if p.peek() != "" {
+		return r, fmt.Errorf("unexpected %q", p.peek())
+	}
+	return r, nil
+}
+
+func (p *parser) threshold() (Threshold, error) {
+	if strings.ToLower(p.peek()) != "open" {
+		return p.number()
+	}
+	p.take()
+	sign := p.peek()
+	if sign != "+" && sign != "-" {
+		return Threshold{Open: true}, nil
+	}
+	p.take()
+	change, err := p.number()
+	if err != nil {
+		return Threshold{}, err
+	}
+	change.Open = true
+	if sign == "-" {
+		change.Value = -change.Value
+	}
+	if p.peek() == "%" {
+		p.take()
+		change.Percent = true
+	}
+	return change, nil
+}
+
+func (p *parser) number() (Threshold, error) {
+	negative := p.peek() == "-"
+	if negative {
+		p.take()
+	}
+	token := p.take()
+	value, err := strconv.ParseFloat(token, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return Threshold{}, fmt.Errorf("expected a number, got %q", token)
+	}
+	if negative {
+		value = -value
+	}
+	return Threshold{Value: value}, nil
+}
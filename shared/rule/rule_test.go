@@ -0,0 +1,143 @@
+package rule
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	for _, test := range []struct {
+		text string
+		want string // canonical form
+	}{
+		{"price(bitcoin) > 105000 at close", "price(bitcoin) > 105000 at close"},
+		{"PRICE(bitcoin)>105000", "price(bitcoin) > 105000 at close"},
+		{"twap(ethereum, 5m) between(3900, 4100)", "twap(ethereum, 5m) between(3900, 4100) at close"},
+		{"twap(ethereum, 90s) <= 4000.5", "twap(ethereum, 90s) <= 4000.5 at close"},
+		{"price(usd-coin) >= open+2%", "price(usd-coin) >= open+2% at close"},
+		{"price(bitcoin) < open-500", "price(bitcoin) < open-500 at close"},
+		{"price(bitcoin) > -5", "price(bitcoin) > -5 at close"},
+		{"price(bitcoin)", "price(bitcoin) at close"},
+	} {
+		r, err := Parse(test.text)
+		if err != nil {
+			t.Errorf("%q: %v", test.text, err)
+			continue
+		}
+		if got := r.String(); got != test.want {
+			t.Errorf("%q reads as %q, want %q", test.text, got, test.want)
+		}
+		// The canonical form reads as the same rule
+		again, err := Parse(r.String())
+		if err != nil || again.String() != r.String() {
+			t.Errorf("%q doesn't round trip: %v %v", r.String(), again, err)
+		}
+	}
+}
+
+// The sign and the percent bind to the change from open, not to the whole
+// threshold, and a minus between letters is part of the feed's name
+func TestPrecedence(t *testing.T) {
+	r, err := Parse("price(usd-coin) >= open-2%")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Feed != "usd-coin" {
+		t.Errorf("feed is %q, want usd-coin", r.Feed)
+	}
+	if bound := r.Bounds[0]; bound != (Threshold{Value: -2, Open: true, Percent: true}) {
+		t.Errorf("threshold is %+v, want open minus 2%%", bound)
+	}
+	if got := r.Bind(200).Bounds[0].Value; got != 196 {
+		t.Errorf("open-2%% of 200 is %v, want 196", got)
+	}
+	if got := mustParse(t, "price(bitcoin) < open-2").Bind(200).Bounds[0].Value; got != 198 {
+		t.Errorf("open-2 of 200 is %v, want 198", got)
+	}
+
+	r = mustParse(t, "twap(bitcoin, 1h) between(open-1%, open+1%)")
+	if r.Window != time.Hour || r.Op != "between" || len(r.Bounds) != 2 {
+		t.Fatalf("parsed %+v", r)
+	}
+	bound := r.Bind(1000)
+	if bound.Bounds[0].Value != 990 || bound.Bounds[1].Value != 1010 {
+		t.Errorf("bound to 1000 the range is %v, want 990 to 1010", bound.Bounds)
+	}
+	if bound.NeedsOpen() {
+		t.Error("bound rule still needs an open price")
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	for _, test := range []struct {
+		text string
+		want string
+	}{
+		{"", "expected price(feed) or twap(feed, window)"},
+		{"bitcoin > 5", "expected price(feed) or twap(feed, window)"},
+		{"price(bitcoin > 5", `expected ")"`},
+		{"price() > 5", "expected a feed"},
+		{"price(5) > 5", "expected a feed"},
+		{"twap(bitcoin) > 5", `expected ","`},
+		{"twap(bitcoin, 500ms) > 5", "whole number of seconds"},
+		{"twap(bitcoin, soon) > 5", "whole number of seconds"},
+		{"price(bitcoin) > lots", "expected a number"},
+		{"price(bitcoin) > NaN", "expected a number"},
+		{"price(bitcoin) between(10, 5)", "is empty"},
+		{"price(bitcoin) between(5 10)", `expected ","`},
+		{"price(bitcoin) > 5 at open", "evaluated at close"},
+		{"price(bitcoin) > 5 or more", `unexpected "or"`},
+		{"price(bitcoin) = 5", `unexpected "="`},
+	} {
+		_, err := Parse(test.text)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%q: got %v, want an error with %q", test.text, err, test.want)
+		}
+	}
+}
+
+func TestOutcome(t *testing.T) {
+	for _, test := range []struct {
+		text  string
+		value float64
+		want  bool
+	}{
+		{"price(bitcoin) > 100", 100.01, true},
+		{"price(bitcoin) > 100", 100, false},
+		{"price(bitcoin) >= 100", 100, true},
+		{"price(bitcoin) >= 100", 99.99, false},
+		{"price(bitcoin) < 100", 99.99, true},
+		{"price(bitcoin) < 100", 100, false},
+		{"price(bitcoin) <= 100", 100, true},
+		{"price(bitcoin) <= 100", 100.01, false},
+		{"price(bitcoin) between(90, 110)", 90, true},
+		{"price(bitcoin) between(90, 110)", 110, true},
+		{"price(bitcoin) between(90, 110)", 89.99, false},
+		{"price(bitcoin) between(90, 110)", 110.01, false},
+	} {
+		yes, err := mustParse(t, test.text).Outcome(test.value)
+		if err != nil {
+			t.Errorf("%q on %v: %v", test.text, test.value, err)
+		} else if yes != test.want {
+			t.Errorf("%q on %v is %v, want %v", test.text, test.value, yes, test.want)
+		}
+	}
+
+	if _, err := mustParse(t, "price(bitcoin) > open+1%").Outcome(5); err == nil {
+		t.Error("rule relative to open resolved without being bound")
+	}
+	if _, err := mustParse(t, "price(bitcoin)").Outcome(5); err == nil {
+		t.Error("scalar rule resolved to YES or NO")
+	}
+}
+
+// mustParse parses a rule the test can't go on without
+func mustParse(t *testing.T, text string) Rule {
+	t.Helper()
+	r, err := Parse(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
@@ -0,0 +1,46 @@
+package types
+
+import "fmt"
+
+// AMMConfig asks for an LMSR market maker on a market. Give either or both:
+// the liquidity b sets how far prices move per share traded, the subsidy is
+// what the AMM may lose. It can lose at most b × ln 2 × payout, so a missing
+// one is worked out from the other.
+type AMMConfig struct {
+	Liquidity float64 `json:"b"`       // in shares
+	Subsidy   Amount  `json:"subsidy"` // USD the market maker is funded with
+}
+
+// Validate checks that a config makes sense
+func (c AMMConfig) Validate() error {
+	if c.Liquidity < 0 || c.Subsidy < 0 {
+		return fmt.Errorf("the AMM's b and subsidy can't be negative")
+	}
+	if c.Liquidity == 0 && c.Subsidy == 0 {
+		return fmt.Errorf("the AMM needs a b or a subsidy")
+	}
+	return nil
+}
+
+// AMM is the LMSR market maker of a market. It trades from an account of its
+// own, funded with the subsidy, and quotes from the shares it has sold.
+type AMM struct {
+	Account string  `json:"account"`
+	B       float64 `json:"b"`
+	Subsidy Amount  `json:"subsidy"`
+	Yes     Shares  `json:"yes"` // YES shares sold net of those bought back, can go negative
+	No      Shares  `json:"no"`
+}
+
+// AMMAccount is the account the AMM of a symbol trades from
+func AMMAccount(symbol string) string {
+	return "amm:" + symbol
+}
+
+// AMMQuoteProps asks what trading quantity shares with a market's AMM costs
+type AMMQuoteProps struct {
+	StockSymbol string `json:"stockSymbol"`
+	StockType   string `json:"stockType"`
+	Side        string `json:"side"` // "buy" or "sell"
+	Quantity    Shares `json:"quantity"`
+}
@@ -0,0 +1,161 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Amount is an amount of USD in cents. Balances, locked funds and prices
+// (USD per share) are all amounts, so they add up exactly no matter how many
+// fills they go through.
+//
+// Amounts are encoded in JSON as decimal strings with two places ("60.50").
+// Plain JSON numbers (60.5) are still accepted when decoding, for clients
+// written against the old float64 fields.
+type Amount int64
+
+// Shares is a whole number of shares
+//
+// Shares are encoded in JSON as integers. Integral floats (2.0) and numeric
+// strings ("2") are accepted when decoding, for clients written against the
+// old float64 fields.
+type Shares int64
+
+const (
+	Cent Amount = 1
+	USD  Amount = 100 * Cent
+
+	// MaxPrice is the default payout of a winning share, and so the highest
+	// price of a share and what a YES and a NO share of a market together
+	// are worth. Markets can set their own payout in their ContractSpec.
+	MaxPrice Amount = 100 * USD
+
+	// MaxShares is the most shares an order, split or merge can be for, so
+	// what it costs always fits in an Amount with room to add fees
+	MaxShares Shares = 1_000_000_000
+)
+
+// Times returns the amount for quantity shares at a price of a per share. A
+// product too large for an Amount comes out as the largest (or smallest)
+// Amount there is, which no balance covers, instead of wrapping around.
+func (a Amount) Times(quantity Shares) Amount {
+	product := a * Amount(quantity)
+	if quantity != 0 && (product/Amount(quantity) != a || (quantity == -1 && a == math.MinInt64)) {
+		if (a < 0) != (quantity < 0) {
+			return math.MinInt64
+		}
+		return math.MaxInt64
+	}
+	return product
+}
+
+// CheckShares returns an error unless quantity is a whole number of shares
+// an order, split or merge can be for
+func CheckShares(quantity Shares) error {
+	if quantity <= 0 || quantity > MaxShares {
+		return fmt.Errorf("quantity should be between 1 and %d", MaxShares)
+	}
+	return nil
+}
+
+// String formats the amount as USD with two decimals
+func (a Amount) String() string {
+	sign := ""
+	cents := int64(a)
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/int64(USD), cents%int64(USD))
+}
+
+// ParseAmount parses a USD amount such as "60", "60.5" or "-0.25". More than
+// two decimal places is an error rather than being rounded away.
+func ParseAmount(s string) (Amount, error) {
+	value, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	cents := value.Mul(value, big.NewRat(int64(USD), 1))
+	if !cents.IsInt() {
+		return 0, fmt.Errorf("invalid amount %q, at most 2 decimal places are allowed", s)
+	}
+	if !cents.Num().IsInt64() {
+		return 0, fmt.Errorf("amount %q is out of range", s)
+	}
+	return Amount(cents.Num().Int64()), nil
+}
+
+func (a Amount) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+func (a *Amount) UnmarshalText(text []byte) error {
+	parsed, err := ParseAmount(string(text))
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var text string
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+		return a.UnmarshalText([]byte(text))
+	}
+	// Legacy float encoding, parsed from its literal text so nothing is lost
+	return a.UnmarshalText(data)
+}
+
+// ParseShares parses a share count such as "3" or "3.0"
+func ParseShares(s string) (Shares, error) {
+	s = strings.TrimSpace(s)
+	if quantity, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return Shares(quantity), nil
+	}
+	value, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return 0, fmt.Errorf("invalid quantity %q", s)
+	}
+	if !value.IsInt() || !value.Num().IsInt64() {
+		return 0, fmt.Errorf("invalid quantity %q, quantities are whole shares", s)
+	}
+	return Shares(value.Num().Int64()), nil
+}
+
+func (q *Shares) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var text string
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+		data = []byte(text)
+	}
+	parsed, err := ParseShares(string(data))
+	if err != nil {
+		return err
+	}
+	*q = parsed
+	return nil
+}
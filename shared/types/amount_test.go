@@ -0,0 +1,44 @@
+package types
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTimes(t *testing.T) {
+	for _, test := range []struct {
+		price    Amount
+		quantity Shares
+		want     Amount
+	}{
+		{50 * USD, 3, 150 * USD},
+		{50 * USD, 0, 0},
+		{0, 3, 0},
+		{-2 * USD, 3, -6 * USD},
+		{MaxPayout, MaxShares, 100_000_000_000_000_000},
+		// 5000 × 2767011611056433 wraps around to a negative int64
+		{50 * USD, 2767011611056433, math.MaxInt64},
+		{50 * USD, -2767011611056433, math.MinInt64},
+		{-50 * USD, 2767011611056433, math.MinInt64},
+		{-50 * USD, -2767011611056433, math.MaxInt64},
+		{math.MinInt64, -1, math.MaxInt64},
+		{-1, math.MinInt64, math.MaxInt64},
+	} {
+		if got := test.price.Times(test.quantity); got != test.want {
+			t.Errorf("%d × %d is %d, want %d", test.price, test.quantity, got, test.want)
+		}
+	}
+}
+
+func TestCheckShares(t *testing.T) {
+	for _, quantity := range []Shares{1, MaxShares} {
+		if err := CheckShares(quantity); err != nil {
+			t.Errorf("%d: %v", quantity, err)
+		}
+	}
+	for _, quantity := range []Shares{0, -1, MaxShares + 1, math.MaxInt64} {
+		if err := CheckShares(quantity); err == nil {
+			t.Errorf("%d shares were accepted", quantity)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package types
+
+import "fmt"
+
+// ContractSpec is what a share of a market pays and how it may be traded.
+// Zero fields take their defaults, which are the rules every market had
+// before specs existed.
+type ContractSpec struct {
+	Payout      Amount `json:"payout"`                // what a winning share pays, and what a YES+NO pair is worth
+	TickSize    Amount `json:"tickSize"`              // prices have to be a multiple of this
+	MinQuantity Shares `json:"minQuantity"`           // smallest order, the lot size
+	MaxQuantity Shares `json:"maxQuantity,omitempty"` // largest order, 0 for no limit
+	MaxPosition Shares `json:"maxPosition,omitempty"` // most YES or NO shares one user may hold, 0 for no limit
+	FeeSchedule string `json:"feeSchedule,omitempty"` // id of the fee schedule that applies
+}
+
+// MaxPayout is the highest payout a contract can have, so MaxShares of it
+// fit in an Amount
+const MaxPayout = 1_000_000 * USD
+
+// WithDefaults fills in the zero fields of a spec
+func (c ContractSpec) WithDefaults() ContractSpec {
+	if c.Payout == 0 {
+		c.Payout = MaxPrice
+	}
+	if c.TickSize == 0 {
+		c.TickSize = Cent
+	}
+	if c.MinQuantity == 0 {
+		c.MinQuantity = 1
+	}
+	return c
+}
+
+// Validate checks that a spec, with its defaults filled in, makes sense
+func (c ContractSpec) Validate() error {
+	c = c.WithDefaults()
+	if c.Payout < 0 || c.TickSize < 0 || c.MinQuantity < 0 || c.MaxQuantity < 0 || c.MaxPosition < 0 {
+		return fmt.Errorf("contract spec values can't be negative")
+	}
+	if c.Payout > MaxPayout {
+		return fmt.Errorf("payout %s is over the limit of %s", c.Payout, MaxPayout)
+	}
+	if c.Payout%c.TickSize != 0 {
+		return fmt.Errorf("payout %s isn't a multiple of the tick size %s", c.Payout, c.TickSize)
+	}
+	if c.MaxQuantity != 0 && c.MaxQuantity < c.MinQuantity {
+		return fmt.Errorf("max quantity %d is below the min quantity %d", c.MaxQuantity, c.MinQuantity)
+	}
+	if c.MaxPosition != 0 && c.MaxPosition < c.MinQuantity {
+		return fmt.Errorf("max position %d is below the min quantity %d", c.MaxPosition, c.MinQuantity)
+	}
+	return nil
+}
+
+// CheckPrice returns an error unless price is on the tick and between 0 and the payout
+func (c ContractSpec) CheckPrice(price Amount) error {
+	if price < 0 || price > c.Payout {
+		return fmt.Errorf("invalid price, price should be between 0 and %s", c.Payout)
+	}
+	if price%c.TickSize != 0 {
+		return fmt.Errorf("invalid price %s, prices move in ticks of %s", price, c.TickSize)
+	}
+	return nil
+}
+
+// CheckQuantity returns an error unless an order for quantity shares is
+// within the order size limits
+func (c ContractSpec) CheckQuantity(quantity Shares) error {
+	if err := CheckShares(quantity); err != nil {
+		return err
+	}
+	if quantity < c.MinQuantity {
+		return fmt.Errorf("quantity should be at least %d", c.MinQuantity)
+	}
+	if c.MaxQuantity != 0 && quantity > c.MaxQuantity {
+		return fmt.Errorf("quantity should be at most %d", c.MaxQuantity)
+	}
+	return nil
+}
+
+// CheckPosition returns an error if holding held shares of one side would go
+// over the position limit
+func (c ContractSpec) CheckPosition(held Shares) error {
+	if c.MaxPosition != 0 && held > c.MaxPosition {
+		return fmt.Errorf("position of %d shares would go over the limit of %d", held, c.MaxPosition)
+	}
+	return nil
+}
+
+// Contract returns the contract spec of a market, with defaults filled in
+func (m Markets) Contract(stockSymbol string) ContractSpec {
+	return m[stockSymbol].Contract.WithDefaults()
+}
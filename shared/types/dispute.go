@@ -0,0 +1,124 @@
+package types
+
+import "slices"
+
+// A manual market is resolved by a proposal that stands unless disputed in
+// its dispute window, and a disputed one by a quorum of admins, or voided if
+// they don't reach one in time.
+
+type ResolutionStatus string
+
+const (
+	ResolutionProposed ResolutionStatus = "PROPOSED" // in its dispute window
+	ResolutionDisputed ResolutionStatus = "DISPUTED" // waiting for the admins
+	ResolutionFinal    ResolutionStatus = "FINAL"    // the market paid out on Decision
+	ResolutionExpired  ResolutionStatus = "EXPIRED"  // no quorum by DecideBy, the market was voided
+)
+
+// Resolution is the resolution workflow of a manual market, kept with it
+type Resolution struct {
+	Status        ResolutionStatus `json:"status"`
+	Proposal      Proposal         `json:"proposal"`
+	DisputeEndsAt int64            `json:"disputeEndsAt"`      // unix millis
+	DecideBy      int64            `json:"decideBy,omitempty"` // unix millis the admins have to decide a disputed market by
+	Disputes      []Dispute        `json:"disputes,omitempty"`
+	Votes         []Vote           `json:"votes,omitempty"`
+	Decision      *Decision        `json:"decision,omitempty"`
+}
+
+// Clone returns a copy of the resolution that shares nothing with it, nil
+// for none
+func (r *Resolution) Clone() *Resolution {
+	if r == nil {
+		return nil
+	}
+	copied := *r
+	copied.Proposal.Value = cloneValue(r.Proposal.Value)
+	copied.Disputes = slices.Clone(r.Disputes)
+	copied.Votes = slices.Clone(r.Votes)
+	for i := range copied.Votes {
+		copied.Votes[i].Value = cloneValue(copied.Votes[i].Value)
+	}
+	if r.Decision != nil {
+		decision := *r.Decision
+		decision.Value = cloneValue(decision.Value)
+		decision.Admins = slices.Clone(decision.Admins)
+		copied.Decision = &decision
+	}
+	return &copied
+}
+
+func cloneValue(value *float64) *float64 {
+	if value == nil {
+		return nil
+	}
+	copied := *value
+	return &copied
+}
+
+// Proposal is the outcome a resolver says a market had
+type Proposal struct {
+	UserId   string   `json:"userId"`
+	Outcome  string   `json:"outcome,omitempty"` // winning stock or outcome
+	Value    *float64 `json:"value,omitempty"`   // scalar markets
+	Evidence string   `json:"evidence"`
+	At       string   `json:"at"`
+}
+
+// Dispute is a user's challenge of a proposal, backed by a bond they lose if
+// the admins side with the proposal
+type Dispute struct {
+	UserId string `json:"userId"`
+	Reason string `json:"reason"`
+	Bond   Amount `json:"bond"`
+	At     string `json:"at"`
+}
+
+// Vote is an admin's ruling on a disputed market
+type Vote struct {
+	AdminId string   `json:"adminId"`
+	Outcome string   `json:"outcome,omitempty"`
+	Value   *float64 `json:"value,omitempty"`
+	At      string   `json:"at"`
+}
+
+// Decision is what a market was finally resolved as
+type Decision struct {
+	Outcome string   `json:"outcome,omitempty"`
+	Value   *float64 `json:"value,omitempty"`
+	By      string   `json:"by"`               // "undisputed" or "admins"
+	Admins  []string `json:"admins,omitempty"` // who made up the quorum
+	Upheld  bool     `json:"upheld"`           // whether it's what was proposed
+	At      string   `json:"at"`
+}
+
+// ProposeResolutionProps is a resolver's proposal of a market's outcome
+type ProposeResolutionProps struct {
+	StockSymbol  string   `json:"stockSymbol"`
+	UserId       string   `json:"userId"`
+	WinningStock string   `json:"winningStock"`
+	Value        *float64 `json:"value,omitempty"` // scalar markets
+	Evidence     string   `json:"evidence"`
+	ByAdmin      bool     `json:"byAdmin"` // set by the server when an admin's key signed the request
+}
+
+// DisputeResolutionProps is a user's challenge of a market's proposal
+type DisputeResolutionProps struct {
+	StockSymbol string `json:"stockSymbol"`
+	UserId      string `json:"userId"`
+	Reason      string `json:"reason"`
+}
+
+// VoteResolutionProps is an admin's ruling on a disputed market
+type VoteResolutionProps struct {
+	StockSymbol  string   `json:"stockSymbol"`
+	AdminId      string   `json:"adminId"` // set by the server from the admin's key
+	WinningStock string   `json:"winningStock"`
+	Value        *float64 `json:"value,omitempty"`
+}
+
+// MarketResolutionUpdate is a change to a market's resolution, sent to the database
+type MarketResolutionUpdate struct {
+	Symbol     string     `json:"symbol"`
+	Resolution Resolution `json:"resolution"`
+}
@@ -0,0 +1,109 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FeeAccount is the platform account every fee is paid into
+const FeeAccount = "fees"
+
+// FeeSchedule is what trading and settling a market costs. Trade fees are
+// basis points of the fill's value plus a fixed fee per contract, the maker
+// paying the maker rates and the taker the taker rates. The settlement fee
+// is basis points of what winning shares pay out.
+type FeeSchedule struct {
+	MakerBps         int64  `json:"makerBps"`
+	TakerBps         int64  `json:"takerBps"`
+	MakerPerContract Amount `json:"makerPerContract"`
+	TakerPerContract Amount `json:"takerPerContract"`
+	SettlementBps    int64  `json:"settlementBps"`
+}
+
+// FeeConfig is every fee schedule of the platform. A user in a tier pays the
+// tier's schedule, anyone else the schedule their market's contract names,
+// and the default without one.
+type FeeConfig struct {
+	Default   FeeSchedule            `json:"default"`
+	Schedules map[string]FeeSchedule `json:"schedules"` // by id, named by a market's ContractSpec.FeeSchedule
+	Tiers     map[string]FeeSchedule `json:"tiers"`     // by tier name
+	Users     map[string]string      `json:"users"`     // user id to tier name
+}
+
+// Validate checks that a schedule makes sense
+func (s FeeSchedule) Validate() error {
+	if s.MakerBps < 0 || s.TakerBps < 0 || s.MakerPerContract < 0 || s.TakerPerContract < 0 || s.SettlementBps < 0 {
+		return fmt.Errorf("fees can't be negative")
+	}
+	if s.MakerBps > 10000 || s.TakerBps > 10000 || s.SettlementBps > 10000 {
+		return fmt.Errorf("fees can't be over 10000 bps")
+	}
+	return nil
+}
+
+// Validate checks every schedule of a config and that every user's tier exists
+func (c FeeConfig) Validate() error {
+	if err := c.Default.Validate(); err != nil {
+		return fmt.Errorf("default: %v", err)
+	}
+	for id, schedule := range c.Schedules {
+		if err := schedule.Validate(); err != nil {
+			return fmt.Errorf("schedule %s: %v", id, err)
+		}
+	}
+	for tier, schedule := range c.Tiers {
+		if err := schedule.Validate(); err != nil {
+			return fmt.Errorf("tier %s: %v", tier, err)
+		}
+	}
+	for userId, tier := range c.Users {
+		if _, exists := c.Tiers[tier]; !exists {
+			return fmt.Errorf("user %s is in tier %s, which doesn't exist", userId, tier)
+		}
+	}
+	return nil
+}
+
+// TradeFee is what the maker (or taker) of a fill of quantity shares worth
+// value pays. Basis points round up to the cent, and a fee is never
+// negative or more than the fill is worth.
+func (s FeeSchedule) TradeFee(maker bool, value Amount, quantity Shares) Amount {
+	if value <= 0 || quantity <= 0 {
+		return 0
+	}
+	bps, perContract := s.TakerBps, s.TakerPerContract
+	if maker {
+		bps, perContract = s.MakerBps, s.MakerPerContract
+	}
+	return max(min(bpsOf(value, bps)+perContract.Times(quantity), value), 0)
+}
+
+// ReservedFeeShare is the part of the maker fee reserved with a resting order
+// of remaining shares that a fill of quantity of them takes: its share rounded
+// down, and all that's left once the order fills completely
+func ReservedFeeShare(reserved Amount, quantity, remaining Shares) Amount {
+	if quantity >= remaining {
+		return reserved
+	}
+	return reserved.Times(quantity) / Amount(remaining)
+}
+
+// SettlementFee is what winnings pay when a market settles
+func (s FeeSchedule) SettlementFee(winnings Amount) Amount {
+	return bpsOf(winnings, s.SettlementBps)
+}
+
+// bpsOf is bps basis points of an amount, rounded up to the cent
+func bpsOf(amount Amount, bps int64) Amount {
+	if amount <= 0 || bps == 0 {
+		return 0
+	}
+	return (amount*Amount(bps) + 9999) / 10000
+}
+
+// PlatformAccount reports whether an account is the platform's own: the
+// treasury, the fee account and the market makers' accounts, which pay no fees
+func PlatformAccount(userId string) bool {
+	return userId == TreasuryAccount || userId == FeeAccount ||
+		strings.HasPrefix(userId, LPAccount("")) || strings.HasPrefix(userId, AMMAccount(""))
+}
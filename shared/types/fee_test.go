@@ -0,0 +1,52 @@
+package types
+
+import "testing"
+
+func TestBpsOf(t *testing.T) {
+	for _, test := range []struct {
+		amount Amount
+		bps    int64
+		want   Amount
+	}{
+		{100 * USD, 100, 1 * USD},
+		{100 * USD, 0, 0},
+		{0, 100, 0},
+		{-100 * USD, 100, 0},
+		{1 * Cent, 1, 1 * Cent},    // a ten thousandth of a cent rounds up
+		{9999 * Cent, 1, 1 * Cent}, // so does 0.9999 of a cent
+		{10000 * Cent, 1, 1 * Cent},
+		{10001 * Cent, 1, 2 * Cent},
+		{60 * USD, 10000, 60 * USD},
+	} {
+		if got := bpsOf(test.amount, test.bps); got != test.want {
+			t.Errorf("%d bps of %s is %s, want %s", test.bps, test.amount, got, test.want)
+		}
+	}
+}
+
+func TestTradeFee(t *testing.T) {
+	schedule := FeeSchedule{MakerBps: 10, TakerBps: 50, MakerPerContract: 0, TakerPerContract: 2 * Cent}
+	for _, test := range []struct {
+		name     string
+		schedule FeeSchedule
+		maker    bool
+		value    Amount
+		quantity Shares
+		want     Amount
+	}{
+		{"taker pays bps and per contract", schedule, false, 600 * USD, 10, 3*USD + 20*Cent},
+		{"maker pays the maker rates", schedule, true, 600 * USD, 10, 60 * Cent},
+		{"bps round up", schedule, true, 1 * USD, 1, 1 * Cent},
+		{"no fees", FeeSchedule{}, false, 600 * USD, 10, 0},
+		{"never more than the fill", FeeSchedule{TakerPerContract: 1 * USD}, false, 50 * Cent, 1, 50 * Cent},
+		{"per contract alone", FeeSchedule{MakerPerContract: 1 * Cent}, true, 90 * USD, 3, 3 * Cent},
+		{"nothing on a negative value", schedule, false, -600 * USD, 10, 0},
+		{"nothing on a negative quantity", schedule, false, 600 * USD, -10, 0},
+		{"nothing on nothing", schedule, false, 0, 10, 0},
+		{"the largest order", FeeSchedule{TakerPerContract: MaxPayout}, false, MaxPayout.Times(MaxShares), MaxShares, MaxPayout.Times(MaxShares)},
+	} {
+		if got := test.schedule.TradeFee(test.maker, test.value, test.quantity); got != test.want {
+			t.Errorf("%s: fee on %d worth %s is %s, want %s", test.name, test.quantity, test.value, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package types
+
+// Every market maker trades from an account of its own, funded from the
+// treasury when its market is created and paid back into it once the market
+// has settled or been voided. What comes back less what went out is the
+// market maker's P&L in that market.
+
+// TreasuryAccount is the account market makers are funded from
+const TreasuryAccount = "treasury"
+
+// LPAccount is the account the market maker posting sell ladders in a
+// symbol trades from
+func LPAccount(symbol string) string {
+	return "lp:" + symbol
+}
+
+// LiquidityProvider is the funding of a symbol's market maker
+type LiquidityProvider struct {
+	Account    string `json:"account"`
+	Kind       string `json:"kind"`                 // "book" for the sell ladders, "amm" for an LMSR market maker
+	Subsidy    Amount `json:"subsidy"`              // USD taken from the treasury
+	Returned   Amount `json:"returned"`             // USD paid back into the treasury, once defunded
+	PnL        Amount `json:"pnl"`                  // returned - subsidy, once defunded
+	FundedAt   string `json:"fundedAt"`             // RFC3339
+	DefundedAt string `json:"defundedAt,omitempty"` // RFC3339, empty while its market is trading
+}
@@ -0,0 +1,12 @@
+package types
+
+// Observation is one value an oracle reported for a feed (e.g. "bitcoin"),
+// kept so every automatic market's creation and resolution can be audited
+type Observation struct {
+	Source     string  `json:"source"` // name of the oracle
+	Feed       string  `json:"feed"`
+	Value      float64 `json:"value"`
+	Error      string  `json:"error,omitempty"`      // set, with no value, when the read failed
+	ReportedAt string  `json:"reportedAt,omitempty"` // when the source last updated the value
+	ObservedAt string  `json:"observedAt"`           // when it was read
+}
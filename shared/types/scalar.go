@@ -0,0 +1,57 @@
+package types
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// ScalarRange is the range a scalar market's value settles in. Its LONG
+// shares trade as the YES side of the book and pay more the higher the value
+// lands; its SHORT shares trade as the NO side and pay the rest.
+type ScalarRange struct {
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+}
+
+// Validate checks that the range isn't empty
+func (r ScalarRange) Validate() error {
+	if math.IsNaN(r.Lower) || math.IsNaN(r.Upper) || math.IsInf(r.Lower, 0) || math.IsInf(r.Upper, 0) {
+		return fmt.Errorf("scalar bounds have to be numbers")
+	}
+	if r.Lower >= r.Upper {
+		return fmt.Errorf("scalar lower bound %v has to be below the upper bound %v", r.Lower, r.Upper)
+	}
+	return nil
+}
+
+// LongPayout returns what a LONG share pays when the value lands at value:
+// nothing at or below the lower bound, the whole payout at or above the upper
+// one and pro rata in between, to the cent. A SHORT share pays the payout
+// minus that, so a LONG and SHORT pair always pays the payout.
+func (r ScalarRange) LongPayout(value float64, payout Amount) Amount {
+	fraction := (value - r.Lower) / (r.Upper - r.Lower)
+	fraction = min(max(fraction, 0), 1)
+	return Amount(math.Round(fraction * float64(payout)))
+}
+
+// StockType returns the book side a stock type of a market trades on, "yes"
+// or "no". Scalar markets also take "long" for yes and "short" for no.
+func (m Markets) StockType(stockSymbol, stockType string) (string, error) {
+	stockType = strings.ToLower(stockType)
+	switch stockType {
+	case "yes", "no":
+		return stockType, nil
+	case "long", "short":
+		if m[stockSymbol].Scalar != nil {
+			if stockType == "long" {
+				return "yes", nil
+			}
+			return "no", nil
+		}
+	}
+	if m[stockSymbol].Scalar != nil {
+		return "", fmt.Errorf("invalid stock type %q, expected long or short", stockType)
+	}
+	return "", fmt.Errorf("invalid stock type %q, expected yes or no", stockType)
+}
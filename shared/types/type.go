@@ -3,6 +3,7 @@ package types
 import (
 	"encoding/json"
 	"fmt"
+	"slices"
 )
 
 type IncomingMessage struct {
@@ -22,6 +23,9 @@ const (
 	GET_BALANCE  = "GET_BALANCE"
 	CANCEL_ORDER = "CANCEL_ORDER"
 	UPDATE_ORDER = "UPDATE_ORDER"
+	OBSERVATION  = "OBSERVATION"
+
+	MARKET_RESOLUTION = "MARKET_RESOLUTION"
 )
 
 // redis related constants
@@ -38,6 +42,16 @@ const (
 	DB_RESPONSES           = "DB_RESPONSES"
 )
 
+// TradesChannel is the pub/sub channel every fill of a symbol is published on
+func TradesChannel(symbol string) string {
+	return "trades:" + symbol
+}
+
+// SettlementsChannel is the pub/sub channel a symbol's settlement is published on
+func SettlementsChannel(symbol string) string {
+	return "settlements:" + symbol
+}
+
 // action types
 
 const (
@@ -53,15 +67,24 @@ const (
 	CREATE_USER        = "CREATE_USER"
 	CREATE_MARKET      = "CREATE_MARKET"
 	ONRAMP_USD         = "ONRAMP_USD"
-	CANCLE_ORDER       = "CANCLE_ORDER"
+	CANCLE_ORDER       = "CANCLE_ORDER" // old spelling of CANCEL_ORDER, still accepted by the engine
 	END_MARKET         = "END_MARKET"
+	MARKET_STATUS      = "MARKET_STATUS"
+	SPLIT_SET          = "SPLIT_SET"
+	MERGE_SET          = "MERGE_SET"
+	PROPOSE_RESOLUTION = "PROPOSE_RESOLUTION"
+	DISPUTE_RESOLUTION = "DISPUTE_RESOLUTION"
+	VOTE_RESOLUTION    = "VOTE_RESOLUTION"
+	AMM_QUOTE          = "AMM_QUOTE"
+	LP_REPORT          = "LP_REPORT"
+	FEE_REPORT         = "FEE_REPORT"
 )
 
 type Balance struct {
-	Id      string  `json:"id"`
-	UserId  string  `json:"userId"`
-	Locked  float64 `json:"locked"`
-	Balance float64 `json:"balance"`
+	Id      string `json:"id"`
+	UserId  string `json:"userId"`
+	Locked  Amount `json:"locked"`
+	Balance Amount `json:"balance"`
 }
 
 type User struct {
@@ -72,9 +95,11 @@ type User struct {
 type OrderStatus string
 
 const (
-	PENDING   OrderStatus = "PENDING"
-	COMPLETED OrderStatus = "COMPLETED"
-	CANCELLED OrderStatus = "CANCELLED"
+	PENDING          OrderStatus = "PENDING"
+	PARTIALLY_FILLED OrderStatus = "PARTIALLY_FILLED"
+	COMPLETED        OrderStatus = "COMPLETED"
+	CANCELLED        OrderStatus = "CANCELLED"
+	EXPIRED          OrderStatus = "EXPIRED"
 )
 
 type orderType string
@@ -91,13 +116,34 @@ const (
 	NO  symbolStockType = "NO"
 )
 
+// OrderKind is how an order is priced: at a limit price or at the market
+type OrderKind string
+
+const (
+	LimitOrder  OrderKind = "limit"
+	MarketOrder OrderKind = "market"
+)
+
+// TimeInForce is how long an order stays in the book
+type TimeInForce string
+
+const (
+	GoodTillCancelled TimeInForce = "GTC" // rests until filled or cancelled
+	ImmediateOrCancel TimeInForce = "IOC" // fills what it can, the rest is cancelled
+	FillOrKill        TimeInForce = "FOK" // fills completely or not at all
+	GoodTillDate      TimeInForce = "GTD" // rests until filled, cancelled or expiresAt
+)
+
 type Order struct {
 	Id              string      `json:"id"`
 	UserId          string      `json:"userId"`
 	OrderType       orderType   `json:"orderType"`
-	Quantity        float64     `json:"quantity"`
-	FilledQty       float64     `json:"filledQty"`
-	Price           float64     `json:"price"`
+	Kind            OrderKind   `json:"kind"`
+	TimeInForce     TimeInForce `json:"timeInForce"`
+	ExpiresAt       int64       `json:"expiresAt,omitempty"` // unix millis, GTD only
+	Quantity        Shares      `json:"quantity"`
+	FilledQty       Shares      `json:"filledQty"`
+	Price           Amount      `json:"price"`
 	Status          OrderStatus `json:"status"`
 	Symbol          string      `json:"symbol"`
 	SymbolStockType string      `json:"symbolStockType"`
@@ -105,6 +151,28 @@ type Order struct {
 	UpdatedAt       string      `json:"updatedAt"`
 }
 
+// OrderUpdate is the UPDATE_ORDER payload sent to the database when an order
+// fills or is cancelled. FilledQty is the quantity filled by this update, not
+// the running total.
+type OrderUpdate struct {
+	OrderId   string      `json:"orderId"`
+	FilledQty Shares      `json:"filledQty"`
+	Status    OrderStatus `json:"status"`
+}
+
+// Fill is one execution of an incoming order against a resting order
+type Fill struct {
+	TradeId  string `json:"tradeId"`
+	OrderId  string `json:"orderId"` // resting order id, "amm" for the market maker
+	UserId   string `json:"userId"`  // resting order owner, or the AMM's account
+	Price    Amount `json:"price"`
+	Quantity Shares `json:"quantity"`
+	Type     string `json:"type"` // "mint" | "swap" | "burn" | "amm"
+	MakerFee Amount `json:"makerFee"`
+	TakerFee Amount `json:"takerFee"`
+	Reserved Amount `json:"-"` // the resting order's reserved maker fee the fill takes
+}
+
 type TransectionType string
 
 const (
@@ -112,16 +180,35 @@ const (
 	BOUGHT  TransectionType = "BOUGHT"
 	DEPOSIT TransectionType = "DEPOSIT"
 	CANCLE  TransectionType = "CANCLE"
+	TRADE   TransectionType = "TRADE"  // one fill between a resting and an incoming order
+	SPLIT   TransectionType = "SPLIT"  // USD turned into YES+NO pairs
+	MERGE   TransectionType = "MERGE"  // YES+NO pairs turned back into USD
+	REFUND  TransectionType = "REFUND" // a position unwound at cost when its market is voided
+	DEBT    TransectionType = "DEBT"   // what a void's clawback couldn't take from a user's free balance, left owing
+	BOND    TransectionType = "BOND"   // a failed dispute's bond, paid to the proposer it disputed
+	FUND    TransectionType = "FUND"   // a market maker's subsidy, from the treasury to its account
+	DEFUND  TransectionType = "DEFUND" // what's left in a market maker's account once its market is over, back to the treasury
+	FEE     TransectionType = "FEE"    // a settlement fee on winnings, paid into the fee account
 )
 
+// Transection is a movement of money or stocks. For TRADE records Id is the
+// trade id, the maker is the owner of the resting order and the taker the
+// owner of the incoming one; SymbolStockType and Price are the side the taker
+// bought or sold and its price per share.
 type Transection struct {
 	Id              string          `json:"id"`
 	MakerId         string          `json:"makerId"` // userId
 	GiverId         []string        `json:"giverId"` // exchangerID
 	TakerId         string          `json:"takerId"` // userId
+	MakerOrderId    string          `json:"makerOrderId,omitempty"`
+	TakerOrderId    string          `json:"takerOrderId,omitempty"`
 	TransectionType TransectionType `json:"transectionType"`
-	Quantity        float64         `json:"quantity"`
-	Price           float64         `json:"price"`
+	FillType        string          `json:"fillType,omitempty"` // "mint" | "swap" | "burn" | "amm", trades only
+	Quantity        Shares          `json:"quantity"`
+	Price           Amount          `json:"price"`
+	Amount          Amount          `json:"amount"`             // USD moved by deposits, payouts, splits and merges
+	MakerFee        Amount          `json:"makerFee,omitempty"` // trades only
+	TakerFee        Amount          `json:"takerFee,omitempty"`
 	Symbol          string          `json:"symbol"`
 	SymbolStockType string          `json:"symbolStockType"`
 	CreatedAt       string          `json:"createdAt"`
@@ -129,16 +216,23 @@ type Transection struct {
 }
 
 type Market struct {
-	Id                string `json:"id"`
-	Symbol            string `json:"symbol"`
-	SymbolStockType   string `json:"symbolStockType"`
-	SourceOfTruth     string `json:"sourceOfTruth"`
-	Heading           string `json:"heading"`
-	EventType         string `json:"eventType"`
-	RepeatEventTime   string `json:"repeatEventTime"`
-	EndEventAfterTime string `json:"endEventAfterTime"`
-	CreatedAt         string `json:"createdAt"`
-	UpdatedAt         string `json:"updatedAt"`
+	Id                string       `json:"id"`
+	Symbol            string       `json:"symbol"`
+	SymbolStockType   string       `json:"symbolStockType"`
+	SourceOfTruth     string       `json:"sourceOfTruth"`
+	Heading           string       `json:"heading"`
+	EventType         string       `json:"eventType"`
+	RepeatEventTime   string       `json:"repeatEventTime"`
+	EndEventAfterTime string       `json:"endEventAfterTime"`
+	Contract          ContractSpec `json:"contract"`
+	Outcomes          []string     `json:"outcomes,omitempty"`   // categorical markets only
+	Scalar            *ScalarRange `json:"scalar,omitempty"`     // scalar markets only
+	Oracle            string       `json:"oracle,omitempty"`     // automatic markets only
+	Feed              string       `json:"feed,omitempty"`       // what Oracle is asked for
+	Rule              string       `json:"rule,omitempty"`       // how Oracle's value resolves the market, see shared/rule
+	Resolution        *Resolution  `json:"resolution,omitempty"` // manual markets, once an outcome is proposed
+	CreatedAt         string       `json:"createdAt"`
+	UpdatedAt         string       `json:"updatedAt"`
 }
 
 type marketType string
@@ -156,31 +250,41 @@ const (
 )
 
 type CreateMarket struct {
-	Symbol          string `json:"symbol"`
-	MarketType      string `json:"marketType"`
-	EndsIn          int64  `json:"endsIn"`
-	SourceOfTruth   string `json:"sourceOfTruth"`
-	EndAfterTime    int64  `json:"endAfterTime"`
-	Heading         string `json:"heading"`
-	EventType       string `json:"eventType"`
-	RepeatEventTime int64  `json:"repeatEventTime"`
+	Symbol          string       `json:"symbol"`
+	MarketType      string       `json:"marketType"`
+	EndsIn          int64        `json:"endsIn"`
+	SourceOfTruth   string       `json:"sourceOfTruth"`
+	EndAfterTime    int64        `json:"endAfterTime"`
+	Heading         string       `json:"heading"`
+	EventType       string       `json:"eventType"`
+	RepeatEventTime int64        `json:"repeatEventTime"`
+	Draft           bool         `json:"draft"`         // create as DRAFT and open it later, instead of OPEN right away
+	Contract        ContractSpec `json:"contract"`      // zero fields take the defaults
+	Outcomes        []string     `json:"outcomes"`      // names of a categorical market's outcomes, empty for YES/NO
+	Scalar          *ScalarRange `json:"scalar"`        // bounds of a scalar market, nil for YES/NO
+	Oracle          string       `json:"oracle"`        // oracle an automatic market resolves on, empty for the default
+	Feed            string       `json:"feed"`          // what the oracle is asked for, set by the server
+	Rule            string       `json:"rule"`          // resolution rule, e.g. "price(bitcoin) > 105000 at close", see shared/rule
+	DisputeWindow   int64        `json:"disputeWindow"` // millis a manual market's proposed outcome can be disputed, 0 for the engine's default
+	AMM             *AMMConfig   `json:"amm"`           // an LMSR market maker instead of the random sell ladders, nil for none
+	LPSubsidy       Amount       `json:"lpSubsidy"`     // USD the sell ladders of each traded symbol are funded with, 0 for the engine's default
 }
 
 // OrderBook Types (equivalent to TypeScript interfaces)
 type OrderDetails struct {
-	UserId   string  `json:"userId"`
-	Quantity float64 `json:"quantity"`
-	Type     string  `json:"type"` // "reverted" | "regular"
+	UserId   string `json:"userId"`
+	Quantity Shares `json:"quantity"`
+	Type     string `json:"type"` // "reverted" | "regular"
 }
 
 type OrderBookOrders map[string]OrderDetails
 
 type OrderBookPerPrice struct {
-	Total  float64         `json:"total"`
+	Total  Shares          `json:"total"`
 	Orders OrderBookOrders `json:"orders"`
 }
 
-type OrderBookPrices map[float64]OrderBookPerPrice
+type OrderBookPrices map[Amount]OrderBookPerPrice
 
 type OrderBookPerStock struct {
 	Yes OrderBookPrices `json:"yes"`
@@ -191,36 +295,101 @@ type OrderBook map[string]OrderBookPerStock
 
 // OnRampProps for USD deposits
 type OnRampProps struct {
-	UserId string  `json:"userId"`
-	Amount float64 `json:"amount"`
+	UserId string `json:"userId"`
+	Amount Amount `json:"amount"`
 }
 
-// CancelOrderProps for order cancellation
+// CancelOrderProps for order cancellation, the engine finds the order by id
 type CancelOrderProps struct {
-	UserId      string  `json:"userId"`
-	StockSymbol string  `json:"stockSymbol"`
-	OrderId     string  `json:"orderId"`
-	StockType   string  `json:"stockType"` // "yes" | "no"
-	Price       float64 `json:"price"`
+	UserId  string `json:"userId"` // must own the order
+	OrderId string `json:"orderId"`
 }
 
 // OrderProps for placing orders
 type OrderProps struct {
-	UserId      string  `json:"userId"`
-	StockSymbol string  `json:"stockSymbol"`
-	Quantity    float64 `json:"quantity"`
-	Price       float64 `json:"price"`
-	StockType   string  `json:"stockType"` // "yes" | "no"
+	UserId      string      `json:"userId"`
+	StockSymbol string      `json:"stockSymbol"`
+	Quantity    Shares      `json:"quantity"`
+	Price       Amount      `json:"price"`       // limit price, ignored for market orders
+	StockType   string      `json:"stockType"`   // "yes" | "no"
+	Kind        OrderKind   `json:"kind"`        // "limit" (default) | "market"
+	TimeInForce TimeInForce `json:"timeInForce"` // GTC (default for limit) | IOC (default for market) | FOK | GTD
+	ExpiresAt   int64       `json:"expiresAt"`   // unix millis, required for GTD
+	Slippage    Amount      `json:"slippage"`    // market orders: max price points away from the best price
+}
+
+// CompleteSetProps asks to split USD into Quantity YES+NO pairs of a market,
+// or to merge Quantity pairs back into USD. Each pair is worth the market's payout.
+type CompleteSetProps struct {
+	UserId      string `json:"userId"`
+	StockSymbol string `json:"stockSymbol"`
+	Quantity    Shares `json:"quantity"`
 }
 
-// Enhanced Market with status tracking
+// MarketStatus is where a market is in its lifecycle:
+//
+//	DRAFT -> OPEN <-> HALTED
+//	OPEN | HALTED -> CLOSED -> RESOLVING -> SETTLED
+//	anything but SETTLED -> VOIDED
+//
+// Orders are only taken while a market is OPEN.
 type MarketStatus string
 
 const (
-	MarketActive    MarketStatus = "Active"
-	MarketCompleted MarketStatus = "COMPLETED"
+	MarketDraft     MarketStatus = "DRAFT"     // created, not trading yet
+	MarketOpen      MarketStatus = "OPEN"      // trading
+	MarketHalted    MarketStatus = "HALTED"    // trading paused, may reopen
+	MarketClosed    MarketStatus = "CLOSED"    // trading over, waiting for the outcome
+	MarketResolving MarketStatus = "RESOLVING" // outcome known, paying out
+	MarketSettled   MarketStatus = "SETTLED"   // paid out, final
+	MarketVoided    MarketStatus = "VOIDED"    // cancelled without an outcome, final
 )
 
+var marketTransitions = map[MarketStatus][]MarketStatus{
+	MarketDraft:     {MarketOpen, MarketVoided},
+	MarketOpen:      {MarketHalted, MarketClosed, MarketVoided},
+	MarketHalted:    {MarketOpen, MarketClosed, MarketVoided},
+	MarketClosed:    {MarketResolving, MarketVoided},
+	MarketResolving: {MarketSettled, MarketVoided},
+}
+
+// CanBecome reports whether a market may go from s to next
+func (s MarketStatus) CanBecome(next MarketStatus) bool {
+	for _, allowed := range marketTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// MarketTransition is one status change of a market
+type MarketTransition struct {
+	From MarketStatus `json:"from"`
+	To   MarketStatus `json:"to"`
+	At   string       `json:"at"`
+}
+
+// Settlement is published when a market finishes. A voided market has no
+// outcome and refunds every user what they paid for their positions; a
+// scalar one settles on a value rather than an outcome.
+type Settlement struct {
+	StockSymbol string            `json:"stockSymbol"`
+	Status      MarketStatus      `json:"status"`
+	Outcome     string            `json:"outcome,omitempty"`
+	Value       *float64          `json:"value,omitempty"`   // where a scalar market's value landed
+	Payouts     map[string]Amount `json:"payouts,omitempty"` // scalar markets: long/short -> USD per share
+	Refunds     map[string]Amount `json:"refunds,omitempty"` // userId -> USD returned
+	Debts       map[string]Amount `json:"debts,omitempty"`   // userId -> USD of a negative refund they couldn't pay
+	At          string            `json:"at"`
+}
+
+// MarketStatusProps asks to move a market to another status
+type MarketStatusProps struct {
+	StockSymbol string       `json:"stockSymbol"`
+	Status      MarketStatus `json:"status"`
+}
+
 type MarketType string
 
 const (
@@ -229,21 +398,87 @@ const (
 )
 
 type EnhancedMarket struct {
-	StockSymbol string       `json:"stockSymbol"`
-	Price       float64      `json:"price"`
-	Heading     string       `json:"heading"`
-	EventType   string       `json:"eventType"`
-	Type        MarketType   `json:"type"`
-	Status      MarketStatus `json:"status"`
+	StockSymbol   string             `json:"stockSymbol"`
+	Price         Amount             `json:"price"`
+	Heading       string             `json:"heading"`
+	EventType     string             `json:"eventType"`
+	Type          MarketType         `json:"type"`
+	Status        MarketStatus       `json:"status"`
+	Transitions   []MarketTransition `json:"transitions"`        // oldest first
+	ClosesAt      int64              `json:"closesAt,omitempty"` // unix millis the engine stops trading, 0 for never
+	Contract      ContractSpec       `json:"contract"`
+	Outcomes      []string           `json:"outcomes,omitempty"` // set on categorical markets
+	Parent        string             `json:"parent,omitempty"`   // set on the outcomes of a categorical market
+	Scalar        *ScalarRange       `json:"scalar,omitempty"`   // set on scalar markets
+	Oracle        string             `json:"oracle,omitempty"`   // set on automatic markets
+	Feed          string             `json:"feed,omitempty"`
+	Rule          string             `json:"rule,omitempty"`
+	DisputeWindow int64              `json:"disputeWindow,omitempty"` // millis, manual markets
+	Resolution    *Resolution        `json:"resolution,omitempty"`    // manual markets, once an outcome is proposed
+	AMM           *AMM               `json:"amm,omitempty"`           // set on markets traded against an LMSR market maker
+	LP            *LiquidityProvider `json:"lp,omitempty"`            // set on traded symbols with a market maker
+}
+
+// Clone returns a copy of the market that shares nothing with it, so the
+// engine can go on changing its markets in place: trades move the market
+// maker's inventory, disputes and votes add to the resolution.
+func (m EnhancedMarket) Clone() EnhancedMarket {
+	m.Transitions = slices.Clone(m.Transitions)
+	m.Outcomes = slices.Clone(m.Outcomes)
+	if m.Scalar != nil {
+		scalar := *m.Scalar
+		m.Scalar = &scalar
+	}
+	m.Resolution = m.Resolution.Clone()
+	if m.AMM != nil {
+		maker := *m.AMM
+		m.AMM = &maker
+	}
+	if m.LP != nil {
+		lp := *m.LP
+		m.LP = &lp
+	}
+	return m
+}
+
+// OutcomeNames returns the outcomes a market settles on: its own for a
+// categorical market, yes and no for a binary one
+func (m EnhancedMarket) OutcomeNames() []string {
+	if len(m.Outcomes) > 0 {
+		return m.Outcomes
+	}
+	return []string{"yes", "no"}
+}
+
+// OutcomeSymbol is the symbol an outcome of a categorical market trades
+// under. Each outcome is a YES/NO market of its own: YES pays if the outcome
+// wins.
+func OutcomeSymbol(symbol, outcome string) string {
+	return symbol + ":" + outcome
 }
 
 type Markets map[string]EnhancedMarket
 
+// CheckOpen returns an error unless the market of stockSymbol is taking orders
+func (m Markets) CheckOpen(stockSymbol string) error {
+	market, exists := m[stockSymbol]
+	if !exists {
+		return fmt.Errorf("market %s doesn't exist", stockSymbol)
+	}
+	if len(market.Outcomes) > 0 {
+		return fmt.Errorf("market %s is categorical, trade its outcomes as %s", stockSymbol, OutcomeSymbol(stockSymbol, "<outcome>"))
+	}
+	if market.Status != MarketOpen {
+		return fmt.Errorf("market %s is %s, not taking orders", stockSymbol, market.Status)
+	}
+	return nil
+}
+
 type USDBalances map[string]USDBalance
 
 type USDBalance struct {
-	Balance float64 `json:"balance"`
-	Locked  float64 `json:"locked"`
+	Balance Amount `json:"balance"`
+	Locked  Amount `json:"locked"`
 }
 
 type StockBalances map[string]UserStockBalance
@@ -256,8 +491,37 @@ type SymbolStockBalance struct {
 }
 
 type StockPosition struct {
-	Quantity float64 `json:"quantity"`
-	Locked   float64 `json:"locked"`
+	Quantity Shares `json:"quantity"`
+	Locked   Shares `json:"locked"`
+	Cost     Amount `json:"cost"` // USD paid for these stocks net of sales, can go negative
+}
+
+// AddCost adds amount to what userId has paid for their stockType ("yes" or
+// "no") stocks of a symbol. Sales pass a negative amount.
+func (b StockBalances) AddCost(userId, stockSymbol, stockType string, amount Amount) {
+	if _, exists := b[userId]; !exists {
+		b[userId] = make(UserStockBalance)
+	}
+	symbolStocks := b[userId][stockSymbol]
+	if stockType == "yes" {
+		symbolStocks.Yes.Cost += amount
+	} else {
+		symbolStocks.No.Cost += amount
+	}
+	b[userId][stockSymbol] = symbolStocks
+}
+
+// Side returns the position of stockType, "yes" or "no"
+func (s *SymbolStockBalance) Side(stockType string) *StockPosition {
+	if stockType == "yes" {
+		return &s.Yes
+	}
+	return &s.No
+}
+
+// Cost is what the user has paid for both sides of the symbol net of sales
+func (s SymbolStockBalance) Cost() Amount {
+	return s.Yes.Cost + s.No.Cost
 }
 
 type YesNoOrderBook map[string]SymbolOrderBook
@@ -267,26 +531,20 @@ type SymbolOrderBook struct {
 	No  PriceOrderBook `json:"no"`
 }
 
-type PriceOrderBook map[float64]PriceLevel
-
-// MarshalJSON custom marshaler for PriceOrderBook to handle float64 keys
-func (p PriceOrderBook) MarshalJSON() ([]byte, error) {
-	// Convert float64 keys to strings
-	stringMap := make(map[string]PriceLevel)
-	for price, level := range p {
-		stringMap[fmt.Sprintf("%.6f", price)] = level
-	}
-	return json.Marshal(stringMap)
-}
+// PriceOrderBook is keyed by price, encoded in JSON as "60.00" style keys
+type PriceOrderBook map[Amount]PriceLevel
 
 type PriceLevel struct {
-	Total  float64                   `json:"total"`
+	Total  Shares                    `json:"total"`
 	Orders map[string]OrderBookEntry `json:"orders"`
 }
 
 type OrderBookEntry struct {
-	UserId   string  `json:"userId"`
-	Quantity float64 `json:"quantity"`
-	Price    float64 `json:"price"`
-	Type     string  `json:"type"` // "reverted" | "regular"
+	UserId    string `json:"userId"`
+	Quantity  Shares `json:"quantity"`
+	Price     Amount `json:"price"`
+	Type      string `json:"type"`                // "reverted" | "regular"
+	Sequence  int64  `json:"sequence"`            // arrival order, used for time priority within a price level
+	ExpiresAt int64  `json:"expiresAt,omitempty"` // unix millis, GTD orders only
+	Fee       Amount `json:"fee,omitempty"`       // maker fee reserved out of the owner's balance, locked until it fills
 }
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	sharedRedis "github.com/adityadeshlahre/probo-v1/shared/redis"
@@ -49,21 +50,29 @@ func startRedisSubscription(symbol string) {
 				continue
 			}
 
-			sym, _ := data["symbol"].(string)
-			SendOrderBookToSubscribers(sym, data)
+			// "trades:<symbol>" carries the symbol's trades, "settlements:<symbol>"
+			// how its market finished, anything else its order book
+			if strings.HasPrefix(symbol, "trades:") {
+				SendToSubscribers(symbol, "event_trade", data)
+			} else if strings.HasPrefix(symbol, "settlements:") {
+				SendToSubscribers(symbol, "event_settlement", data)
+			} else {
+				SendToSubscribers(symbol, "event_orderbook_update", data)
+			}
 		}
 	}()
 }
 
-func SendOrderBookToSubscribers(symbol string, orderBook map[string]interface{}) {
-	log.Println("Sending order book to subscribers for:", symbol)
+// SendToSubscribers sends a message to every client subscribed to symbol
+func SendToSubscribers(symbol string, event string, message map[string]interface{}) {
+	log.Println("Sending", event, "to subscribers for:", symbol)
 
 	for _, sub := range subscriptionsMap {
 		if sub.Symbol == symbol {
 			for _, conn := range sub.Subscribers {
 				msg := OrderBookMessage{
-					Event:   "event_orderbook_update",
-					Message: orderBook,
+					Event:   event,
+					Message: message,
 				}
 				data, err := json.Marshal(msg)
 				if err != nil {
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"math"
 	"math/rand"
 	"net/http"
 	"time"
@@ -70,6 +69,7 @@ func main() {
 		EndAfterTime:  30000,
 		Heading:       "Will BTC be above $100k?",
 		EventType:     "crypto",
+		DisputeWindow: 2000, // settles 2 seconds after the outcome is proposed
 	}
 	marketJSON, _ := json.Marshal(marketReq)
 	resp, err := http.Post(serverURL+"/symbol/createmarket", "application/json", bytes.NewBuffer(marketJSON))
@@ -195,12 +195,12 @@ func main() {
 				stockType = "no"
 			}
 			quantity := 1
-			price := float64(10 + r.Intn(81)) // 10 to 90 USD
+			price := types.Amount(10+r.Intn(81)) * types.USD // 10 to 90 USD
 
 			orderProps := types.OrderProps{
 				UserId:      userID,
 				StockType:   stockType,
-				Quantity:    float64(quantity),
+				Quantity:    types.Shares(quantity),
 				Price:       price,
 				StockSymbol: marketSymbol,
 			}
@@ -224,7 +224,7 @@ func main() {
 				var orderResp map[string]interface{}
 				json.Unmarshal(body, &orderResp)
 				if status, ok := orderResp["status"].(bool); ok && status {
-					fmt.Printf("✓ Order placed for user %s (%s %s %.0f x %d)\n", userID, orderType, stockType, price, quantity)
+					fmt.Printf("✓ Order placed for user %s (%s %s %s x %d)\n", userID, orderType, stockType, price, quantity)
 				} else {
 					message, _ := orderResp["message"].(string)
 					if message == "insufficient balance" || message == "user doesn't have the required quantity" {
@@ -286,8 +286,22 @@ func main() {
 		resp.Body.Close()
 	}
 
-	// Test 7: End market randomly
+	// Test 7: End market randomly, by closing it and proposing the outcome
 	fmt.Println("Test 7: Ending market...")
+	closeJSON, _ := json.Marshal(map[string]interface{}{
+		"stockSymbol": marketSymbol,
+		"status":      "CLOSED",
+	})
+	resp, err = http.Post(serverURL+"/symbol/status", "application/json", bytes.NewBuffer(closeJSON))
+	if err != nil {
+		log.Printf("Failed to close market: %v", err)
+	} else {
+		if resp.StatusCode != 200 {
+			body, _ = io.ReadAll(resp.Body)
+			fmt.Printf("Market close response: %s\n", string(body))
+		}
+		resp.Body.Close()
+	}
 	winningStock := "yes"
 	if r.Float32() < 0.5 {
 		winningStock = "no"
@@ -296,6 +310,8 @@ func main() {
 		"stockSymbol":  marketSymbol,
 		"marketId":     marketSymbol,
 		"winningStock": winningStock,
+		"userId":       userIDs[0],
+		"evidence":     "test run",
 	}
 	endJSON, _ := json.Marshal(endReq)
 	resp, err = http.Post(serverURL+"/order/endmarket", "application/json", bytes.NewBuffer(endJSON))
@@ -311,8 +327,8 @@ func main() {
 		resp.Body.Close()
 	}
 
-	// Wait for settlement
-	time.Sleep(1 * time.Second)
+	// Wait for the dispute window to pass and the market to settle
+	time.Sleep(3 * time.Second)
 
 	// Test 8: Check final balances
 	fmt.Println("Test 8: Checking final balances...")
@@ -326,12 +342,11 @@ func main() {
 			body, _ = io.ReadAll(resp.Body)
 			var balResp BalanceResp
 			json.Unmarshal(body, &balResp)
-			// Check that locked is approximately 0
-			lockedDiff := math.Abs(balResp.Data.Balance.Locked)
-			if lockedDiff < 1e-10 {
-				fmt.Printf("✓ Final balance for %s: %.2f USD (locked: %.2f)\n", userID, balResp.Data.Balance.Balance, balResp.Data.Balance.Locked)
+			// Check that nothing is left locked
+			if balResp.Data.Balance.Locked == 0 {
+				fmt.Printf("✓ Final balance for %s: %s USD (locked: %s)\n", userID, balResp.Data.Balance.Balance, balResp.Data.Balance.Locked)
 			} else {
-				log.Printf("Final balance check failed for %s: locked %.2f", userID, balResp.Data.Balance.Locked)
+				log.Printf("Final balance check failed for %s: locked %s", userID, balResp.Data.Balance.Locked)
 			}
 		}
 		resp.Body.Close()